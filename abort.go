@@ -0,0 +1,73 @@
+package wasmtime
+
+import (
+	"sync/atomic"
+)
+
+// AbortSignal is a pair of flags shared between the host and a guest for
+// cooperative cancellation, which works with any guest that can call an
+// imported function, unlike epoch interruption and fuel which require no
+// cooperation but stop the guest abruptly.
+//
+// The host asks the guest to abort with `Abort`, which the guest observes by
+// polling the `abort_requested` function defined by
+// `Linker.DefineAbortSignal`. In the other direction the guest calls
+// `request_abort` to ask the host to abort, which the host observes with
+// `GuestAborted`, for example from a host function or after a call returns.
+//
+// An `AbortSignal` is safe to use from multiple goroutines, so `Abort` can be
+// called while the guest is running on another goroutine.
+type AbortSignal struct {
+	host, guest int32
+}
+
+// AbortSignal returns the abort signal of this store, which is shared with
+// the guest through the functions defined by `Linker.DefineAbortSignal`.
+func (store *Store) AbortSignal() *AbortSignal {
+	return &getDataInStore(store).abort
+}
+
+// Abort asks the guest to abort.
+func (s *AbortSignal) Abort() {
+	atomic.StoreInt32(&s.host, 1)
+}
+
+// Aborted returns whether the host has asked the guest to abort.
+func (s *AbortSignal) Aborted() bool {
+	return atomic.LoadInt32(&s.host) != 0
+}
+
+// GuestAborted returns whether the guest has asked the host to abort.
+func (s *AbortSignal) GuestAborted() bool {
+	return atomic.LoadInt32(&s.guest) != 0
+}
+
+// Reset clears the requests of both the host and the guest.
+func (s *AbortSignal) Reset() {
+	atomic.StoreInt32(&s.host, 0)
+	atomic.StoreInt32(&s.guest, 0)
+}
+
+// DefineAbortSignal defines functions in `module` which give guests access to
+// the `AbortSignal` of their store:
+//
+//   - `abort_requested: [] -> [i32]` returns 1 if the host has asked the guest
+//     to abort with `AbortSignal.Abort`, and 0 otherwise.
+//   - `request_abort: [] -> []` asks the host to abort, which it can observe
+//     with `AbortSignal.GuestAborted`.
+//
+// Returns an error if shadowing is disabled and the names are already defined.
+func (l *Linker) DefineAbortSignal(module string) error {
+	err := l.FuncWrap(module, "abort_requested", func(caller *Caller) int32 {
+		if getDataInStore(caller).abort.Aborted() {
+			return 1
+		}
+		return 0
+	})
+	if err != nil {
+		return err
+	}
+	return l.FuncWrap(module, "request_abort", func(caller *Caller) {
+		atomic.StoreInt32(&getDataInStore(caller).abort.guest, 1)
+	})
+}
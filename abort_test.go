@@ -0,0 +1,55 @@
+package wasmtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAbortSignal(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "abort" "abort_requested" (func $abort_requested (result i32)))
+	  (import "abort" "request_abort" (func $request_abort))
+	  ;; spins until the host asks it to abort, returning how many times it
+	  ;; polled
+	  (func (export "spin") (result i32) (local $n i32)
+	    (loop $continue
+	      (local.set $n (i32.add (local.get $n) (i32.const 1)))
+	      (br_if $continue (i32.eqz (call $abort_requested))))
+	    (local.get $n))
+	  (func (export "give_up") (call $request_abort))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineAbortSignal("abort"))
+	store := NewStore(engine)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+
+	signal := store.AbortSignal()
+	require.False(t, signal.Aborted())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		signal.Abort()
+	}()
+	result, err := instance.GetFunc(store, "spin").Call(store)
+	require.NoError(t, err)
+	require.Greater(t, result.(int32), int32(1))
+	require.True(t, signal.Aborted())
+
+	require.False(t, signal.GuestAborted())
+	_, err = instance.GetFunc(store, "give_up").Call(store)
+	require.NoError(t, err)
+	require.True(t, signal.GuestAborted())
+
+	signal.Reset()
+	require.False(t, signal.Aborted())
+	require.False(t, signal.GuestAborted())
+	require.Same(t, signal, store.AbortSignal())
+}
@@ -0,0 +1,119 @@
+package wasmtime
+
+// Helpers for host functions which exchange data with the guest through its
+// linear memory. They all access the caller's exported memory named "memory"
+// with the accessors of `Memory`, bounds checked in the same way, taking guest pointers and lengths as the
+// `int32` values wasm passes them as, which are interpreted as unsigned. They
+// return a trap if the memory doesn't exist or the access is out of bounds,
+// so host functions can return it directly.
+
+// ReadBytes returns a copy of the `size` bytes at `ptr` in the caller's
+// memory.
+func (c *Caller) ReadBytes(ptr, size int32) ([]byte, *Trap) {
+	region, trap := guestRegion(c, ptr, size)
+	if trap != nil {
+		return nil, trap
+	}
+	return append([]byte(nil), region...), nil
+}
+
+// ReadString returns the `size` bytes at `ptr` in the caller's memory as a
+// string.
+func (c *Caller) ReadString(ptr, size int32) (string, *Trap) {
+	region, trap := guestRegion(c, ptr, size)
+	if trap != nil {
+		return "", trap
+	}
+	return string(region), nil
+}
+
+// WriteBytes copies `data` to `ptr` in the caller's memory.
+func (c *Caller) WriteBytes(ptr int32, data []byte) *Trap {
+	region, trap := guestRegion(c, ptr, int32(len(data)))
+	if trap != nil {
+		return trap
+	}
+	copy(region, data)
+	return nil
+}
+
+// WriteString copies `s` to `ptr` in the caller's memory.
+func (c *Caller) WriteString(ptr int32, s string) *Trap {
+	region, trap := guestRegion(c, ptr, int32(len(s)))
+	if trap != nil {
+		return trap
+	}
+	copy(region, s)
+	return nil
+}
+
+// ReadUint32 reads the little-endian `u32` at `ptr` in the caller's memory,
+// see `Memory.ReadUint32`.
+func (c *Caller) ReadUint32(ptr int32) (uint32, *Trap) {
+	mem, trap := callerMemory(c)
+	if trap != nil {
+		return 0, trap
+	}
+	val, err := mem.ReadUint32(c, uint64(uint32(ptr)))
+	return val, memoryTrap(err)
+}
+
+// ReadUint64 reads the little-endian `u64` at `ptr` in the caller's memory,
+// see `Memory.ReadUint64`.
+func (c *Caller) ReadUint64(ptr int32) (uint64, *Trap) {
+	mem, trap := callerMemory(c)
+	if trap != nil {
+		return 0, trap
+	}
+	val, err := mem.ReadUint64(c, uint64(uint32(ptr)))
+	return val, memoryTrap(err)
+}
+
+// WriteUint32 writes `val` as a little-endian `u32` to `ptr` in the caller's
+// memory, see `Memory.WriteUint32`.
+func (c *Caller) WriteUint32(ptr int32, val uint32) *Trap {
+	mem, trap := callerMemory(c)
+	if trap != nil {
+		return trap
+	}
+	return memoryTrap(mem.WriteUint32(c, uint64(uint32(ptr)), val))
+}
+
+// WriteUint64 writes `val` as a little-endian `u64` to `ptr` in the caller's
+// memory, see `Memory.WriteUint64`.
+func (c *Caller) WriteUint64(ptr int32, val uint64) *Trap {
+	mem, trap := callerMemory(c)
+	if trap != nil {
+		return trap
+	}
+	return memoryTrap(mem.WriteUint64(c, uint64(uint32(ptr)), val))
+}
+
+// Returns the exported memory of the caller named "memory".
+func callerMemory(caller *Caller) (*Memory, *Trap) {
+	export := caller.GetExport("memory")
+	if export == nil || export.Memory() == nil {
+		return nil, NewTrap("guest does not export a memory named `memory`")
+	}
+	return export.Memory(), nil
+}
+
+// Returns the `size` bytes at `ptr` within the exported memory of the caller,
+// aliasing the memory rather than copying it.
+func guestRegion(caller *Caller, ptr, size int32) ([]byte, *Trap) {
+	mem, trap := callerMemory(caller)
+	if trap != nil {
+		return nil, trap
+	}
+	region, err := mem.region(caller, uint64(uint32(ptr)), uint64(uint32(size)))
+	return region, memoryTrap(err)
+}
+
+// Returns a trap for an error from one of the accessors of `Memory`, or nil
+// if there wasn't one.
+func memoryTrap(err error) *Trap {
+	if err == nil {
+		return nil
+	}
+	return NewTrap(err.Error())
+}
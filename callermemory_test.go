@@ -0,0 +1,72 @@
+package wasmtime
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallerMemoryHelpers(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "host" "upper" (func $upper (param i32 i32 i32) (result i32)))
+	  (import "host" "bad" (func $bad (param i32)))
+	  (memory (export "memory") 1)
+	  (data (i32.const 0) "hello")
+	  (func (export "upper") (result i32) (call $upper (i32.const 0) (i32.const 5) (i32.const 16)))
+	  (func (export "bad") (param i32) (call $bad (local.get 0)))
+	  (func (export "load64") (param i32) (result i64) (i64.load (local.get 0))))
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+
+	linker := NewLinker(store.Engine)
+	err = linker.FuncWrap("host", "upper", func(c *Caller, ptr, size, out int32) (int32, *Trap) {
+		s, trap := c.ReadString(ptr, size)
+		if trap != nil {
+			return 0, trap
+		}
+		if trap := c.WriteString(out+4, strings.ToUpper(s)); trap != nil {
+			return 0, trap
+		}
+		if trap := c.WriteUint32(out, uint32(len(s))); trap != nil {
+			return 0, trap
+		}
+		n, trap := c.ReadUint32(out)
+		if trap != nil {
+			return 0, trap
+		}
+		if trap := c.WriteUint64(32, uint64(n)<<32|1); trap != nil {
+			return 0, trap
+		}
+		return int32(n), nil
+	})
+	require.NoError(t, err)
+	err = linker.FuncWrap("host", "bad", func(c *Caller, ptr int32) *Trap {
+		_, trap := c.ReadBytes(ptr, 8)
+		return trap
+	})
+	require.NoError(t, err)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+
+	n, err := instance.GetFunc(store, "upper").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(5), n)
+	data := instance.GetExport(store, "memory").Memory().UnsafeData(store)
+	require.Equal(t, "HELLO", string(data[20:25]))
+	v, err := instance.GetFunc(store, "load64").Call(store, 32)
+	require.NoError(t, err)
+	require.Equal(t, int64(5)<<32|1, v)
+
+	_, err = instance.GetFunc(store, "bad").Call(store, 65536-8)
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "bad").Call(store, 65536-7)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds")
+	_, err = instance.GetFunc(store, "bad").Call(store, -1)
+	require.Error(t, err)
+}
@@ -0,0 +1,158 @@
+package wasmtime
+
+import (
+	"sync"
+)
+
+// MessageChannel is a bidirectional channel of byte messages between the host
+// and WebAssembly guests, backed by Go channels.
+//
+// A `MessageChannel` is made available to guests with
+// `Linker.DefineMessageChannel`, which defines the following functions in the
+// requested module, all of which operate on the guest's exported `memory`:
+//
+//	;; Sends the `len` bytes at `ptr` to the host, blocking until there is
+//	;; room in the channel. Returns 0 on success or -1 if the channel is
+//	;; closed.
+//	(func $send (param $ptr i32) (param $len i32) (result i32))
+//
+//	;; Blocks until a message from the host is available and copies it to
+//	;; `ptr`, returning its length. If the message is longer than `cap`
+//	;; then nothing is copied, the message is kept for the next call, and
+//	;; its length is still returned. Returns -1 if the channel is closed.
+//	(func $recv (param $ptr i32) (param $cap i32) (result i32))
+//
+//	;; Same as `recv` except that -2 is returned immediately if no message
+//	;; is available.
+//	(func $try_recv (param $ptr i32) (param $cap i32) (result i32))
+type MessageChannel struct {
+	toGuest   chan []byte
+	fromGuest chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// Messages taken from `toGuest` which haven't been copied to a guest yet,
+	// either because they didn't fit in its buffer or because another guest
+	// got here first.
+	pendingLock sync.Mutex
+	pending     [][]byte
+}
+
+// NewMessageChannel creates a new `MessageChannel` which buffers up to
+// `capacity` messages in each direction.
+func NewMessageChannel(capacity int) *MessageChannel {
+	return &MessageChannel{
+		toGuest:   make(chan []byte, capacity),
+		fromGuest: make(chan []byte, capacity),
+		closed:    make(chan struct{}),
+	}
+}
+
+// ToGuest returns the channel used to send messages to guests.
+//
+// Messages sent on this channel must not be modified afterwards. Closing this
+// channel causes guests to observe the channel as closed once all buffered
+// messages have been received.
+func (c *MessageChannel) ToGuest() chan<- []byte {
+	return c.toGuest
+}
+
+// FromGuest returns the channel on which messages sent by guests are
+// delivered.
+//
+// This channel is never closed, use `Close` to stop guests from sending any
+// further messages.
+func (c *MessageChannel) FromGuest() <-chan []byte {
+	return c.fromGuest
+}
+
+// Close closes this channel, causing any blocked and future guest operations
+// to return -1. It is safe to call this method more than once.
+func (c *MessageChannel) Close() {
+	c.closeOnce.Do(func() { close(c.closed) })
+}
+
+// DefineMessageChannel defines the functions described in `MessageChannel`
+// under the module name provided, backed by `ch`.
+//
+// Returns an error if shadowing is disabled and the names are already defined.
+func (l *Linker) DefineMessageChannel(module string, ch *MessageChannel) error {
+	err := l.FuncWrap(module, "send", ch.send)
+	if err == nil {
+		err = l.FuncWrap(module, "recv", func(caller *Caller, ptr, capacity int32) (int32, *Trap) {
+			return ch.recv(caller, ptr, capacity, true)
+		})
+	}
+	if err == nil {
+		err = l.FuncWrap(module, "try_recv", func(caller *Caller, ptr, capacity int32) (int32, *Trap) {
+			return ch.recv(caller, ptr, capacity, false)
+		})
+	}
+	return err
+}
+
+func (c *MessageChannel) send(caller *Caller, ptr, size int32) (int32, *Trap) {
+	region, trap := guestRegion(caller, ptr, size)
+	if trap != nil {
+		return 0, trap
+	}
+	msg := append([]byte(nil), region...)
+	select {
+	case <-c.closed:
+		return -1, nil
+	default:
+	}
+	select {
+	case c.fromGuest <- msg:
+		return 0, nil
+	case <-c.closed:
+		return -1, nil
+	}
+}
+
+func (c *MessageChannel) recv(caller *Caller, ptr, capacity int32, block bool) (int32, *Trap) {
+	select {
+	case <-c.closed:
+		return -1, nil
+	default:
+	}
+	// Don't hold the lock while waiting for a message so other guests can
+	// still receive a message already pending.
+	c.pendingLock.Lock()
+	if len(c.pending) == 0 {
+		c.pendingLock.Unlock()
+		var msg []byte
+		var ok bool
+		if block {
+			select {
+			case msg, ok = <-c.toGuest:
+			case <-c.closed:
+			}
+		} else {
+			select {
+			case msg, ok = <-c.toGuest:
+			case <-c.closed:
+			default:
+				return -2, nil
+			}
+		}
+		if !ok {
+			return -1, nil
+		}
+		c.pendingLock.Lock()
+		c.pending = append(c.pending, msg)
+	}
+	defer c.pendingLock.Unlock()
+	msg := c.pending[0]
+	if len(msg) > int(capacity) {
+		return int32(len(msg)), nil
+	}
+	region, trap := guestRegion(caller, ptr, int32(len(msg)))
+	if trap != nil {
+		return 0, trap
+	}
+	copy(region, msg)
+	c.pending[0] = nil
+	c.pending = c.pending[1:]
+	return int32(len(msg)), nil
+}
@@ -0,0 +1,82 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageChannel(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "chan" "send" (func $send (param i32 i32) (result i32)))
+	  (import "chan" "recv" (func $recv (param i32 i32) (result i32)))
+	  (import "chan" "try_recv" (func $try_recv (param i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  (func (export "echo") (param $cap i32) (result i32)
+	    (local $len i32)
+	    (local.set $len (call $recv (i32.const 0) (local.get $cap)))
+	    (if (i32.gt_s (local.get $len) (local.get $cap))
+	      (then (return (local.get $len))))
+	    (if (i32.lt_s (local.get $len) (i32.const 0))
+	      (then (return (local.get $len))))
+	    (call $send (i32.const 0) (local.get $len)))
+	  (func (export "poll") (result i32)
+	    (call $try_recv (i32.const 0) (i32.const 100)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	ch := NewMessageChannel(1)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineMessageChannel("chan", ch))
+	store := NewStore(engine)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	echo := instance.GetFunc(store, "echo")
+	poll := instance.GetFunc(store, "poll")
+
+	ch.ToGuest() <- []byte("hello")
+	result, err := echo.Call(store, 100)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), result)
+	require.Equal(t, []byte("hello"), <-ch.FromGuest())
+
+	// messages which don't fit are kept for the next call
+	ch.ToGuest() <- []byte("hello world")
+	result, err = echo.Call(store, 5)
+	require.NoError(t, err)
+	require.Equal(t, int32(11), result)
+	result, err = echo.Call(store, 11)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), result)
+	require.Equal(t, []byte("hello world"), <-ch.FromGuest())
+
+	result, err = poll.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(-2), result)
+
+	// a guest blocked in `recv` doesn't stop others from polling
+	other := NewStore(engine)
+	otherInstance, err := linker.Instantiate(other, module)
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		_, err := otherInstance.GetFunc(other, "echo").Call(other, 100)
+		done <- err
+	}()
+	result, err = poll.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(-2), result)
+	ch.ToGuest() <- []byte("ping")
+	require.Equal(t, []byte("ping"), <-ch.FromGuest())
+	require.NoError(t, <-done)
+
+	ch.Close()
+	result, err = echo.Call(store, 100)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), result)
+}
@@ -0,0 +1,80 @@
+package wasmtime
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// CompileModulesError is returned by `Engine.CompileModules` when some of the
+// modules fail to compile.
+type CompileModulesError struct {
+	// The error compiling each module, in the same order as the binaries
+	// passed to `CompileModules`, or nil for the modules which compiled.
+	Errors []error
+}
+
+func (e *CompileModulesError) Error() string {
+	failed, first := 0, -1
+	for i, err := range e.Errors {
+		if err != nil {
+			if first < 0 {
+				first = i
+			}
+			failed++
+		}
+	}
+	if first < 0 {
+		return "no modules failed to compile"
+	}
+	return fmt.Sprintf("%d of %d modules failed to compile, first module %d: %v", failed, len(e.Errors), first, e.Errors[first])
+}
+
+// CompileModules compiles each of the binaries in `wasms` with this engine, as
+// with `NewModule`, compiling up to `runtime.GOMAXPROCS` of them at a time.
+//
+// The returned modules are in the same order as `wasms`. If any of them fail
+// to compile then the others are still compiled, nil is returned in place of
+// those that failed, and the error is a `*CompileModulesError` describing
+// each failure.
+//
+// Compilation of modules which haven't started when `ctx` is done is
+// skipped, failing with the context's error. Modules which are already being
+// compiled are unaffected, since compilation can't be interrupted.
+func (engine *Engine) CompileModules(ctx context.Context, wasms [][]byte) ([]*Module, error) {
+	modules := make([]*Module, len(wasms))
+	errs := make([]error, len(wasms))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(wasms) {
+		workers = len(wasms)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				modules[i], errs[i] = NewModule(engine, wasms[i])
+			}
+		}()
+	}
+	for i := range wasms {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return modules, &CompileModulesError{Errors: errs}
+		}
+	}
+	return modules, nil
+}
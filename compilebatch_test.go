@@ -0,0 +1,52 @@
+package wasmtime
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileModules(t *testing.T) {
+	engine := NewEngine()
+	var wasms [][]byte
+	for i := 0; i < 20; i++ {
+		wasm, err := Wat2Wasm(fmt.Sprintf(`(module (func (export "f") (result i32) (i32.const %d)))`, i))
+		require.NoError(t, err)
+		wasms = append(wasms, wasm)
+	}
+	modules, err := engine.CompileModules(context.Background(), wasms)
+	require.NoError(t, err)
+	require.Len(t, modules, len(wasms))
+	store := NewStore(engine)
+	for i, module := range modules {
+		instance, err := NewInstance(store, module, nil)
+		require.NoError(t, err)
+		result, err := instance.GetFunc(store, "f").Call(store)
+		require.NoError(t, err)
+		require.Equal(t, int32(i), result)
+	}
+
+	modules, err = engine.CompileModules(context.Background(), [][]byte{wasms[0], {1, 2, 3}, wasms[1]})
+	var compileErr *CompileModulesError
+	require.ErrorAs(t, err, &compileErr)
+	require.Len(t, compileErr.Errors, 3)
+	require.NoError(t, compileErr.Errors[0])
+	require.Error(t, compileErr.Errors[1])
+	require.NoError(t, compileErr.Errors[2])
+	require.Contains(t, err.Error(), "1 of 3 modules failed to compile, first module 1")
+	require.NotNil(t, modules[0])
+	require.Nil(t, modules[1])
+	require.NotNil(t, modules[2])
+
+	modules, err = engine.CompileModules(context.Background(), nil)
+	require.NoError(t, err)
+	require.Empty(t, modules)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = engine.CompileModules(ctx, wasms)
+	require.ErrorAs(t, err, &compileErr)
+	require.ErrorIs(t, compileErr.Errors[0], context.Canceled)
+}
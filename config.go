@@ -40,11 +40,33 @@ const (
 	ProfilingStrategyNone ProfilingStrategy = C.WASMTIME_PROFILING_STRATEGY_NONE
 	// ProfilingStrategyJitdump will use the "jitdump" linux support
 	ProfilingStrategyJitdump ProfilingStrategy = C.WASMTIME_PROFILING_STRATEGY_JITDUMP
+	// ProfilingStrategyVTune will use support for Intel's VTune profiler
+	ProfilingStrategyVTune ProfilingStrategy = C.WASMTIME_PROFILING_STRATEGY_VTUNE
+	// ProfilingStrategyPerfMap will write a "perf map" file to `/tmp/perf-$PID.map`
+	// describing JIT code, which `perf` on linux uses to name functions
+	ProfilingStrategyPerfMap ProfilingStrategy = C.WASMTIME_PROFILING_STRATEGY_PERFMAP
 )
 
 // Config holds options used to create an Engine and customize its behavior.
 type Config struct {
 	_ptr *C.wasm_config_t
+
+	// Settings applied to this config so far, in order, see
+	// `Engine.SerializeConfig`.
+	settings []configSetting
+	// Whether modules keep their DWARF sections, see `SetSourceLocations`.
+	sourceLocations bool
+}
+
+// A call to a method of `Config`, recorded so that it can be replayed later.
+type configSetting struct {
+	Method string        `json:"method"`
+	Args   []interface{} `json:"args"`
+}
+
+// Records that the `Config` method `method` was called with `args`.
+func (cfg *Config) record(method string, args ...interface{}) {
+	cfg.settings = append(cfg.settings, configSetting{method, args})
 }
 
 // NewConfig creates a new `Config` with all default options configured.
@@ -57,9 +79,42 @@ func NewConfig() *Config {
 }
 
 // SetDebugInfo configures whether dwarf debug information for JIT code is enabled
+//
+// When enabled, the DWARF information of modules compiled with debug info is
+// translated to describe the generated machine code and registered with native
+// debuggers through the GDB JIT interface, so guests can be stepped through at
+// the source level with GDB or LLDB. Setting the `WASMTIME_BACKTRACE_DETAILS=1`
+// environment variable additionally makes trap backtraces include guest source
+// file and line information.
 func (cfg *Config) SetDebugInfo(enabled bool) {
 	C.wasmtime_config_debug_info_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetDebugInfo", enabled)
+}
+
+// SetSourceLocations configures whether modules keep the DWARF debug info of
+// the binaries they're compiled from, which `Module.SourceLocation` needs to
+// map offsets to source code. This is disabled by default, so the `.debug_*`
+// custom sections, which can take up many megabytes, aren't copied into every
+// module, and aren't returned by `Module.CustomSections` either.
+//
+// Unlike `SetDebugInfo` this doesn't change how code is compiled.
+func (cfg *Config) SetSourceLocations(enabled bool) {
+	cfg.sourceLocations = enabled
+	cfg.record("SetSourceLocations", enabled)
+}
+
+// SetNativeUnwindInfo configures whether native unwind information, such as
+// `.eh_frame` on Linux, is generated for JIT code. This is enabled by default
+// and is required for native debuggers and profilers to walk the stack through
+// WebAssembly frames.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.native_unwind_info
+func (cfg *Config) SetNativeUnwindInfo(enabled bool) {
+	C.wasmtime_config_native_unwind_info_set(cfg.ptr(), C.bool(enabled))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetNativeUnwindInfo", enabled)
 }
 
 // SetMaxWasmStack configures the maximum stack size, in bytes, that JIT code can use.
@@ -69,60 +124,102 @@ func (cfg *Config) SetDebugInfo(enabled bool) {
 func (cfg *Config) SetMaxWasmStack(size int) {
 	C.wasmtime_config_max_wasm_stack_set(cfg.ptr(), C.size_t(size))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetMaxWasmStack", size)
 }
 
 // SetWasmThreads configures whether the wasm threads proposal is enabled
 func (cfg *Config) SetWasmThreads(enabled bool) {
 	C.wasmtime_config_wasm_threads_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmThreads", enabled)
 }
 
 // SetWasmReferenceTypes configures whether the wasm reference types proposal is enabled
 func (cfg *Config) SetWasmReferenceTypes(enabled bool) {
 	C.wasmtime_config_wasm_reference_types_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmReferenceTypes", enabled)
 }
 
 // SetWasmSIMD configures whether the wasm SIMD proposal is enabled
 func (cfg *Config) SetWasmSIMD(enabled bool) {
 	C.wasmtime_config_wasm_simd_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmSIMD", enabled)
+}
+
+// SetWasmRelaxedSIMD configures whether the wasm relaxed SIMD proposal is
+// enabled, which also requires SIMD to be enabled.
+//
+// The results of relaxed SIMD instructions may differ between hosts, see
+// `SetWasmRelaxedSIMDDeterministic`. The default is false.
+func (cfg *Config) SetWasmRelaxedSIMD(enabled bool) {
+	C.wasmtime_config_wasm_relaxed_simd_set(cfg.ptr(), C.bool(enabled))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmRelaxedSIMD", enabled)
 }
 
 // SetWasmBulkMemory configures whether the wasm bulk memory proposal is enabled
 func (cfg *Config) SetWasmBulkMemory(enabled bool) {
 	C.wasmtime_config_wasm_bulk_memory_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmBulkMemory", enabled)
 }
 
 // SetWasmMultiValue configures whether the wasm multi value proposal is enabled
 func (cfg *Config) SetWasmMultiValue(enabled bool) {
 	C.wasmtime_config_wasm_multi_value_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmMultiValue", enabled)
 }
 
 // SetWasmMultiMemory configures whether the wasm multi memory proposal is enabled
 func (cfg *Config) SetWasmMultiMemory(enabled bool) {
 	C.wasmtime_config_wasm_multi_memory_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmMultiMemory", enabled)
 }
 
 // SetWasmMemory64 configures whether the wasm memory64 proposal is enabled
+//
+// 64-bit memories are indexed with `i64` addresses and can be larger than 4GiB.
+// Their types are created with `NewMemoryType64`.
 func (cfg *Config) SetWasmMemory64(enabled bool) {
 	C.wasmtime_config_wasm_memory64_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmMemory64", enabled)
 }
 
 // SetConsumFuel configures whether fuel is enabled
 func (cfg *Config) SetConsumeFuel(enabled bool) {
 	C.wasmtime_config_consume_fuel_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetConsumeFuel", enabled)
 }
 
 // SetStrategy configures what compilation strategy is used to compile wasm code
+//
+// Note that the Winch baseline compiler is not available in the version of
+// wasmtime this package links against, so Cranelift is the only compiler. Its
+// code generation can be tuned with `SetCraneliftOptLevel`,
+// `EnableCraneliftFlag`, and `SetCraneliftFlag`.
 func (cfg *Config) SetStrategy(strat Strategy) {
 	C.wasmtime_config_strategy_set(cfg.ptr(), C.wasmtime_strategy_t(strat))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetStrategy", strat)
+}
+
+// SetParallelCompilation configures whether modules are compiled using multiple
+// threads. This is enabled by default.
+//
+// The number of threads can't be configured through the C API. Wasmtime's
+// compilation thread pool is shared by every engine in the process and sizes
+// itself from the `RAYON_NUM_THREADS` environment variable when it's first
+// used, so set that variable before starting the process to bound it.
+func (cfg *Config) SetParallelCompilation(enabled bool) {
+	C.wasmtime_config_parallel_compilation_set(cfg.ptr(), C.bool(enabled))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetParallelCompilation", enabled)
 }
 
 // SetCraneliftDebugVerifier configures whether the cranelift debug verifier will be active when
@@ -130,22 +227,133 @@ func (cfg *Config) SetStrategy(strat Strategy) {
 func (cfg *Config) SetCraneliftDebugVerifier(enabled bool) {
 	C.wasmtime_config_cranelift_debug_verifier_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetCraneliftDebugVerifier", enabled)
 }
 
 // SetCraneliftOptLevel configures the cranelift optimization level for generated code
 func (cfg *Config) SetCraneliftOptLevel(level OptLevel) {
 	C.wasmtime_config_cranelift_opt_level_set(cfg.ptr(), C.wasmtime_opt_level_t(level))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetCraneliftOptLevel", level)
+}
+
+// SetCraneliftNanCanonicalization configures whether Cranelift replaces all NaN
+// values produced by floating point operations with a single canonical NaN.
+//
+// This isn't required by the WebAssembly specification, but is useful for
+// embeddings requiring entirely deterministic execution. The default is false.
+func (cfg *Config) SetCraneliftNanCanonicalization(enabled bool) {
+	C.wasmtime_config_cranelift_nan_canonicalization_set(cfg.ptr(), C.bool(enabled))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetCraneliftNanCanonicalization", enabled)
+}
+
+// SetWasmRelaxedSIMDDeterministic configures whether the instructions of the
+// wasm relaxed SIMD proposal produce the same results on every host, rather
+// than whatever is fastest on the current host. The default is false.
+func (cfg *Config) SetWasmRelaxedSIMDDeterministic(enabled bool) {
+	C.wasmtime_config_wasm_relaxed_simd_deterministic_set(cfg.ptr(), C.bool(enabled))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetWasmRelaxedSIMDDeterministic", enabled)
+}
+
+// SetDeterministic configures all settings related to nondeterministic
+// execution so that running the same module with the same inputs produces
+// bit-identical results on every host.
+//
+// This enables NaN canonicalization and deterministic relaxed SIMD, and
+// disables the wasm threads proposal since the interleaving of shared memory
+// accesses is inherently nondeterministic. Note that host functions and WASI
+// remain a source of nondeterminism that the embedder must control.
+func (cfg *Config) SetDeterministic() {
+	cfg.SetCraneliftNanCanonicalization(true)
+	cfg.SetWasmRelaxedSIMDDeterministic(true)
+	cfg.SetWasmThreads(false)
 }
 
 // SetProfiler configures what profiler strategy to use for generated code
+//
+// This lets native profilers such as `perf` and VTune attribute samples to the
+// WebAssembly code they belong to instead of anonymous regions of JIT code.
 func (cfg *Config) SetProfiler(profiler ProfilingStrategy) {
 	C.wasmtime_config_profiler_set(cfg.ptr(), C.wasmtime_profiling_strategy_t(profiler))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetProfiler", profiler)
+}
+
+// SetStaticMemoryForced configures whether linear memories are always allocated
+// in the "static" style, reserving `SetStaticMemoryMaximumSize` bytes of address
+// space up front, even if they declare a maximum size larger than that.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.static_memory_forced
+func (cfg *Config) SetStaticMemoryForced(enabled bool) {
+	C.wasmtime_config_static_memory_forced_set(cfg.ptr(), C.bool(enabled))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetStaticMemoryForced", enabled)
+}
+
+// SetStaticMemoryMaximumSize configures the amount of address space, in bytes,
+// reserved up front for each linear memory which can use the "static" style.
+// Memories whose maximum size isn't known to fit are allocated in the
+// "dynamic" style, which may move when grown.
+//
+// On 64-bit hosts this defaults to 4GiB. Setting it to 0 makes all memories
+// dynamic, which greatly reduces address space usage at the cost of some
+// performance. Newer versions of wasmtime call this `memory_reservation`.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.static_memory_maximum_size
+func (cfg *Config) SetStaticMemoryMaximumSize(size uint64) {
+	C.wasmtime_config_static_memory_maximum_size_set(cfg.ptr(), C.uint64_t(size))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetStaticMemoryMaximumSize", size)
+}
+
+// SetStaticMemoryGuardSize configures the size, in bytes, of the guard region
+// placed after "static" linear memories. On 64-bit hosts this defaults to
+// 2GiB.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.static_memory_guard_size
+func (cfg *Config) SetStaticMemoryGuardSize(size uint64) {
+	C.wasmtime_config_static_memory_guard_size_set(cfg.ptr(), C.uint64_t(size))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetStaticMemoryGuardSize", size)
+}
+
+// SetDynamicMemoryGuardSize configures the size, in bytes, of the guard region
+// placed after "dynamic" linear memories. This defaults to 64KiB.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.dynamic_memory_guard_size
+func (cfg *Config) SetDynamicMemoryGuardSize(size uint64) {
+	C.wasmtime_config_dynamic_memory_guard_size_set(cfg.ptr(), C.uint64_t(size))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetDynamicMemoryGuardSize", size)
+}
+
+// SetDynamicMemoryReservedForGrowth configures the size, in bytes, of extra
+// address space reserved after "dynamic" linear memories so they can grow in
+// place without being moved. On 64-bit hosts this defaults to 2GiB.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.dynamic_memory_reserved_for_growth
+func (cfg *Config) SetDynamicMemoryReservedForGrowth(size uint64) {
+	C.wasmtime_config_dynamic_memory_reserved_for_growth_set(cfg.ptr(), C.uint64_t(size))
+	runtime.KeepAlive(cfg)
+	cfg.record("SetDynamicMemoryReservedForGrowth", size)
 }
 
 // CacheConfigLoadDefault enables compiled code caching for this `Config` using the default settings
-// configuration can be found.
+// configuration file, if one can be found.
+//
+// With caching enabled, modules compiled with `NewModule` are stored on disk and
+// subsequent compilations of the same bytes with the same configuration load the
+// compiled code from disk instead of recompiling it.
+//
+// The cache configuration isn't part of `Engine.SerializeConfig` since it
+// doesn't affect the code that is produced.
 //
 // For more information about caching see
 // https://bytecodealliance.github.io/wasmtime/cli-cache.html
@@ -180,6 +388,7 @@ func (cfg *Config) CacheConfigLoad(path string) error {
 func (cfg *Config) SetEpochInterruption(enable bool) {
 	C.wasmtime_config_epoch_interruption_set(cfg.ptr(), C.bool(enable))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetEpochInterruption", enable)
 }
 
 // SetTarget configures the target triple that this configuration will produce
@@ -190,6 +399,15 @@ func (cfg *Config) SetEpochInterruption(enable bool) {
 // detection of SSE4.2 on x86_64 hosts). Native features can be reenabled with
 // the `cranelift_flag_{set,enable}` properties.
 //
+// Setting a target other than the host enables cross-compilation: modules can
+// be compiled with `NewModule` and then written out with `Module.Serialize` to
+// be loaded with `NewModuleDeserialize` on a machine of that architecture, but
+// they cannot be instantiated locally. Note that the prebuilt libraries bundled
+// with this package only include the code generator for their own
+// architecture, and creating an `Engine` for any other architecture will abort
+// the process. Cross-compiling requires linking against a wasmtime C API built
+// with Cranelift's `all-arch` feature.
+//
 // For more information see the Rust documentation at
 // https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.config
 func (cfg *Config) SetTarget(target string) error {
@@ -200,6 +418,7 @@ func (cfg *Config) SetTarget(target string) error {
 	if err != nil {
 		return mkError(err)
 	}
+	cfg.record("SetTarget", target)
 	return nil
 }
 
@@ -215,6 +434,7 @@ func (cfg *Config) EnableCraneliftFlag(flag string) {
 	C.wasmtime_config_cranelift_flag_enable(cfg.ptr(), cstr)
 	C.free(unsafe.Pointer(cstr))
 	runtime.KeepAlive(cfg)
+	cfg.record("EnableCraneliftFlag", flag)
 }
 
 // SetCraneliftFlag sets a target-specific flag in Cranelift to the specified value.
@@ -231,6 +451,7 @@ func (cfg *Config) SetCraneliftFlag(name string, value string) {
 	C.free(unsafe.Pointer(cstrName))
 	C.free(unsafe.Pointer(cstrValue))
 	runtime.KeepAlive(cfg)
+	cfg.record("SetCraneliftFlag", name, value)
 }
 
 // See comments in `ffi.go` for what's going on here
@@ -242,3 +463,14 @@ func (cfg *Config) ptr() *C.wasm_config_t {
 	}
 	return ret
 }
+
+// Close deallocates this configuration without waiting for it to be garbage
+// collected, if it hasn't already been used to create an `Engine`.
+func (cfg *Config) Close() {
+	if cfg._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(cfg, nil)
+	C.wasm_config_delete(cfg._ptr)
+	cfg._ptr = nil
+}
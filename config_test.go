@@ -1,7 +1,10 @@
 package wasmtime
 
 import (
+	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -9,24 +12,38 @@ import (
 
 func TestConfig(t *testing.T) {
 	NewConfig().SetDebugInfo(true)
+	NewConfig().SetNativeUnwindInfo(false)
 	NewConfig().SetMaxWasmStack(8388608)
 	NewConfig().SetWasmThreads(true)
 	NewConfig().SetWasmReferenceTypes(true)
 	NewConfig().SetWasmSIMD(true)
+	NewConfig().SetWasmRelaxedSIMD(true)
 	NewConfig().SetWasmBulkMemory(true)
 	NewConfig().SetWasmMultiValue(true)
 	NewConfig().SetWasmMultiMemory(true)
 	NewConfig().SetConsumeFuel(true)
 	NewConfig().SetStrategy(StrategyAuto)
 	NewConfig().SetStrategy(StrategyCranelift)
+	NewConfig().SetParallelCompilation(false)
 	NewConfig().SetCraneliftDebugVerifier(true)
 	NewConfig().SetCraneliftOptLevel(OptLevelNone)
 	NewConfig().SetCraneliftOptLevel(OptLevelSpeed)
 	NewConfig().SetCraneliftOptLevel(OptLevelSpeedAndSize)
+	NewConfig().SetCraneliftNanCanonicalization(true)
+	NewConfig().SetWasmRelaxedSIMDDeterministic(true)
+	NewConfig().SetDeterministic()
 	NewConfig().SetProfiler(ProfilingStrategyNone)
+	NewConfig().SetProfiler(ProfilingStrategyJitdump)
+	NewConfig().SetProfiler(ProfilingStrategyVTune)
+	NewConfig().SetProfiler(ProfilingStrategyPerfMap)
 	if runtime.GOARCH == "amd64" && runtime.GOOS == "linux" {
 		NewConfig().SetTarget("x86_64-unknown-linux-gnu")
 	}
+	NewConfig().SetStaticMemoryForced(true)
+	NewConfig().SetStaticMemoryMaximumSize(0)
+	NewConfig().SetStaticMemoryGuardSize(65536)
+	NewConfig().SetDynamicMemoryGuardSize(65536)
+	NewConfig().SetDynamicMemoryReservedForGrowth(0)
 	NewConfig().SetCraneliftFlag("opt_level", "none")
 	NewConfig().EnableCraneliftFlag("unwind_info")
 	err := NewConfig().CacheConfigLoadDefault()
@@ -34,3 +51,125 @@ func TestConfig(t *testing.T) {
 	err = NewConfig().CacheConfigLoad("nonexistent.toml")
 	require.Error(t, err)
 }
+
+func TestCacheConfigLoad(t *testing.T) {
+	// wasmtime's cache worker may still be writing to the cache directory in
+	// the background, so clean it up on a best-effort basis.
+	dir, err := os.MkdirTemp("", "wasmtime-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+	path := filepath.Join(dir, "config.toml")
+	config := "[cache]\nenabled = true\ndirectory = " + strconv.Quote(cacheDir) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+
+	wasm, err := Wat2Wasm(`(module (func (export "f")))`)
+	require.NoError(t, err)
+	compile := func() {
+		cfg := NewConfig()
+		require.NoError(t, cfg.CacheConfigLoad(path))
+		_, err := NewModule(NewEngineWithConfig(cfg), wasm)
+		require.NoError(t, err)
+	}
+	// Returns the compiled modules in the cache, skipping the statistics kept
+	// next to them and files the cache worker removes while walking.
+	entries := func() map[string]os.FileInfo {
+		ret := map[string]os.FileInfo{}
+		err := filepath.Walk(cacheDir, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err == nil && !info.IsDir() && filepath.Ext(path) == "" {
+				ret[path] = info
+			}
+			return err
+		})
+		require.NoError(t, err)
+		return ret
+	}
+
+	compile()
+	before := entries()
+	require.Len(t, before, 1)
+
+	// A cache miss would write the entry again, replacing the file.
+	compile()
+	after := entries()
+	require.Len(t, after, 1)
+	for path, info := range before {
+		require.Contains(t, after, path)
+		require.True(t, os.SameFile(info, after[path]))
+		require.Equal(t, info.ModTime(), after[path].ModTime())
+	}
+}
+
+func TestConfigDynamicMemory(t *testing.T) {
+	config := NewConfig()
+	config.SetStaticMemoryMaximumSize(0)
+	config.SetDynamicMemoryGuardSize(0)
+	config.SetDynamicMemoryReservedForGrowth(0)
+	store := NewStore(NewEngineWithConfig(config))
+	mem, err := NewMemory(store, NewMemoryType(1, false, 0))
+	require.NoError(t, err)
+	mem.UnsafeData(store)[0] = 1
+	_, err = mem.Grow(store, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), mem.Size(store))
+	require.Equal(t, byte(1), mem.UnsafeData(store)[0])
+}
+
+func TestConfigDeterministic(t *testing.T) {
+	wasm, err := Wat2Wasm(`
+	(module
+	  (func (export "nan") (param f32 f32) (result i32)
+	    (i32.reinterpret_f32 (f32.div (local.get 0) (local.get 1))))
+	)
+	`)
+	require.NoError(t, err)
+	config := NewConfig()
+	config.SetDeterministic()
+	store := NewStore(NewEngineWithConfig(config))
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	result, err := instance.GetFunc(store, "nan").Call(store, float32(0), float32(0))
+	require.NoError(t, err)
+	require.Equal(t, int32(0x7fc00000), result)
+}
+
+func TestConfigDebugInfo(t *testing.T) {
+	config := NewConfig()
+	config.SetDebugInfo(true)
+	config.SetNativeUnwindInfo(true)
+	store := NewStore(NewEngineWithConfig(config))
+	wasm, err := Wat2Wasm(`(module (func (export "f") unreachable))`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "f").Call(store)
+	require.Error(t, err)
+}
+
+func TestConfigProfilerPerfMap(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("perf maps are only written on linux")
+	}
+	config := NewConfig()
+	config.SetProfiler(ProfilingStrategyPerfMap)
+	engine := NewEngineWithConfig(config)
+	wasm, err := Wat2Wasm(`(module (func (export "f")))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	_, err = NewInstance(NewStore(engine), module, []AsExtern{})
+	require.NoError(t, err)
+
+	path := "/tmp/perf-" + strconv.Itoa(os.Getpid()) + ".map"
+	defer os.Remove(path)
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(contents), "wasm[0]::")
+}
@@ -12,6 +12,11 @@ under-documented here then you may have luck consulting the Rust documentation
 as well. As always though feel free to file any issues at
 https://github.com/bytecodealliance/wasmtime-go/issues/new.
 
+Objects allocated by Wasmtime, such as an Engine, Store, Module or Linker, are
+freed when they're garbage collected. They can also be freed right away by
+calling their Close method, which makes memory usage more predictable in
+long-running programs. An object must not be used after it's closed.
+
 It's also worth pointing out that the authors of this package up to this point
 primarily work in Rust, so if you've got suggestions of how to make this package
 more idiomatic for Go we'd love to hear your thoughts!
@@ -3,7 +3,12 @@ package wasmtime
 // #include <wasmtime.h>
 import "C"
 import (
+	"encoding/json"
+	"errors"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 )
 
 // Engine is an instance of a wasmtime engine which is used to create a `Store`.
@@ -12,6 +17,36 @@ import (
 // and such.
 type Engine struct {
 	_ptr *C.wasm_engine_t
+
+	// Settings of the `Config` this engine was created with.
+	settings []configSetting
+	// See `Config.SetSourceLocations`.
+	sourceLocations bool
+
+	hooksLock       sync.Mutex
+	instanceCreated func(InstanceInfo)
+	instanceDropped func(InstanceInfo)
+
+	// Ring buffer of recent events, see `RecentEvents`. A size of 0 means
+	// the default size and -1 means the log is disabled.
+	eventsLock sync.Mutex
+	events     []EngineEvent
+	eventsNext int
+	eventsSize int
+
+	// Number of stores and instances alive in this engine, see `Metrics`.
+	metricsLock sync.Mutex
+	stores      int
+	instances   int
+}
+
+// InstanceInfo describes an instance reported to the callbacks configured with
+// `Engine.SetInstanceHooks`.
+type InstanceInfo struct {
+	// The name of the module the instance was created from, see `Module.Name`.
+	ModuleName string
+	// The `Store.ID` of the store the instance belongs to.
+	StoreID uint64
 }
 
 // NewEngine creates a new `Engine` with default configuration.
@@ -30,7 +65,11 @@ func NewEngineWithConfig(config *Config) *Engine {
 	if config.ptr() == nil {
 		panic("config already used")
 	}
-	engine := &Engine{_ptr: C.wasm_engine_new_with_config(config.ptr())}
+	engine := &Engine{
+		_ptr:            C.wasm_engine_new_with_config(config.ptr()),
+		settings:        config.settings,
+		sourceLocations: config.sourceLocations,
+	}
 	runtime.SetFinalizer(config, nil)
 	config._ptr = nil
 	runtime.SetFinalizer(engine, func(engine *Engine) {
@@ -39,12 +78,97 @@ func NewEngineWithConfig(config *Config) *Engine {
 	return engine
 }
 
+// The version of wasmtime this package is linked against.
+const wasmtimeVersion = C.WASMTIME_VERSION
+
+// The format of `Engine.SerializeConfig`.
+type serializedConfig struct {
+	Version  string          `json:"wasmtime"`
+	Settings []configSetting `json:"settings"`
+}
+
+// SerializeConfig returns an encoding of the configuration used to create this
+// engine, which can be passed to `NewEngineFromSerializedConfig` to create an
+// engine with the same configuration.
+//
+// This is intended to be stored alongside artifacts produced with
+// `Module.Serialize` so that an engine which is able to load them can be
+// reconstructed later.
+func (engine *Engine) SerializeConfig() []byte {
+	ret, err := json.Marshal(serializedConfig{
+		Version:  wasmtimeVersion,
+		Settings: engine.settings,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// NewEngineFromSerializedConfig creates a new `Engine` using a configuration
+// previously returned by `Engine.SerializeConfig`.
+//
+// Returns an error if the configuration is malformed, was produced by a
+// different version of wasmtime, or contains settings which fail to apply.
+func NewEngineFromSerializedConfig(serialized []byte) (*Engine, error) {
+	var decoded struct {
+		Version  string `json:"wasmtime"`
+		Settings []struct {
+			Method string            `json:"method"`
+			Args   []json.RawMessage `json:"args"`
+		} `json:"settings"`
+	}
+	if err := json.Unmarshal(serialized, &decoded); err != nil {
+		return nil, err
+	}
+	if decoded.Version != wasmtimeVersion {
+		return nil, errors.New("config was serialized by wasmtime " + decoded.Version + ", not " + wasmtimeVersion)
+	}
+
+	config := NewConfig()
+	for _, setting := range decoded.Settings {
+		method := reflect.ValueOf(config).MethodByName(setting.Method)
+		isSetter := strings.HasPrefix(setting.Method, "Set") || strings.HasPrefix(setting.Method, "Enable")
+		if !isSetter || !method.IsValid() || method.Type().NumIn() != len(setting.Args) {
+			return nil, errors.New("unknown config setting: " + setting.Method)
+		}
+		args := make([]reflect.Value, len(setting.Args))
+		for i, raw := range setting.Args {
+			arg := reflect.New(method.Type().In(i))
+			if err := json.Unmarshal(raw, arg.Interface()); err != nil {
+				return nil, err
+			}
+			args[i] = arg.Elem()
+		}
+		for _, result := range method.Call(args) {
+			if err, ok := result.Interface().(error); ok && err != nil {
+				return nil, err
+			}
+		}
+	}
+	return NewEngineWithConfig(config), nil
+}
+
 func (engine *Engine) ptr() *C.wasm_engine_t {
 	ret := engine._ptr
+	if ret == nil {
+		panic("Engine used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this engine without waiting for it to be garbage collected.
+// Stores and modules created from it remain usable.
+func (engine *Engine) Close() {
+	if engine._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(engine, nil)
+	C.wasm_engine_delete(engine._ptr)
+	engine._ptr = nil
+}
+
 // IncrementEpoch will increase the current epoch number by 1 within the
 // current engine which will cause any connected stores with their epoch
 // deadline exceeded to now be interrupted.
@@ -54,3 +178,28 @@ func (engine *Engine) IncrementEpoch() {
 	C.wasmtime_engine_increment_epoch(engine.ptr())
 	runtime.KeepAlive(engine)
 }
+
+// SetInstanceHooks configures callbacks which are invoked whenever an instance
+// is created within, or dropped from, a `Store` using this engine. This can be
+// used to maintain an inventory of live instances, for example to detect
+// instances which are kept alive for longer than intended.
+//
+// Instances are created by `NewInstance` and `Linker.Instantiate`, and are
+// dropped when the `Store` that owns them is garbage collected. The `dropped`
+// callback is therefore invoked from a finalizer and may run on any goroutine.
+// Instances created by wasmtime itself, for example by `Linker.DefineModule`,
+// are not reported.
+//
+// Either callback may be nil. This method is safe to call from any goroutine.
+func (engine *Engine) SetInstanceHooks(created, dropped func(InstanceInfo)) {
+	engine.hooksLock.Lock()
+	engine.instanceCreated = created
+	engine.instanceDropped = dropped
+	engine.hooksLock.Unlock()
+}
+
+func (engine *Engine) instanceHooks() (created, dropped func(InstanceInfo)) {
+	engine.hooksLock.Lock()
+	defer engine.hooksLock.Unlock()
+	return engine.instanceCreated, engine.instanceDropped
+}
@@ -1,7 +1,9 @@
 package wasmtime
 
 import (
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -27,3 +29,120 @@ func TestEngineInvalidatesConfig(t *testing.T) {
 		require.Nil(t, engine)
 	}
 }
+
+func TestEngineInstanceHooks(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`(module $hooked)`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	require.Equal(t, "hooked", module.Name())
+
+	var created []InstanceInfo
+	dropped := make(chan InstanceInfo, 2)
+	engine.SetInstanceHooks(
+		func(info InstanceInfo) { created = append(created, info) },
+		func(info InstanceInfo) { dropped <- info },
+	)
+
+	id := func() uint64 {
+		store := NewStore(engine)
+		_, err := NewInstance(store, module, []AsExtern{})
+		require.NoError(t, err)
+		_, err = NewLinker(engine).Instantiate(store, module)
+		require.NoError(t, err)
+		return store.ID()
+	}()
+	require.Equal(t, []InstanceInfo{
+		{ModuleName: "hooked", StoreID: id},
+		{ModuleName: "hooked", StoreID: id},
+	}, created)
+
+	for i := 0; i < 2; i++ {
+		timeout := time.After(10 * time.Second)
+	wait:
+		for {
+			runtime.GC()
+			select {
+			case info := <-dropped:
+				require.Equal(t, InstanceInfo{ModuleName: "hooked", StoreID: id}, info)
+				break wait
+			case <-timeout:
+				t.Fatal("instance was never dropped")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+}
+
+func TestEngineSerializeConfig(t *testing.T) {
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	config.SetWasmMemory64(true)
+	config.SetCraneliftOptLevel(OptLevelSpeedAndSize)
+	config.SetCraneliftFlag("opt_level", "speed_and_size")
+	config.SetSourceLocations(true)
+	engine := NewEngineWithConfig(config)
+
+	wasm, err := Wat2Wasm(`(module (func (export "f")))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	bytes, err := module.Serialize()
+	require.NoError(t, err)
+
+	restored, err := NewEngineFromSerializedConfig(engine.SerializeConfig())
+	require.NoError(t, err)
+	require.Equal(t, engine.SerializeConfig(), restored.SerializeConfig())
+	require.True(t, restored.sourceLocations)
+	_, err = NewModuleDeserialize(restored, bytes)
+	require.NoError(t, err)
+	store := NewStore(restored)
+	require.NoError(t, store.AddFuel(1))
+
+	// the default configuration can't load the artifact
+	_, err = NewModuleDeserialize(NewEngine(), bytes)
+	require.Error(t, err)
+
+	_, err = NewEngineFromSerializedConfig([]byte(`{"wasmtime":"0.0.0"}`))
+	require.Error(t, err)
+	_, err = NewEngineFromSerializedConfig([]byte(`{"wasmtime":"` + wasmtimeVersion + `","settings":[{"method":"CacheConfigLoad","args":["x"]}]}`))
+	require.Error(t, err)
+}
+
+func TestEngineClose(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`(module (func (export "f") (result i32) i32.const 1))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	store := NewStore(engine)
+	linker := NewLinker(engine)
+	ty := NewFuncType([]*ValType{NewValType(KindI32)}, nil)
+	params := ty.Params()
+
+	// closing the engine, module and linker leaves existing objects usable
+	params[0].Close()
+	ty.Close()
+	ty.Close()
+	engine.Close()
+	engine.Close()
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	linker.Close()
+	module.Close()
+	result, err := instance.GetFunc(store, "f").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result)
+
+	require.Panics(t, func() { NewStore(engine) })
+	require.Panics(t, func() { module.Exports() })
+	require.Panics(t, func() { ty.Params() })
+
+	config := NewConfig()
+	config.Close()
+	config.Close()
+	wasi := NewWasiConfig()
+	wasi.Close()
+	wasi.Close()
+}
@@ -5,7 +5,8 @@ import "C"
 import "runtime"
 
 type Error struct {
-	_ptr *C.wasmtime_error_t
+	_ptr    *C.wasmtime_error_t
+	payload interface{}
 }
 
 func mkError(ptr *C.wasmtime_error_t) *Error {
@@ -18,10 +19,23 @@ func mkError(ptr *C.wasmtime_error_t) *Error {
 
 func (e *Error) ptr() *C.wasmtime_error_t {
 	ret := e._ptr
+	if ret == nil {
+		panic("Error used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this error without waiting for it to be garbage collected.
+func (e *Error) Close() {
+	if e._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(e, nil)
+	C.wasmtime_error_delete(e._ptr)
+	e._ptr = nil
+}
+
 func (e *Error) Error() string {
 	message := C.wasm_byte_vec_t{}
 	C.wasmtime_error_message(e.ptr(), &message)
@@ -40,3 +54,16 @@ func (e *Error) ExitStatus() (int32, bool) {
 	runtime.KeepAlive(e)
 	return int32(status), bool(ok)
 }
+
+// Payload returns the payload of the trap raised by a host function which
+// caused this error, see `NewTrapWithPayload`, or nil if there isn't one.
+func (e *Error) Payload() interface{} {
+	return e.payload
+}
+
+// Unwrap returns the payload of this error if it's an error itself, so that
+// `errors.As` and `errors.Is` see through to it.
+func (e *Error) Unwrap() error {
+	err, _ := e.payload.(error)
+	return err
+}
@@ -0,0 +1,109 @@
+package wasmtime
+
+import (
+	"fmt"
+	"time"
+)
+
+// EngineEventKind is the kind of an `EngineEvent`.
+type EngineEventKind int
+
+const (
+	// A module was compiled, or failed to compile, with `NewModule`.
+	EngineEventCompile EngineEventKind = iota
+	// An instance was created.
+	EngineEventInstantiate
+	// A call into WebAssembly trapped.
+	EngineEventTrap
+	// The host tried to grow a memory or table, or create an instance, and
+	// was denied by a limit.
+	EngineEventLimitDenied
+)
+
+func (kind EngineEventKind) String() string {
+	switch kind {
+	case EngineEventCompile:
+		return "compile"
+	case EngineEventInstantiate:
+		return "instantiate"
+	case EngineEventTrap:
+		return "trap"
+	case EngineEventLimitDenied:
+		return "limit denied"
+	}
+	return fmt.Sprintf("EngineEventKind(%d)", int(kind))
+}
+
+// EngineEvent is an event recorded in the log returned by
+// `Engine.RecentEvents`.
+type EngineEvent struct {
+	Time time.Time
+	Kind EngineEventKind
+	// The `Store.ID` of the store the event happened in, or 0 for events
+	// which don't happen in a store, such as compilation.
+	StoreID uint64
+	// A description of the event, such as the name of the module compiled
+	// or instantiated, the trap message, or the limit which denied a request.
+	Detail string
+}
+
+func (event EngineEvent) String() string {
+	return fmt.Sprintf("%s %s store=%d: %s", event.Time.Format(time.RFC3339Nano), event.Kind, event.StoreID, event.Detail)
+}
+
+// The number of events `Engine.RecentEvents` keeps by default.
+const defaultEngineEventLogSize = 128
+
+// SetEventLogSize configures how many of the most recent events are kept in
+// the log returned by `RecentEvents`, discarding the current log. A size of 0
+// disables the log. By default the last 128 events are kept.
+func (engine *Engine) SetEventLogSize(size int) {
+	if size <= 0 {
+		size = -1
+	}
+	engine.eventsLock.Lock()
+	defer engine.eventsLock.Unlock()
+	engine.eventsSize = size
+	engine.events = nil
+	engine.eventsNext = 0
+}
+
+// RecentEvents returns the most recent events in this engine, such as
+// compilations, instantiations, traps, and requests denied by limits, oldest
+// first.
+//
+// The log is always maintained so that crash handlers and support tooling can
+// dump recent history without having set up instrumentation beforehand.
+func (engine *Engine) RecentEvents() []EngineEvent {
+	engine.eventsLock.Lock()
+	defer engine.eventsLock.Unlock()
+	ret := make([]EngineEvent, 0, len(engine.events))
+	ret = append(ret, engine.events[engine.eventsNext:]...)
+	return append(ret, engine.events[:engine.eventsNext]...)
+}
+
+// Records an event in the log returned by `RecentEvents`.
+func (engine *Engine) recordEvent(kind EngineEventKind, storeID uint64, detail string) {
+	engine.eventsLock.Lock()
+	defer engine.eventsLock.Unlock()
+	size := engine.eventsSize
+	if size < 0 {
+		return
+	}
+	if size == 0 {
+		size = defaultEngineEventLogSize
+	}
+	event := EngineEvent{Time: time.Now(), Kind: kind, StoreID: storeID, Detail: detail}
+	if len(engine.events) < size {
+		engine.events = append(engine.events, event)
+		return
+	}
+	engine.events[engine.eventsNext] = event
+	engine.eventsNext = (engine.eventsNext + 1) % size
+}
+
+// Records that a request in this store was denied by a limit, returning `err`.
+func (data *storeData) limitDenied(err error) error {
+	data.engine.recordEvent(EngineEventLimitDenied, data.id, err.Error())
+	return err
+}
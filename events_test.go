@@ -0,0 +1,58 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineRecentEvents(t *testing.T) {
+	engine := NewEngine()
+	store := NewStore(engine)
+	wasm, err := Wat2Wasm(`(module $m (func (export "f") unreachable))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	_, err = NewModule(engine, []byte{1, 2, 3})
+	require.Error(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "f").Call(store)
+	require.Error(t, err)
+	require.NoError(t, engine.NewQuotaGroup(QuotaLimits{MemorySize: 65536}).Add(store))
+	memory, err := NewMemory(store, NewMemoryType(1, false, 0))
+	require.NoError(t, err)
+	_, err = memory.Grow(store, 1)
+	require.Error(t, err)
+
+	events := engine.RecentEvents()
+	require.Len(t, events, 5)
+	require.Equal(t, EngineEvent{Kind: EngineEventCompile, Detail: "m"}, EngineEvent{Kind: events[0].Kind, Detail: events[0].Detail})
+	require.Equal(t, EngineEventCompile, events[1].Kind)
+	require.Contains(t, events[1].Detail, "failed")
+	require.Equal(t, EngineEventInstantiate, events[2].Kind)
+	require.Equal(t, store.ID(), events[2].StoreID)
+	require.Equal(t, EngineEventTrap, events[3].Kind)
+	require.Contains(t, events[3].Detail, "unreachable")
+	require.Equal(t, EngineEventLimitDenied, events[4].Kind)
+	require.Contains(t, events[4].String(), "limit denied")
+
+	// only the most recent events are kept
+	engine.SetEventLogSize(2)
+	require.Empty(t, engine.RecentEvents())
+	for i := 0; i < 3; i++ {
+		_, err = NewInstance(store, module, []AsExtern{})
+		require.NoError(t, err)
+	}
+	_, err = instance.GetFunc(store, "f").Call(store)
+	require.Error(t, err)
+	events = engine.RecentEvents()
+	require.Len(t, events, 2)
+	require.Equal(t, EngineEventInstantiate, events[0].Kind)
+	require.Equal(t, EngineEventTrap, events[1].Kind)
+
+	engine.SetEventLogSize(0)
+	_, err = NewModule(engine, wasm)
+	require.NoError(t, err)
+	require.Empty(t, engine.RecentEvents())
+}
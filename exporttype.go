@@ -40,10 +40,25 @@ func mkExportType(ptr *C.wasm_exporttype_t, owner interface{}) *ExportType {
 
 func (ty *ExportType) ptr() *C.wasm_exporttype_t {
 	ret := ty._ptr
+	if ret == nil {
+		panic("ExportType used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this type without waiting for it to be garbage collected.
+// It has no effect on types which belong to another object, such as those
+// returned from the methods of other types.
+func (ty *ExportType) Close() {
+	if ty._ptr == nil || ty._owner != nil {
+		return
+	}
+	runtime.SetFinalizer(ty, nil)
+	C.wasm_exporttype_delete(ty._ptr)
+	ty._ptr = nil
+}
+
 func (ty *ExportType) owner() interface{} {
 	if ty._owner != nil {
 		return ty._owner
@@ -2,7 +2,10 @@ package wasmtime
 
 // #include "shims.h"
 import "C"
-import "runtime"
+import (
+	"runtime"
+	"unsafe"
+)
 
 // Extern is an external value, which is the runtime representation of an entity that can be imported or exported.
 // It is an address denoting either a function instance, table instance, memory instance, or global instances in the shared store.
@@ -26,12 +29,33 @@ func mkExtern(ptr *C.wasmtime_extern_t) *Extern {
 
 func (e *Extern) ptr() *C.wasmtime_extern_t {
 	ret := e._ptr
+	if ret == nil {
+		panic("Extern used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this extern without waiting for it to be garbage
+// collected. The item it refers to lives on in its store.
+func (e *Extern) Close() {
+	if e._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(e, nil)
+	C.wasmtime_extern_delete(e._ptr)
+	e._ptr = nil
+}
+
+// Returns the internal identifier of the store the item `ext` refers to
+// belongs to, which every kind of extern starts with.
+func externStoreID(ext *C.wasmtime_extern_t) C.uint64_t {
+	return *(*C.uint64_t)(unsafe.Pointer(&ext.of))
+}
+
 // Type returns the type of this export
 func (e *Extern) Type(store Storelike) *ExternType {
+	assertStore(store, externStoreID(e._ptr), "extern")
 	ptr := C.wasmtime_extern_type(store.Context(), e.ptr())
 	runtime.KeepAlive(e)
 	runtime.KeepAlive(store)
@@ -27,10 +27,25 @@ func mkExternType(ptr *C.wasm_externtype_t, owner interface{}) *ExternType {
 
 func (ty *ExternType) ptr() *C.wasm_externtype_t {
 	ret := ty._ptr
+	if ret == nil {
+		panic("ExternType used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this type without waiting for it to be garbage collected.
+// It has no effect on types which belong to another object, such as those
+// returned from the methods of other types.
+func (ty *ExternType) Close() {
+	if ty._ptr == nil || ty._owner != nil {
+		return
+	}
+	runtime.SetFinalizer(ty, nil)
+	C.wasm_externtype_delete(ty._ptr)
+	ty._ptr = nil
+}
+
 func (ty *ExternType) owner() interface{} {
 	if ty._owner != nil {
 		return ty._owner
@@ -0,0 +1,25 @@
+package wasmtime
+
+// Failpoint identifies a location in these bindings where a failure of the
+// underlying C API can be simulated.
+//
+// Failpoints only have an effect when this package is built with the
+// `failpoints` build tag, in which case they can be armed with
+// `EnableFailpoint`. Otherwise they're compiled out entirely. They exist so
+// that the error-handling paths around cgo calls, which are otherwise very
+// difficult to reach, can be exercised in tests.
+type Failpoint string
+
+const (
+	// FailpointAlloc simulates an allocation failure when creating or
+	// growing memories, tables, and globals.
+	FailpointAlloc Failpoint = "alloc"
+	// FailpointCompile simulates a failure to compile or validate a module.
+	FailpointCompile Failpoint = "compile"
+	// FailpointDeserialize corrupts serialized modules before they're handed
+	// to wasmtime for deserialization.
+	FailpointDeserialize Failpoint = "deserialize"
+	// FailpointTrap simulates a trap being raised whenever WebAssembly is
+	// entered, for example via `Func.Call` or instantiation.
+	FailpointTrap Failpoint = "trap"
+)
@@ -0,0 +1,98 @@
+//go:build failpoints
+// +build failpoints
+
+package wasmtime
+
+// See `failpoint.go` for what's going on here.
+
+// #include <wasmtime.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+var gFailpointLock sync.Mutex
+var gFailpoints = make(map[Failpoint]int)
+
+// EnableFailpoint arms the failpoint `fp` so that its next `count`
+// evaluations fail. If `count` is negative then every evaluation fails until
+// the failpoint is disabled with `DisableFailpoint`.
+//
+// This function is only available when built with the `failpoints` tag.
+func EnableFailpoint(fp Failpoint, count int) {
+	gFailpointLock.Lock()
+	defer gFailpointLock.Unlock()
+	if count == 0 {
+		delete(gFailpoints, fp)
+	} else {
+		gFailpoints[fp] = count
+	}
+}
+
+// DisableFailpoint disarms the failpoint `fp`.
+//
+// This function is only available when built with the `failpoints` tag.
+func DisableFailpoint(fp Failpoint) {
+	gFailpointLock.Lock()
+	defer gFailpointLock.Unlock()
+	delete(gFailpoints, fp)
+}
+
+// Returns whether the failpoint `fp` should fail at this time, decrementing
+// its remaining count.
+func failpointHit(fp Failpoint) bool {
+	gFailpointLock.Lock()
+	defer gFailpointLock.Unlock()
+	count, ok := gFailpoints[fp]
+	if !ok {
+		return false
+	}
+	if count > 0 {
+		count--
+		if count == 0 {
+			delete(gFailpoints, fp)
+		} else {
+			gFailpoints[fp] = count
+		}
+	}
+	return true
+}
+
+// Returns a freshly allocated error, as if returned by the C API, if `fp` is
+// armed, or nil otherwise.
+func failpointError(fp Failpoint) *C.wasmtime_error_t {
+	if !failpointHit(fp) {
+		return nil
+	}
+	cstr := C.CString("failpoint triggered: " + string(fp))
+	defer C.free(unsafe.Pointer(cstr))
+	return C.wasmtime_error_new(cstr)
+}
+
+// Returns a freshly allocated trap, as if raised by WebAssembly, if the
+// `FailpointTrap` failpoint is armed, or nil otherwise.
+func failpointTrap() *C.wasm_trap_t {
+	if !failpointHit(FailpointTrap) {
+		return nil
+	}
+	msg := "failpoint triggered: " + string(FailpointTrap)
+	ret := C.wasmtime_trap_new(C._GoStringPtr(msg), C._GoStringLen(msg))
+	runtime.KeepAlive(msg)
+	return ret
+}
+
+// Returns a corrupted copy of `bytes` if `fp` is armed, or `bytes` itself
+// otherwise.
+func failpointCorrupt(fp Failpoint, bytes []byte) []byte {
+	if !failpointHit(fp) {
+		return bytes
+	}
+	ret := make([]byte, len(bytes))
+	for i, b := range bytes {
+		ret[i] = ^b
+	}
+	return ret
+}
@@ -0,0 +1,21 @@
+//go:build !failpoints
+// +build !failpoints
+
+package wasmtime
+
+// See `failpoint.go` for what's going on here.
+
+// #include <wasmtime.h>
+import "C"
+
+func failpointError(fp Failpoint) *C.wasmtime_error_t {
+	return nil
+}
+
+func failpointTrap() *C.wasm_trap_t {
+	return nil
+}
+
+func failpointCorrupt(fp Failpoint, bytes []byte) []byte {
+	return bytes
+}
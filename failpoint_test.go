@@ -0,0 +1,80 @@
+//go:build failpoints
+// +build failpoints
+
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailpointAlloc(t *testing.T) {
+	store := NewStore(NewEngine())
+	EnableFailpoint(FailpointAlloc, 1)
+	_, err := NewMemory(store, NewMemoryType(1, false, 0))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failpoint triggered: alloc")
+
+	mem, err := NewMemory(store, NewMemoryType(1, false, 0))
+	require.NoError(t, err)
+
+	EnableFailpoint(FailpointAlloc, -1)
+	_, err = mem.Grow(store, 1)
+	require.Error(t, err)
+	_, err = NewGlobal(store, NewGlobalType(NewValType(KindI32), false), ValI32(0))
+	require.Error(t, err)
+	_, err = NewTable(store, NewTableType(NewValType(KindFuncref), 0, false, 0), ValFuncref(nil))
+	require.Error(t, err)
+	DisableFailpoint(FailpointAlloc)
+
+	_, err = mem.Grow(store, 1)
+	require.NoError(t, err)
+}
+
+func TestFailpointCompile(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`(module)`)
+	require.NoError(t, err)
+
+	EnableFailpoint(FailpointCompile, 2)
+	_, err = NewModule(engine, wasm)
+	require.Error(t, err)
+	require.Error(t, ModuleValidate(engine, wasm))
+	_, err = NewModule(engine, wasm)
+	require.NoError(t, err)
+}
+
+func TestFailpointDeserialize(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`(module)`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	bytes, err := module.Serialize()
+	require.NoError(t, err)
+
+	EnableFailpoint(FailpointDeserialize, 1)
+	_, err = NewModuleDeserialize(engine, bytes)
+	require.Error(t, err)
+	_, err = NewModuleDeserialize(engine, bytes)
+	require.NoError(t, err)
+}
+
+func TestFailpointTrap(t *testing.T) {
+	store := NewStore(NewEngine())
+	called := false
+	f := WrapFunc(store, func() { called = true })
+
+	EnableFailpoint(FailpointTrap, 1)
+	_, err := f.Call(store)
+	require.Error(t, err)
+	trap, ok := err.(*Trap)
+	require.True(t, ok)
+	require.Contains(t, trap.Message(), "failpoint triggered: trap")
+	require.False(t, called)
+
+	_, err = f.Call(store)
+	require.NoError(t, err)
+	require.True(t, called)
+}
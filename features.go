@@ -0,0 +1,128 @@
+package wasmtime
+
+import (
+	"sync"
+)
+
+// FeatureSet describes which WebAssembly proposals a module requires to be
+// enabled in order to be used, as reported by `DetectFeatures`.
+type FeatureSet struct {
+	// Whether the module uses shared memories or atomic instructions.
+	Threads bool
+	// Whether the module uses 128-bit SIMD.
+	SIMD bool
+	// Whether the module uses relaxed SIMD instructions. This also requires
+	// `SIMD`.
+	RelaxedSIMD bool
+	// Whether the module uses reference types, such as `externref`.
+	ReferenceTypes bool
+	// Whether the module uses bulk memory operations. This is also required
+	// by `ReferenceTypes` and `Threads`.
+	BulkMemory bool
+	// Whether the module has functions or blocks with multiple results.
+	MultiValue bool
+	// Whether the module has more than one memory.
+	MultiMemory bool
+	// Whether the module has 64-bit memories.
+	Memory64 bool
+}
+
+// Configure enables all of the features in this set on `cfg`.
+func (features FeatureSet) Configure(cfg *Config) {
+	if features.Threads {
+		cfg.SetWasmThreads(true)
+	}
+	if features.SIMD {
+		cfg.SetWasmSIMD(true)
+	}
+	if features.RelaxedSIMD {
+		cfg.SetWasmRelaxedSIMD(true)
+	}
+	if features.ReferenceTypes {
+		cfg.SetWasmReferenceTypes(true)
+	}
+	if features.BulkMemory {
+		cfg.SetWasmBulkMemory(true)
+	}
+	if features.MultiValue {
+		cfg.SetWasmMultiValue(true)
+	}
+	if features.MultiMemory {
+		cfg.SetWasmMultiMemory(true)
+	}
+	if features.Memory64 {
+		cfg.SetWasmMemory64(true)
+	}
+}
+
+// All features that `DetectFeatures` knows about, along with how to disable
+// them, and any features which depend on them, in a `Config`.
+var detectableFeatures = []struct {
+	mark    func(*FeatureSet)
+	disable func(*Config)
+}{
+	{func(f *FeatureSet) { f.Threads = true }, func(cfg *Config) { cfg.SetWasmThreads(false) }},
+	{func(f *FeatureSet) { f.SIMD = true }, func(cfg *Config) {
+		cfg.SetWasmSIMD(false)
+		cfg.SetWasmRelaxedSIMD(false)
+	}},
+	{func(f *FeatureSet) { f.RelaxedSIMD = true }, func(cfg *Config) { cfg.SetWasmRelaxedSIMD(false) }},
+	{func(f *FeatureSet) { f.ReferenceTypes = true }, func(cfg *Config) { cfg.SetWasmReferenceTypes(false) }},
+	{func(f *FeatureSet) { f.BulkMemory = true }, func(cfg *Config) {
+		cfg.SetWasmBulkMemory(false)
+		cfg.SetWasmReferenceTypes(false)
+		cfg.SetWasmThreads(false)
+	}},
+	{func(f *FeatureSet) { f.MultiValue = true }, func(cfg *Config) { cfg.SetWasmMultiValue(false) }},
+	{func(f *FeatureSet) { f.MultiMemory = true }, func(cfg *Config) { cfg.SetWasmMultiMemory(false) }},
+	{func(f *FeatureSet) { f.Memory64 = true }, func(cfg *Config) { cfg.SetWasmMemory64(false) }},
+}
+
+// Engines used by `DetectFeatures`, created on first use. The first has every
+// feature enabled and the rest line up with `detectableFeatures`, each having
+// that one feature disabled.
+var gFeatureEngines []*Engine
+var gFeatureEnginesOnce sync.Once
+
+func featureEngines() []*Engine {
+	gFeatureEnginesOnce.Do(func() {
+		newConfig := func() *Config {
+			cfg := NewConfig()
+			FeatureSet{true, true, true, true, true, true, true, true}.Configure(cfg)
+			cfg.SetParallelCompilation(false)
+			return cfg
+		}
+		gFeatureEngines = append(gFeatureEngines, NewEngineWithConfig(newConfig()))
+		for _, feature := range detectableFeatures {
+			cfg := newConfig()
+			feature.disable(cfg)
+			gFeatureEngines = append(gFeatureEngines, NewEngineWithConfig(cfg))
+		}
+	})
+	return gFeatureEngines
+}
+
+// DetectFeatures reports which WebAssembly proposals the binary `wasm` requires
+// to be enabled, without compiling it. This can be used to choose the
+// configuration of an `Engine` for a module, or to reject modules up front.
+//
+// Detection works by validating the module with each feature disabled in turn,
+// so a feature is reported if the module fails to validate without it.
+//
+// An error is returned if the module doesn't validate even with all supported
+// features enabled, which includes modules using proposals that the version of
+// wasmtime this package links against doesn't support, such as GC or tail
+// calls.
+func DetectFeatures(wasm []byte) (FeatureSet, error) {
+	var ret FeatureSet
+	engines := featureEngines()
+	if err := ModuleValidate(engines[0], wasm); err != nil {
+		return ret, err
+	}
+	for i, feature := range detectableFeatures {
+		if ModuleValidate(engines[i+1], wasm) != nil {
+			feature.mark(&ret)
+		}
+	}
+	return ret, nil
+}
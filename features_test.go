@@ -0,0 +1,53 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFeatures(t *testing.T) {
+	detect := func(wat string) FeatureSet {
+		wasm, err := Wat2Wasm(wat)
+		require.NoError(t, err)
+		features, err := DetectFeatures(wasm)
+		require.NoError(t, err)
+		return features
+	}
+
+	require.Equal(t, FeatureSet{}, detect(`(module (func (export "f") (result i32) i32.const 1))`))
+	require.Equal(t, FeatureSet{SIMD: true}, detect(`(module (func (result v128) v128.const i64x2 0 0))`))
+	require.Equal(t, FeatureSet{SIMD: true, RelaxedSIMD: true}, detect(`
+	(module
+	  (func (param v128 v128 v128) (result v128)
+	    (f32x4.relaxed_madd (local.get 0) (local.get 1) (local.get 2))))
+	`))
+	require.Equal(t, FeatureSet{Memory64: true}, detect(`(module (memory i64 1))`))
+	require.Equal(t, FeatureSet{MultiMemory: true}, detect(`(module (memory 1) (memory 1))`))
+	require.Equal(t, FeatureSet{MultiValue: true}, detect(`(module (func (result i32 i32) i32.const 1 i32.const 2))`))
+	require.Equal(t, FeatureSet{BulkMemory: true}, detect(`
+	(module
+	  (memory 1)
+	  (func (memory.copy (i32.const 0) (i32.const 1) (i32.const 1))))
+	`))
+	require.Equal(t, FeatureSet{ReferenceTypes: true, BulkMemory: true}, detect(`
+	(module (func (param externref)))
+	`))
+	require.Equal(t, FeatureSet{Threads: true, BulkMemory: true}, detect(`
+	(module (memory 1 1 shared))
+	`))
+
+	_, err := DetectFeatures([]byte{1, 2, 3})
+	require.Error(t, err)
+}
+
+func TestFeatureSetConfigure(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module (memory i64 1) (memory 1))`)
+	require.NoError(t, err)
+	features, err := DetectFeatures(wasm)
+	require.NoError(t, err)
+	config := NewConfig()
+	features.Configure(config)
+	_, err = NewModule(NewEngineWithConfig(config), wasm)
+	require.NoError(t, err)
+}
@@ -1,14 +1,5 @@
 package wasmtime
 
-// #cgo CFLAGS:-I${SRCDIR}/build/include
-// #cgo !windows LDFLAGS:-lwasmtime -lm -ldl -pthread
-// #cgo windows CFLAGS:-DWASM_API_EXTERN= -DWASI_API_EXTERN=
-// #cgo windows LDFLAGS:-lwasmtime -luserenv -lole32 -lntdll -lws2_32 -lkernel32 -lbcrypt
-// #cgo linux,amd64 LDFLAGS:-L${SRCDIR}/build/linux-x86_64
-// #cgo linux,arm64 LDFLAGS:-L${SRCDIR}/build/linux-aarch64
-// #cgo darwin,amd64 LDFLAGS:-L${SRCDIR}/build/macos-x86_64
-// #cgo darwin,arm64 LDFLAGS:-L${SRCDIR}/build/macos-aarch64
-// #cgo windows,amd64 LDFLAGS:-L${SRCDIR}/build/windows-x86_64
 // #include <wasm.h>
 import "C"
 import (
@@ -35,7 +26,8 @@ import (
 //
 // Each `ptr()` method has the basic structure of doing these steps:
 //
-// 1. First it reads the pointer value from the GC object
+// 1. First it reads the pointer value from the GC object, panicking if it's
+//    nil because the object was explicitly deallocated with `Close`
 // 2. Next it conditionally calls `runtime.GC()`, depending on build flags
 // 3. Finally it returns the original pointer value
 //
@@ -47,6 +39,12 @@ import (
 //
 // If anyone else has a better idea of what to handle all this it would be very
 // much appreciated :)
+//
+// Objects which own C memory also have a `Close` method which frees it right
+// away, for long-running programs where waiting on finalizers makes memory
+// usage unpredictable. `Close` clears the finalizer and the pointer so it's
+// idempotent, and the finalizer remains as a backstop for objects which are
+// never closed.
 
 // Convert a Go string into an owned `wasm_byte_vec_t`
 func stringToByteVec(s string) C.wasm_byte_vec_t {
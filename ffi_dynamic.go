@@ -0,0 +1,10 @@
+//go:build wasmtime_dynamic
+// +build wasmtime_dynamic
+
+package wasmtime
+
+// Links against a libwasmtime installed on the system, found with pkg-config,
+// instead of the static libraries in the `build` directory. See the README.
+
+// #cgo pkg-config: wasmtime
+import "C"
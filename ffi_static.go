@@ -0,0 +1,17 @@
+//go:build !wasmtime_dynamic
+// +build !wasmtime_dynamic
+
+package wasmtime
+
+// Links against the static libraries checked into the `build` directory.
+
+// #cgo CFLAGS:-I${SRCDIR}/build/include
+// #cgo !windows LDFLAGS:-lwasmtime -lm -ldl -pthread
+// #cgo windows CFLAGS:-DWASM_API_EXTERN= -DWASI_API_EXTERN=
+// #cgo windows LDFLAGS:-lwasmtime -luserenv -lole32 -lntdll -lws2_32 -lkernel32 -lbcrypt
+// #cgo linux,amd64 LDFLAGS:-L${SRCDIR}/build/linux-x86_64
+// #cgo linux,arm64 LDFLAGS:-L${SRCDIR}/build/linux-aarch64
+// #cgo darwin,amd64 LDFLAGS:-L${SRCDIR}/build/macos-x86_64
+// #cgo darwin,arm64 LDFLAGS:-L${SRCDIR}/build/macos-aarch64
+// #cgo windows,amd64 LDFLAGS:-L${SRCDIR}/build/windows-x86_64
+import "C"
@@ -4,6 +4,7 @@ package wasmtime
 import "C"
 import (
 	"errors"
+	"fmt"
 	"os"
 	"reflect"
 	"runtime"
@@ -64,7 +65,7 @@ func NewFunc(
 	)
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(ty)
-
+	recordStoreID(store, ret.store_id)
 	return mkFunc(ret)
 }
 
@@ -95,7 +96,15 @@ func goTrampolineNew(
 	var lastPanic interface{}
 	func() {
 		defer func() { lastPanic = recover() }()
-		results, trap = entry.callback(caller, params)
+		data.checkMemoryGrowth(caller)
+		if err := data.invokeCallHook(caller, CallHookCallingHost); err != nil {
+			trap = hookErrorToTrap(err)
+		} else {
+			results, trap = entry.callback(caller, params)
+			if err := data.invokeCallHook(caller, CallHookReturningFromHost); err != nil && trap == nil {
+				trap = hookErrorToTrap(err)
+			}
+		}
 		if trap != nil {
 			if trap._ptr == nil {
 				panic("returned an already-returned trap")
@@ -121,6 +130,7 @@ func goTrampolineNew(
 		runtime.SetFinalizer(trap, nil)
 		ret := trap.ptr()
 		trap._ptr = nil
+		data.trapPayload = trap.payload
 		return ret
 	}
 
@@ -148,6 +158,8 @@ func goTrampolineNew(
 //
 // `float64` - a wasm `f64`
 //
+// `[16]byte` - a wasm `v128`
+//
 // `*Caller` - information about the caller's instance
 //
 // `*Func` - a wasm `funcref`
@@ -179,6 +191,7 @@ func WrapFunc(
 	)
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(wasmTy)
+	recordStoreID(store, ret.store_id)
 	return mkFunc(ret)
 }
 
@@ -235,6 +248,10 @@ func typeToValType(ty reflect.Type) *ValType {
 	if ty == reflect.TypeOf(f) {
 		return NewValType(KindFuncref)
 	}
+	var v [16]byte
+	if ty == reflect.TypeOf(v) {
+		return NewValType(KindV128)
+	}
 	return NewValType(KindExternref)
 }
 
@@ -272,10 +289,19 @@ func goTrampolineWrap(
 	// Invoke the function, catching any panics to propagate later. Panics
 	// result in immediately returning a trap.
 	var results []reflect.Value
+	var hookTrap *Trap
 	var lastPanic interface{}
 	func() {
 		defer func() { lastPanic = recover() }()
+		data.checkMemoryGrowth(caller)
+		if err := data.invokeCallHook(caller, CallHookCallingHost); err != nil {
+			hookTrap = hookErrorToTrap(err)
+			return
+		}
 		results = entry.callback.Call(params)
+		if err := data.invokeCallHook(caller, CallHookReturningFromHost); err != nil {
+			hookTrap = hookErrorToTrap(err)
+		}
 	}()
 	if lastPanic != nil {
 		data.lastPanic = lastPanic
@@ -283,6 +309,13 @@ func goTrampolineWrap(
 		runtime.SetFinalizer(trap, nil)
 		return trap.ptr()
 	}
+	if hookTrap != nil {
+		runtime.SetFinalizer(hookTrap, nil)
+		ret := hookTrap.ptr()
+		hookTrap._ptr = nil
+		data.trapPayload = hookTrap.payload
+		return ret
+	}
 
 	// And now we write all the results into memory depending on the type
 	// of value that was returned.
@@ -298,6 +331,8 @@ func goTrampolineWrap(
 			*ptr = *ValF32(val).ptr()
 		case float64:
 			*ptr = *ValF64(val).ptr()
+		case [16]byte:
+			*ptr = *ValV128(val).ptr()
 		case *Func:
 			*ptr = *ValFuncref(val).ptr()
 		case *Trap:
@@ -309,6 +344,7 @@ func goTrampolineWrap(
 					data.lastPanic = "cannot return trap twice"
 					return nil
 				} else {
+					data.trapPayload = val.payload
 					return ret
 				}
 			}
@@ -322,12 +358,33 @@ func goTrampolineWrap(
 	return nil
 }
 
+// Converts an error returned from a call hook into a trap to raise in
+// WebAssembly, panicking like a host function returning the same trap twice
+// would if the error is a trap which was already raised.
+func hookErrorToTrap(err error) *Trap {
+	trap := errorToTrap(err)
+	if trap._ptr == nil {
+		panic("cannot return trap twice")
+	}
+	return trap
+}
+
+// Converts an error returned from a host callback into a trap to raise in
+// WebAssembly.
+func errorToTrap(err error) *Trap {
+	if trap, ok := err.(*Trap); ok {
+		return trap
+	}
+	return NewTrapWithPayload(err.Error(), err)
+}
+
 func mkFunc(val C.wasmtime_func_t) *Func {
 	return &Func{val}
 }
 
 // Type returns the type of this func
 func (f *Func) Type(store Storelike) *FuncType {
+	assertStore(store, f.val.store_id, "func")
 	ptr := C.wasmtime_func_type(store.Context(), &f.val)
 	runtime.KeepAlive(store)
 	return mkFuncType(ptr, nil)
@@ -347,6 +404,8 @@ func (f *Func) Type(store Storelike) *FuncType {
 //
 // `float64` - a wasm `f64`
 //
+// `[16]byte` - a wasm `v128`
+//
 // `Val` - correspond to a wasm value
 //
 // `*Func` - a wasm `funcref`
@@ -358,7 +417,8 @@ func (f *Func) Type(store Storelike) *FuncType {
 // 1. If the function returns successfully, then the `interface{}` return
 // argument will be the result of the function. If there were 0 results then
 // this value is `nil`. If there was one result then this is that result.
-// Otherwise if there were multiple results then `[]Val` is returned.
+// Otherwise if there were multiple results then `[]Val` is returned. Either
+// way `ScanResults` can copy the results into typed variables.
 //
 // 2. If this function invocation traps, then the returned `interface{}` value
 // will be `nil` and a non-`nil` `*Trap` will be returned with information
@@ -367,12 +427,17 @@ func (f *Func) Type(store Storelike) *FuncType {
 // 3. If a panic in Go ends up happening somewhere, then this function will
 // panic.
 func (f *Func) Call(store Storelike, args ...interface{}) (interface{}, error) {
+	if err := checkStore(store, f.val.store_id, "func"); err != nil {
+		return nil, err
+	}
 	ty := f.Type(store)
 	params := ty.Params()
 	if len(args) > len(params) {
 		return nil, errors.New("too many arguments provided")
 	}
-	paramVals := make([]C.wasmtime_val_t, len(args))
+	data := getDataInStore(store)
+	scratch := data.callFrame().scratch(len(args) + len(ty.Results()))
+	paramVals, resultVals := scratch[:len(args)], scratch[len(args):]
 	var externrefs []Val
 	for i, param := range args {
 		dst := &paramVals[i]
@@ -400,6 +465,8 @@ func (f *Func) Call(store Storelike, args ...interface{}) (interface{}, error) {
 		case float64:
 			dst.kind = C.WASMTIME_F64
 			C.go_wasmtime_val_f64_set(dst, C.double(val))
+		case [16]byte:
+			*dst = *ValV128(val).ptr()
 		case *Func:
 			dst.kind = C.WASMTIME_FUNCREF
 			C.go_wasmtime_val_funcref_set(dst, val.val)
@@ -414,8 +481,9 @@ func (f *Func) Call(store Storelike, args ...interface{}) (interface{}, error) {
 
 	}
 
-	resultVals := make([]C.wasmtime_val_t, len(ty.Results()))
-
+	if lift := data.applyCallGrowthLimit(store); lift != nil {
+		defer lift()
+	}
 	err := enterWasm(store, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
 		var paramsPtr *C.wasmtime_val_t
 		if len(paramVals) > 0 {
@@ -460,6 +528,140 @@ func (f *Func) Call(store Storelike, args ...interface{}) (interface{}, error) {
 
 }
 
+// ScanResults copies the `results` returned by `Func.Call` into the variables
+// pointed to by `dsts`, which makes it easier to consume functions with
+// multiple results:
+//
+//	results, err := f.Call(store, 1)
+//	var quot, rem int32
+//	err = wasmtime.ScanResults(results, &quot, &rem)
+//
+// Each of `dsts` must be a `*int32`, `*int64`, `*float32`, `*float64`,
+// `*[16]byte`, `**Func`, `*Val`, or `*interface{}`, which receives an
+// externref's value, and there must be exactly one for each result. Returns
+// an error, having copied nothing, if the number of results or their kinds
+// don't match.
+func ScanResults(results interface{}, dsts ...interface{}) error {
+	var vals []Val
+	switch results := results.(type) {
+	case nil:
+		// `Call` returns nil both for no results and for a single null
+		// externref, which only the number of `dsts` tells apart.
+		if len(dsts) == 1 {
+			vals = []Val{ValExternref(nil)}
+		}
+	case []Val:
+		vals = results
+	case int32:
+		vals = []Val{ValI32(results)}
+	case int64:
+		vals = []Val{ValI64(results)}
+	case float32:
+		vals = []Val{ValF32(results)}
+	case float64:
+		vals = []Val{ValF64(results)}
+	case [16]byte:
+		vals = []Val{ValV128(results)}
+	case *Func:
+		vals = []Val{ValFuncref(results)}
+	default:
+		vals = []Val{ValExternref(results)}
+	}
+	if len(vals) != len(dsts) {
+		return fmt.Errorf("%d results can't be scanned into %d values", len(vals), len(dsts))
+	}
+	for i, dst := range dsts {
+		kind := vals[i].Kind()
+		var want ValKind
+		switch dst.(type) {
+		case *int32:
+			want = KindI32
+		case *int64:
+			want = KindI64
+		case *float32:
+			want = KindF32
+		case *float64:
+			want = KindF64
+		case *[16]byte:
+			want = KindV128
+		case **Func:
+			want = KindFuncref
+		case *interface{}:
+			want = KindExternref
+		case *Val:
+			want = kind
+		default:
+			return fmt.Errorf("can't scan result %d into %T", i, dst)
+		}
+		if kind != want {
+			return fmt.Errorf("can't scan result %d of type %s into %T", i, kind, dst)
+		}
+	}
+	for i, dst := range dsts {
+		switch dst := dst.(type) {
+		case *int32:
+			*dst = vals[i].I32()
+		case *int64:
+			*dst = vals[i].I64()
+		case *float32:
+			*dst = vals[i].F32()
+		case *float64:
+			*dst = vals[i].F64()
+		case *[16]byte:
+			*dst = vals[i].V128()
+		case **Func:
+			*dst = vals[i].Funcref()
+		case *interface{}:
+			*dst = vals[i].Externref()
+		case *Val:
+			*dst = vals[i]
+		}
+	}
+	return nil
+}
+
+// CallInto invokes this function with `args`, writing its results to
+// `results`, which must have exactly as many elements as the function has
+// results.
+//
+// Unlike `Call` this doesn't look up the function's type or box its results
+// in an `interface{}`, and values are passed to wasmtime through scratch space
+// owned by `store`, so the only allocations made are for the results. Each
+// element of `results` is replaced with a new `Val`, leaving copies of the
+// values previously in `results` untouched. Use `GetTypedFunc0` and friends
+// for calls which don't allocate at all.
+//
+// Returns an error if the types of `args` or the length of `results` don't
+// match the function's type, or a `*Trap` if the function trapped.
+func (f *Func) CallInto(store Storelike, args []Val, results []Val) error {
+	if err := checkStore(store, f.val.store_id, "func"); err != nil {
+		return err
+	}
+	data := getDataInStore(store)
+	vals := data.callFrame().scratch(len(args) + len(results) + 1)
+	for i, arg := range args {
+		vals[i] = *arg.ptr()
+	}
+	params, rets := &vals[0], &vals[len(args)]
+
+	if lift := data.applyCallGrowthLimit(store); lift != nil {
+		defer lift()
+	}
+	err := enterWasm(store, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
+		return C.wasmtime_func_call(store.Context(), &f.val, params, C.size_t(len(args)), rets, C.size_t(len(results)), trap)
+	})
+	runtime.KeepAlive(store)
+	runtime.KeepAlive(args)
+	if err != nil {
+		return err
+	}
+
+	for i := range results {
+		results[i] = takeVal(&vals[len(args)+i])
+	}
+	return nil
+}
+
 // Implementation of the `AsExtern` interface for `Func`
 func (f *Func) AsExtern() C.wasmtime_extern_t {
 	ret := C.wasmtime_extern_t{kind: C.WASMTIME_EXTERN_FUNC}
@@ -491,6 +693,31 @@ func (c *Caller) GetExport(name string) *Extern {
 
 }
 
+// GetMemory gets an exported memory from the caller's module by `name`, which
+// can be any of its memories when the multi-memory proposal is in use.
+//
+// May return `nil` if the export doesn't exist, if it's not a memory, if there
+// isn't a caller, etc.
+func (c *Caller) GetMemory(name string) *Memory {
+	m := c.GetExport(name)
+	if m == nil {
+		return nil
+	}
+	return m.Memory()
+}
+
+// Data returns the user-defined data attached to the store this caller
+// belongs to with `Store.SetData`, or nil if no data has been attached.
+func (c *Caller) Data() interface{} {
+	return getDataInStore(c).userData
+}
+
+// SetData attaches arbitrary user-defined `data` to the store this caller
+// belongs to, replacing any previously attached value.
+func (c *Caller) SetData(data interface{}) {
+	getDataInStore(c).userData = data
+}
+
 // Implementation of the `Storelike` interface for `Caller`.
 func (c *Caller) Context() *C.wasmtime_context_t {
 	if c.ptr == nil {
@@ -499,6 +726,67 @@ func (c *Caller) Context() *C.wasmtime_context_t {
 	return C.wasmtime_caller_context(c.ptr)
 }
 
+// Calls `f` with `args` on behalf of the host function `caller` belongs to,
+// returning its `nresults` results, or a trap to propagate from the host
+// function.
+func (c *Caller) callFunc(f *Func, args []Val, nresults int) ([]Val, *Trap) {
+	params := make([]C.wasmtime_val_t, len(args)+1)
+	for i, arg := range args {
+		params[i] = *arg.ptr()
+	}
+	results := make([]C.wasmtime_val_t, nresults+1)
+	var trap *C.wasm_trap_t
+	err := C.wasmtime_func_call(c.Context(), &f.val, &params[0], C.size_t(len(args)), &results[0], C.size_t(nresults), &trap)
+	runtime.KeepAlive(c)
+	runtime.KeepAlive(args)
+	data := getDataInStore(c)
+	payload := data.trapPayload
+	data.trapPayload = nil
+	if trap != nil {
+		ret := mkTrap(trap)
+		ret.payload = payload
+		return nil, ret
+	}
+	if err != nil {
+		ret := errorToTrap(mkError(err))
+		ret.payload = payload
+		return nil, ret
+	}
+	ret := make([]Val, nresults)
+	for i := range ret {
+		ret[i] = takeVal(&results[i])
+	}
+	return ret, nil
+}
+
+// Scratch space for an invocation of WebAssembly from Go, reused by every
+// invocation at the same depth of a store's stack so that calls don't need to
+// allocate.
+//
+// The trap is allocated separately from the frame since cgo doesn't allow it
+// to be passed to C alongside the Go pointer to `vals`.
+type callFrame struct {
+	trap **C.wasm_trap_t
+	vals []C.wasmtime_val_t
+}
+
+// Returns the frame for an invocation of WebAssembly at the current depth.
+func (data *storeData) callFrame() *callFrame {
+	for len(data.callFrames) <= data.wasmDepth {
+		data.callFrames = append(data.callFrames, &callFrame{trap: new(*C.wasm_trap_t)})
+	}
+	return data.callFrames[data.wasmDepth]
+}
+
+// Returns `n` values of scratch space, valid until the frame's invocation
+// returns.
+func (frame *callFrame) scratch(n int) []C.wasmtime_val_t {
+	if cap(frame.vals) < n {
+		frame.vals = make([]C.wasmtime_val_t, n)
+	}
+	return frame.vals[:n]
+}
+
 // Shim function that's expected to wrap any invocations of WebAssembly from Go
 // itself.
 //
@@ -513,9 +801,33 @@ func enterWasm(store Storelike, wasm func(**C.wasm_trap_t) *C.wasmtime_error_t)
 	// Load the internal `storeData` that our `store` references, which is
 	// used for handling panics which we are going to use here.
 	data := getDataInStore(store)
+	if err := data.invokeCallHook(store, CallHookCallingWasm); err != nil {
+		return err
+	}
 
-	var trap *C.wasm_trap_t
-	err := wasm(&trap)
+	var endQuota func()
+	if data.quota != nil && data.wasmDepth == 0 {
+		endQuota = data.quota.beginCall(store, data)
+	}
+	// The trap is written to a slot owned by the frame rather than to a
+	// local so that it doesn't need to be moved to the heap on every call.
+	frame := data.callFrame()
+	*frame.trap = failpointTrap()
+	var err *C.wasmtime_error_t
+	if *frame.trap == nil {
+		data.wasmDepth++
+		err = wasm(frame.trap)
+		data.wasmDepth--
+	}
+	trap := *frame.trap
+	*frame.trap = nil
+	payload := data.trapPayload
+	data.trapPayload = nil
+	if endQuota != nil {
+		endQuota()
+	}
+	data.checkMemoryGrowth(store)
+	hookErr := data.invokeCallHook(store, CallHookReturningFromWasm)
 
 	// Take ownership of any returned values to ensure we properly run
 	// destructors for them.
@@ -523,9 +835,12 @@ func enterWasm(store Storelike, wasm func(**C.wasm_trap_t) *C.wasmtime_error_t)
 	var wrappedError error
 	if trap != nil {
 		wrappedTrap = mkTrap(trap)
+		wrappedTrap.payload = payload
 	}
 	if err != nil {
-		wrappedError = mkError(err)
+		e := mkError(err)
+		e.payload = payload
+		wrappedError = e
 	}
 
 	// Check to see if wasm panicked, and if it did then we need to
@@ -540,9 +855,13 @@ func enterWasm(store Storelike, wasm func(**C.wasm_trap_t) *C.wasmtime_error_t)
 	// If there wasn't a panic then we determine whether to return the trap
 	// or the error.
 	if wrappedTrap != nil {
+		data.engine.recordEvent(EngineEventTrap, data.id, wrappedTrap.Message())
 		return wrappedTrap
 	}
-	return wrappedError
+	if wrappedError != nil {
+		return wrappedError
+	}
+	return hookErr
 }
 
 /// Refraction-Networking changes begin here
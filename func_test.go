@@ -431,3 +431,148 @@ func TestPanicTraps(t *testing.T) {
 	require.NotNil(t, lastPanic, "expected a panic")
 	require.True(t, correctPanic, "wasm was resumed after initial panic")
 }
+
+func TestFuncCallerData(t *testing.T) {
+	store := NewStore(NewEngine())
+	store.SetData(int32(1))
+	f := WrapFunc(store, func(c *Caller) int32 {
+		prev := c.Data().(int32)
+		c.SetData(prev + 1)
+		return prev
+	})
+	result, err := f.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result)
+	require.Equal(t, int32(2), store.Data())
+}
+
+func TestFuncCallInto(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (func (export "swap") (param i32 i64) (result i64 i32)
+	      local.get 1
+	      local.get 0)
+	    (func (export "ref") (param externref) (result externref)
+	      local.get 0)
+	    (func (export "trap") unreachable))
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+
+	swap := instance.GetFunc(store, "swap")
+	args := []Val{ValI32(1), ValI64(2)}
+	results := make([]Val, 2)
+	require.NoError(t, swap.CallInto(store, args, results))
+	require.Equal(t, int64(2), results[0].I64())
+	require.Equal(t, int32(1), results[1].I32())
+
+	// results are new values, so copies of previous results are unaffected
+	prev := results[0]
+	require.NoError(t, swap.CallInto(store, []Val{ValI32(3), ValI64(4)}, results))
+	require.Equal(t, int64(4), results[0].I64())
+	require.Equal(t, int64(2), prev.I64())
+
+	require.Error(t, swap.CallInto(store, args, results[:1]))
+	require.Error(t, swap.CallInto(store, []Val{ValI64(1), ValI64(2)}, results))
+
+	ref := instance.GetFunc(store, "ref")
+	require.NoError(t, ref.CallInto(store, []Val{ValExternref("hello")}, results[:1]))
+	require.Equal(t, "hello", results[0].Externref())
+
+	err = instance.GetFunc(store, "trap").CallInto(store, nil, nil)
+	require.Error(t, err)
+	_, ok := err.(*Trap)
+	require.True(t, ok)
+}
+
+func TestFuncWrapMultiValue(t *testing.T) {
+	store := NewStore(NewEngine())
+	divmod := WrapFunc(store, func(a, b int32) (int32, int32, *Trap) {
+		if b == 0 {
+			return 0, 0, NewTrap("division by zero")
+		}
+		return a / b, a % b, nil
+	})
+	require.Len(t, divmod.Type(store).Results(), 2)
+
+	results, err := divmod.Call(store, 7, 2)
+	require.NoError(t, err)
+	var quot, rem int32
+	require.NoError(t, ScanResults(results, &quot, &rem))
+	require.Equal(t, int32(3), quot)
+	require.Equal(t, int32(1), rem)
+
+	_, err = divmod.Call(store, 7, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "division by zero")
+}
+
+func TestScanResults(t *testing.T) {
+	var i32 int32
+	var i64 int64
+	var f32 float32
+	var f64 float64
+	var ref interface{}
+	var val Val
+	require.NoError(t, ScanResults([]Val{ValI32(1), ValI64(2), ValF32(3), ValF64(4), ValExternref("x"), ValI32(5)}, &i32, &i64, &f32, &f64, &ref, &val))
+	require.Equal(t, int32(1), i32)
+	require.Equal(t, int64(2), i64)
+	require.Equal(t, float32(3), f32)
+	require.Equal(t, float64(4), f64)
+	require.Equal(t, "x", ref)
+	require.Equal(t, int32(5), val.I32())
+
+	require.NoError(t, ScanResults(int64(6), &i64))
+	require.Equal(t, int64(6), i64)
+	require.NoError(t, ScanResults(nil))
+	ref = "not nil"
+	require.NoError(t, ScanResults(nil, &ref))
+	require.Nil(t, ref)
+
+	require.Error(t, ScanResults([]Val{ValI32(1)}))
+	require.Error(t, ScanResults([]Val{ValI32(1), ValI32(2)}, &i32, &i64))
+	require.Equal(t, int32(1), i32, "nothing copied on error")
+	require.Error(t, ScanResults(int32(1), &i32, &i32))
+	require.Error(t, ScanResults(int32(1), new(string)))
+}
+
+func TestFuncV128(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "" "double" (func $double (param v128) (result v128)))
+	    (func (export "add") (param v128 v128) (result v128)
+	      (i32x4.add (local.get 0) (local.get 1)))
+	    (func (export "double") (param v128) (result v128)
+	      (call $double (local.get 0))))
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	double := WrapFunc(store, func(v [16]byte) [16]byte {
+		for i := range v {
+			v[i] *= 2
+		}
+		return v
+	})
+	require.Equal(t, KindV128, double.Type(store).Params()[0].Kind())
+	instance, err := NewInstance(store, module, []AsExtern{double})
+	require.NoError(t, err)
+
+	a := [16]byte{1, 0, 0, 0, 2}
+	b := [16]byte{3, 0, 0, 0, 4, 0, 0, 0, 5}
+	result, err := instance.GetFunc(store, "add").Call(store, a, ValV128(b))
+	require.NoError(t, err)
+	require.Equal(t, [16]byte{4, 0, 0, 0, 6, 0, 0, 0, 5}, result)
+
+	result, err = instance.GetFunc(store, "double").Call(store, b)
+	require.NoError(t, err)
+	var doubled [16]byte
+	require.NoError(t, ScanResults(result, &doubled))
+	require.Equal(t, [16]byte{6, 0, 0, 0, 8, 0, 0, 0, 10}, doubled)
+	require.Equal(t, "v128", KindV128.String())
+}
@@ -48,10 +48,25 @@ func mkFuncType(ptr *C.wasm_functype_t, owner interface{}) *FuncType {
 
 func (ty *FuncType) ptr() *C.wasm_functype_t {
 	ret := ty._ptr
+	if ret == nil {
+		panic("FuncType used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this type without waiting for it to be garbage collected.
+// It has no effect on types which belong to another object, such as those
+// returned from the methods of other types.
+func (ty *FuncType) Close() {
+	if ty._ptr == nil || ty._owner != nil {
+		return
+	}
+	runtime.SetFinalizer(ty, nil)
+	C.wasm_functype_delete(ty._ptr)
+	ty._ptr = nil
+}
+
 func (ty *FuncType) owner() interface{} {
 	if ty._owner != nil {
 		return ty._owner
@@ -19,19 +19,22 @@ func NewGlobal(
 	val Val,
 ) (*Global, error) {
 	var ret C.wasmtime_global_t
-	err := C.wasmtime_global_new(
-		store.Context(),
-		ty.ptr(),
-		val.ptr(),
-		&ret,
-	)
+	err := failpointError(FailpointAlloc)
+	if err == nil {
+		err = C.wasmtime_global_new(
+			store.Context(),
+			ty.ptr(),
+			val.ptr(),
+			&ret,
+		)
+	}
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(ty)
 	runtime.KeepAlive(val)
 	if err != nil {
 		return nil, mkError(err)
 	}
-
+	recordStoreID(store, ret.store_id)
 	return mkGlobal(ret), nil
 }
 
@@ -41,6 +44,7 @@ func mkGlobal(val C.wasmtime_global_t) *Global {
 
 // Type returns the type of this global
 func (g *Global) Type(store Storelike) *GlobalType {
+	assertStore(store, g.val.store_id, "global")
 	ptr := C.wasmtime_global_type(store.Context(), &g.val)
 	runtime.KeepAlive(store)
 	return mkGlobalType(ptr, nil)
@@ -48,6 +52,7 @@ func (g *Global) Type(store Storelike) *GlobalType {
 
 // Get gets the value of this global
 func (g *Global) Get(store Storelike) Val {
+	assertStore(store, g.val.store_id, "global")
 	ret := C.wasmtime_val_t{}
 	C.wasmtime_global_get(store.Context(), &g.val, &ret)
 	runtime.KeepAlive(store)
@@ -56,6 +61,9 @@ func (g *Global) Get(store Storelike) Val {
 
 // Set sets the value of this global
 func (g *Global) Set(store Storelike, val Val) error {
+	if err := checkStore(store, g.val.store_id, "global"); err != nil {
+		return err
+	}
 	err := C.wasmtime_global_set(store.Context(), &g.val, val.ptr())
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(val)
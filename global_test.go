@@ -20,3 +20,15 @@ func TestGlobal(t *testing.T) {
 	err = g.Set(store, ValI64(200))
 	require.Error(t, err, "should fail to set global")
 }
+
+func TestGlobalV128(t *testing.T) {
+	store := NewStore(NewEngine())
+	ty := NewGlobalType(NewValType(KindV128), true)
+	require.Equal(t, KindV128, ty.Content().Kind())
+	g, err := NewGlobal(store, ty, ValV128([16]byte{1, 2, 3}))
+	require.NoError(t, err)
+	require.Equal(t, [16]byte{1, 2, 3}, g.Get(store).V128())
+	require.NoError(t, g.Set(store, ValV128([16]byte{15: 0xff})))
+	require.Equal(t, [16]byte{15: 0xff}, g.Get(store).V128())
+	require.Error(t, g.Set(store, ValI32(1)))
+}
@@ -36,10 +36,25 @@ func mkGlobalType(ptr *C.wasm_globaltype_t, owner interface{}) *GlobalType {
 
 func (ty *GlobalType) ptr() *C.wasm_globaltype_t {
 	ret := ty._ptr
+	if ret == nil {
+		panic("GlobalType used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this type without waiting for it to be garbage collected.
+// It has no effect on types which belong to another object, such as those
+// returned from the methods of other types.
+func (ty *GlobalType) Close() {
+	if ty._ptr == nil || ty._owner != nil {
+		return
+	}
+	runtime.SetFinalizer(ty, nil)
+	C.wasm_globaltype_delete(ty._ptr)
+	ty._ptr = nil
+}
+
 func (ty *GlobalType) owner() interface{} {
 	if ty._owner != nil {
 		return ty._owner
@@ -0,0 +1,84 @@
+package wasmtime
+
+import (
+	"fmt"
+)
+
+// DefineEventBridge defines functions in `module` which let guests subscribe
+// their exported functions to named events emitted by the host with
+// `Store.EmitEvent`:
+//
+//   - `subscribe: [event_ptr i32, event_len i32, export_ptr i32, export_len i32] -> [i32]`
+//     subscribes the caller's export named by the UTF-8 string at
+//     `export_ptr` to the event named by the string at `event_ptr`. The
+//     export must be a function of type `[payload_len i32] -> []`. Returns 0
+//     on success and 1 if the export doesn't exist or has the wrong type.
+//   - `event_payload: [ptr i32, len i32] -> [i32]` copies up to `len` bytes
+//     of the payload of the event currently being delivered to `ptr`, and
+//     returns the full length of the payload. This is only meaningful while
+//     a callback is running.
+//
+// Strings and payloads are read from and written to the caller's exported
+// memory named "memory".
+//
+// Returns an error if shadowing is disabled and the names are already defined.
+func (l *Linker) DefineEventBridge(module string) error {
+	err := l.FuncWrap(module, "subscribe", func(caller *Caller, eventPtr, eventLen, exportPtr, exportLen int32) (int32, *Trap) {
+		event, trap := guestRegion(caller, eventPtr, eventLen)
+		if trap != nil {
+			return 0, trap
+		}
+		export, trap := guestRegion(caller, exportPtr, exportLen)
+		if trap != nil {
+			return 0, trap
+		}
+		item := caller.GetExport(string(export))
+		if item == nil || item.Func() == nil {
+			return 1, nil
+		}
+		callback := item.Func()
+		callbackTy := NewFuncType([]*ValType{NewValType(KindI32)}, []*ValType{})
+		if !sameFuncType(callback.Type(caller), callbackTy) {
+			return 1, nil
+		}
+		store := getDataInStore(caller)
+		if store.eventCallbacks == nil {
+			store.eventCallbacks = make(map[string][]*Func)
+		}
+		store.eventCallbacks[string(event)] = append(store.eventCallbacks[string(event)], callback)
+		return 0, nil
+	})
+	if err != nil {
+		return err
+	}
+	return l.FuncWrap(module, "event_payload", func(caller *Caller, ptr, n int32) (int32, *Trap) {
+		dst, trap := guestRegion(caller, ptr, n)
+		if trap != nil {
+			return 0, trap
+		}
+		payload := getDataInStore(caller).eventPayload
+		copy(dst, payload)
+		return int32(len(payload)), nil
+	})
+}
+
+// EmitEvent invokes every guest function subscribed to the event `name`,
+// through the functions defined by `Linker.DefineEventBridge`, in the order
+// they subscribed, passing them `payload`.
+//
+// All subscribers are invoked even if some of them fail, and the first error
+// is returned.
+func (store *Store) EmitEvent(name string, payload []byte) error {
+	data := getDataInStore(store)
+	prev := data.eventPayload
+	data.eventPayload = payload
+	defer func() { data.eventPayload = prev }()
+
+	var ret error
+	for _, callback := range data.eventCallbacks[name] {
+		if _, err := callback.Call(store, int32(len(payload))); err != nil && ret == nil {
+			ret = fmt.Errorf("event %q: %w", name, err)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,91 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBridge(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "events" "subscribe" (func $subscribe (param i32 i32 i32 i32) (result i32)))
+	  (import "events" "event_payload" (func $event_payload (param i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  (data (i32.const 0) "tick")
+	  (data (i32.const 8) "on_tick")
+	  (data (i32.const 16) "missing")
+	  (data (i32.const 24) "bad_type")
+	  (global $ticks (mut i32) (i32.const 0))
+	  (global $last (mut i32) (i32.const 0))
+	  (func (export "init") (result i32 i32 i32)
+	    (call $subscribe (i32.const 0) (i32.const 4) (i32.const 8) (i32.const 7))
+	    (call $subscribe (i32.const 0) (i32.const 4) (i32.const 16) (i32.const 7))
+	    (call $subscribe (i32.const 0) (i32.const 4) (i32.const 24) (i32.const 8)))
+	  ;; counts ticks and saves the first byte of the payload
+	  (func (export "on_tick") (param $len i32)
+	    (global.set $ticks (i32.add (global.get $ticks) (i32.const 1)))
+	    (drop (call $event_payload (i32.const 100) (i32.const 1)))
+	    (global.set $last (i32.load8_u (i32.const 100))))
+	  (func (export "bad_type") (param i64))
+	  (func (export "ticks") (result i32) (global.get $ticks))
+	  (func (export "last") (result i32) (global.get $last))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineEventBridge("events"))
+	store := NewStore(engine)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+
+	results, err := instance.GetFunc(store, "init").Call(store)
+	require.NoError(t, err)
+	statuses := results.([]Val)
+	require.Equal(t, int32(0), statuses[0].I32())
+	require.Equal(t, int32(1), statuses[1].I32())
+	require.Equal(t, int32(1), statuses[2].I32())
+
+	require.NoError(t, store.EmitEvent("tick", []byte("x")))
+	require.NoError(t, store.EmitEvent("tick", []byte("yz")))
+	require.NoError(t, store.EmitEvent("other", nil))
+	ticks, err := instance.GetFunc(store, "ticks").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), ticks)
+	last, err := instance.GetFunc(store, "last").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32('y'), last)
+}
+
+func TestEventBridgeError(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "events" "subscribe" (func $subscribe (param i32 i32 i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  (data (i32.const 0) "go")
+	  (data (i32.const 8) "fail")
+	  (func (export "init") (drop (call $subscribe (i32.const 0) (i32.const 2) (i32.const 8) (i32.const 4))))
+	  (func (export "fail") (param i32) unreachable)
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineEventBridge("events"))
+	store := NewStore(engine)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "init").Call(store)
+	require.NoError(t, err)
+
+	err = store.EmitEvent("go", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `event "go"`)
+	var trap *Trap
+	require.ErrorAs(t, err, &trap)
+}
@@ -0,0 +1,86 @@
+package wasmtime
+
+import (
+	"errors"
+)
+
+// HeapLayout describes the layout of a guest's linear memory, as reported by
+// `Instance.HeapLayout`.
+//
+// Modules linked with `wasm-ld`, as produced by most toolchains targeting
+// WebAssembly, place static data at the start of memory followed by the stack,
+// which grows downwards from `HeapBase`, and finally the heap which extends to
+// the end of memory.
+type HeapLayout struct {
+	// The end of the static data, from the `__data_end` global.
+	DataEnd uint64
+	// The start of the heap, from the `__heap_base` global.
+	HeapBase uint64
+	// The current value of the `__stack_pointer` global, which toolchains
+	// don't export by default. Only valid if `HasStackPointer` is true.
+	StackPointer    uint64
+	HasStackPointer bool
+	// The current size, in bytes, of the exported `memory`.
+	MemorySize uint64
+}
+
+// HeapSize returns the number of bytes currently available to the heap, from
+// `HeapBase` to the end of memory.
+func (layout *HeapLayout) HeapSize() uint64 {
+	if layout.MemorySize < layout.HeapBase {
+		return 0
+	}
+	return layout.MemorySize - layout.HeapBase
+}
+
+// StackUsed returns the number of bytes of stack currently in use, which is
+// only known if the stack pointer is exported and the stack is placed between
+// the static data and the heap.
+func (layout *HeapLayout) StackUsed() (uint64, bool) {
+	sp := layout.StackPointer
+	if !layout.HasStackPointer || sp < layout.DataEnd || sp > layout.HeapBase {
+		return 0, false
+	}
+	return layout.HeapBase - sp, true
+}
+
+// HeapLayout inspects the exports of this instance to determine the layout of
+// its linear memory, which can help when debugging guests that run out of
+// memory.
+//
+// This relies on the instance exporting a memory named `memory` along with the
+// `__data_end` and `__heap_base` globals, as modules linked by `wasm-ld` do.
+// An error is returned if any of those are missing.
+func (i *Instance) HeapLayout(store Storelike) (*HeapLayout, error) {
+	memory := i.GetExport(store, "memory")
+	if memory == nil || memory.Memory() == nil {
+		return nil, errors.New("instance does not export a memory named `memory`")
+	}
+	layout := &HeapLayout{MemorySize: uint64(memory.Memory().DataSize(store))}
+
+	var ok bool
+	if layout.DataEnd, ok = i.addressGlobal(store, "__data_end"); !ok {
+		return nil, errors.New("instance does not export the `__data_end` global")
+	}
+	if layout.HeapBase, ok = i.addressGlobal(store, "__heap_base"); !ok {
+		return nil, errors.New("instance does not export the `__heap_base` global")
+	}
+	layout.StackPointer, layout.HasStackPointer = i.addressGlobal(store, "__stack_pointer")
+	return layout, nil
+}
+
+// Returns the value of the exported global `name` if it holds an address.
+func (i *Instance) addressGlobal(store Storelike, name string) (uint64, bool) {
+	export := i.GetExport(store, name)
+	if export == nil || export.Global() == nil {
+		return 0, false
+	}
+	val := export.Global().Get(store)
+	switch val.Kind() {
+	case KindI32:
+		return uint64(uint32(val.I32())), true
+	case KindI64:
+		return uint64(val.I64()), true
+	}
+	return 0, false
+}
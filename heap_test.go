@@ -0,0 +1,53 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeapLayout(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	(module
+	  (memory (export "memory") 2)
+	  (global (export "__data_end") i32 (i32.const 1024))
+	  (global (export "__heap_base") i32 (i32.const 9216))
+	  (global $sp (export "__stack_pointer") (mut i32) (i32.const 9216))
+	  (func (export "push") (param i32)
+	    (global.set $sp (i32.sub (global.get $sp) (local.get 0))))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+
+	layout, err := instance.HeapLayout(store)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1024), layout.DataEnd)
+	require.Equal(t, uint64(9216), layout.HeapBase)
+	require.Equal(t, uint64(2*65536), layout.MemorySize)
+	require.Equal(t, uint64(2*65536-9216), layout.HeapSize())
+	used, ok := layout.StackUsed()
+	require.True(t, ok)
+	require.Equal(t, uint64(0), used)
+
+	_, err = instance.GetFunc(store, "push").Call(store, 100)
+	require.NoError(t, err)
+	layout, err = instance.HeapLayout(store)
+	require.NoError(t, err)
+	used, ok = layout.StackUsed()
+	require.True(t, ok)
+	require.Equal(t, uint64(100), used)
+
+	wasm, err = Wat2Wasm(`(module (memory (export "memory") 1))`)
+	require.NoError(t, err)
+	module, err = NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err = NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = instance.HeapLayout(store)
+	require.Error(t, err)
+}
@@ -0,0 +1,183 @@
+package wasmtime
+
+// #include <wasmtime.h>
+import "C"
+import (
+	"errors"
+	"fmt"
+)
+
+// A function import of an instance created with `Linker.InstantiateDeferred`
+// which forwards calls to whichever function it was last linked to.
+type deferredImport struct {
+	ty     *FuncType
+	target *Func
+}
+
+// Key of a deferred import within a store.
+type deferredImportKey struct {
+	instance     C.wasmtime_instance_t
+	module, name string
+}
+
+// InstantiateDeferred instantiates `module` like `Instantiate`, except that
+// function imports which aren't defined in this linker don't cause an error.
+// Instead each of them is bound to a placeholder which can later be linked to
+// an export of another instance in the same store with
+// `Store.LinkExportToImport`, which allows graphs of modules to be assembled
+// at runtime without rebuilding the linker for every instance.
+//
+// Calling a placeholder which hasn't been linked traps. Imports other than
+// functions must still be defined in this linker.
+func (l *Linker) InstantiateDeferred(store Storelike, module *Module) (*Instance, error) {
+	if err := l.checkWasiShims(store, module); err != nil {
+		return nil, err
+	}
+	var imports []AsExtern
+	deferred := make(map[linkerName]*deferredImport)
+	for _, imp := range module.Imports() {
+		name := ""
+		if imp.Name() != nil {
+			name = *imp.Name()
+		}
+		if item := l.Get(store, imp.Module(), name); item != nil {
+			imports = append(imports, item)
+			continue
+		}
+		ty := imp.Type().FuncType()
+		if ty == nil {
+			return nil, fmt.Errorf("unknown import: `%s::%s` has not been defined", imp.Module(), name)
+		}
+		importModule := imp.Module()
+		d := &deferredImport{ty: ty}
+		imports = append(imports, NewFunc(store, ty, func(caller *Caller, args []Val) ([]Val, *Trap) {
+			if d.target == nil {
+				return nil, NewTrap(fmt.Sprintf("import `%s::%s` has not been linked", importModule, name))
+			}
+			return caller.callFunc(d.target, args, len(d.ty.Results()))
+		}))
+		deferred[linkerName{importModule, name}] = d
+	}
+
+	instance, err := NewInstance(store, module, imports)
+	if err != nil {
+		return nil, err
+	}
+	data := getDataInStore(store)
+	if data.deferredImports == nil {
+		data.deferredImports = make(map[deferredImportKey]*deferredImport)
+	}
+	for n, d := range deferred {
+		data.deferredImports[deferredImportKey{instance.val, n.module, n.name}] = d
+	}
+	return instance, nil
+}
+
+// LinkExportToImport links the function `export` of instance `from` to the
+// import `module`/`name` of instance `to`, so that calls `to` makes to that
+// import are forwarded to `from`. Both instances must belong to this store
+// and `to` must have been created with `Linker.InstantiateDeferred`, leaving
+// the import undefined.
+//
+// An import can be linked again to replace the function it forwards to. An
+// error is returned if the export or import don't exist, or if their types
+// don't match.
+func (store *Store) LinkExportToImport(from *Instance, export string, to *Instance, module, name string) error {
+	d := getDataInStore(store).deferredImports[deferredImportKey{to.val, module, name}]
+	if d == nil {
+		return fmt.Errorf("instance has no deferred import `%s::%s`", module, name)
+	}
+	f := from.GetFunc(store, export)
+	if f == nil {
+		return fmt.Errorf("instance has no exported function `%s`", export)
+	}
+	if !sameFuncType(f.Type(store), d.ty) {
+		return errors.New("type of the export doesn't match the type of the import")
+	}
+	d.target = f
+	return nil
+}
+
+// DefineUnknownImportsAsTraps defines every function import of `module`
+// which isn't defined in this linker as a function which traps when called,
+// so that modules with optional or unused imports can be instantiated. Only
+// calling a missing import fails, rather than instantiation.
+//
+// Imports other than functions must still be defined in this linker.
+func (l *Linker) DefineUnknownImportsAsTraps(module *Module) error {
+	return l.defineUnknownImports(module, "DefineUnknownImportsAsTraps", func(importModule, name string, ty *FuncType) func(*Caller, []Val) ([]Val, *Trap) {
+		return func(*Caller, []Val) ([]Val, *Trap) {
+			return nil, NewTrap(fmt.Sprintf("unknown import: `%s::%s` has not been defined", importModule, name))
+		}
+	})
+}
+
+// DefineUnknownImportsAsDefaultValues is like `DefineUnknownImportsAsTraps`,
+// except that the missing functions do nothing and return zero values: 0 for
+// numbers and null for references.
+func (l *Linker) DefineUnknownImportsAsDefaultValues(module *Module) error {
+	return l.defineUnknownImports(module, "DefineUnknownImportsAsDefaultValues", func(importModule, name string, ty *FuncType) func(*Caller, []Val) ([]Val, *Trap) {
+		results := make([]Val, len(ty.Results()))
+		for i, result := range ty.Results() {
+			results[i] = zeroVal(result.Kind())
+		}
+		return func(*Caller, []Val) ([]Val, *Trap) {
+			return results, nil
+		}
+	})
+}
+
+// Defines every function import of `module` missing from this linker with
+// the function returned by `stub`.
+func (l *Linker) defineUnknownImports(module *Module, by string, stub func(importModule, name string, ty *FuncType) func(*Caller, []Val) ([]Val, *Trap)) error {
+	for _, imp := range module.Imports() {
+		name := ""
+		if imp.Name() != nil {
+			name = *imp.Name()
+		}
+		ty := imp.Type().FuncType()
+		if _, ok := l.defined[linkerName{imp.Module(), name}]; ok || ty == nil {
+			continue
+		}
+		if err := l.FuncNew(imp.Module(), name, ty, stub(imp.Module(), name, ty)); err != nil {
+			return err
+		}
+		l.recordDefined(imp.Module(), name, by)
+	}
+	return nil
+}
+
+// Returns the zero value of `kind`, which is null for references.
+func zeroVal(kind ValKind) Val {
+	switch kind {
+	case KindI64:
+		return ValI64(0)
+	case KindF32:
+		return ValF32(0)
+	case KindF64:
+		return ValF64(0)
+	case KindFuncref:
+		return ValFuncref(nil)
+	case KindExternref:
+		return ValExternref(nil)
+	case KindV128:
+		return ValV128([16]byte{})
+	}
+	return ValI32(0)
+}
+
+// Returns whether `a` and `b` have the same parameters and results.
+func sameFuncType(a, b *FuncType) bool {
+	sameKinds := func(a, b []*ValType) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i].Kind() != b[i].Kind() {
+				return false
+			}
+		}
+		return true
+	}
+	return sameKinds(a.Params(), b.Params()) && sameKinds(a.Results(), b.Results())
+}
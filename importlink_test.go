@@ -0,0 +1,119 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkExportToImport(t *testing.T) {
+	engine := NewEngine()
+	store := NewStore(engine)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.FuncWrap("host", "base", func() int32 { return 100 }))
+
+	newModule := func(wat string) *Module {
+		wasm, err := Wat2Wasm(wat)
+		require.NoError(t, err)
+		module, err := NewModule(engine, wasm)
+		require.NoError(t, err)
+		return module
+	}
+
+	consumer := newModule(`
+	(module
+	  (import "host" "base" (func $base (result i32)))
+	  (import "env" "f" (func $f (param i32) (result i32)))
+	  (func (export "run") (param i32) (result i32)
+	    (i32.add (call $base) (call $f (local.get 0)))))
+	`)
+	b, err := linker.InstantiateDeferred(store, consumer)
+	require.NoError(t, err)
+	run := b.GetFunc(store, "run")
+
+	_, err = run.Call(store, 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "import `env::f` has not been linked")
+
+	provider := newModule(`
+	(module
+	  (func (export "double") (param i32) (result i32)
+	    (i32.mul (local.get 0) (i32.const 2)))
+	  (func (export "square") (param i32) (result i32)
+	    (i32.mul (local.get 0) (local.get 0)))
+	  (func (export "wrong") (param i64)))
+	`)
+	a, err := NewInstance(store, provider, []AsExtern{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.LinkExportToImport(a, "double", b, "env", "f"))
+	result, err := run.Call(store, 5)
+	require.NoError(t, err)
+	require.Equal(t, int32(110), result)
+
+	require.NoError(t, store.LinkExportToImport(a, "square", b, "env", "f"))
+	result, err = run.Call(store, 5)
+	require.NoError(t, err)
+	require.Equal(t, int32(125), result)
+
+	require.Error(t, store.LinkExportToImport(a, "wrong", b, "env", "f"))
+	require.Error(t, store.LinkExportToImport(a, "missing", b, "env", "f"))
+	require.Error(t, store.LinkExportToImport(a, "double", b, "host", "base"))
+	require.Error(t, store.LinkExportToImport(a, "double", a, "env", "f"))
+
+	_, err = linker.InstantiateDeferred(store, newModule(`(module (import "env" "m" (memory 1)))`))
+	require.Error(t, err)
+}
+
+func TestLinkerDefineUnknownImports(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "host" "log" (func $log (param i32)))
+	  (import "host" "optional" (func $optional (result i32 i64 f32 f64 externref funcref)))
+	  (import "host" "known" (func $known (result i32)))
+	  (func (export "log") (call $log (i32.const 1)))
+	  (func (export "optional") (result i32 i64 f32 f64 externref funcref) (call $optional))
+	  (func (export "known") (result i32) (call $known))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	linker := NewLinker(engine)
+	require.NoError(t, linker.FuncWrap("host", "known", func() int32 { return 7 }))
+	_, err = linker.Instantiate(NewStore(engine), module)
+	require.Error(t, err)
+
+	require.NoError(t, linker.DefineUnknownImportsAsTraps(module))
+	store := NewStore(engine)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	result, err := instance.GetFunc(store, "known").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(7), result)
+	_, err = instance.GetFunc(store, "log").Call(store)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown import: `host::log` has not been defined")
+	// everything is defined now, so this is a no-op
+	require.NoError(t, linker.DefineUnknownImportsAsTraps(module))
+
+	linker = NewLinker(engine)
+	require.NoError(t, linker.FuncWrap("host", "known", func() int32 { return 7 }))
+	require.NoError(t, linker.DefineUnknownImportsAsDefaultValues(module))
+	store = NewStore(engine)
+	instance, err = linker.Instantiate(store, module)
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "log").Call(store)
+	require.NoError(t, err)
+	results, err := instance.GetFunc(store, "optional").Call(store)
+	require.NoError(t, err)
+	vals := results.([]Val)
+	require.Equal(t, int32(0), vals[0].I32())
+	require.Equal(t, int64(0), vals[1].I64())
+	require.Equal(t, float32(0), vals[2].F32())
+	require.Equal(t, float64(0), vals[3].F64())
+	require.Nil(t, vals[4].Externref())
+	require.Nil(t, vals[5].Funcref())
+}
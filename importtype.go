@@ -42,10 +42,25 @@ func mkImportType(ptr *C.wasm_importtype_t, owner interface{}) *ImportType {
 
 func (ty *ImportType) ptr() *C.wasm_importtype_t {
 	ret := ty._ptr
+	if ret == nil {
+		panic("ImportType used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this type without waiting for it to be garbage collected.
+// It has no effect on types which belong to another object, such as those
+// returned from the methods of other types.
+func (ty *ImportType) Close() {
+	if ty._ptr == nil || ty._owner != nil {
+		return
+	}
+	runtime.SetFinalizer(ty, nil)
+	C.wasm_importtype_delete(ty._ptr)
+	ty._ptr = nil
+}
+
 func (ty *ImportType) owner() interface{} {
 	if ty._owner != nil {
 		return ty._owner
@@ -25,6 +25,15 @@ func NewInstance(store Storelike, module *Module, imports []AsExtern) (*Instance
 	importsRaw := make([]C.wasmtime_extern_t, len(imports))
 	for i, imp := range imports {
 		importsRaw[i] = imp.AsExtern()
+		if err := checkStore(store, externStoreID(&importsRaw[i]), "import"); err != nil {
+			return nil, err
+		}
+	}
+	data := getDataInStore(store)
+	if data.quota != nil {
+		if err := data.quota.reserveInstance(data); err != nil {
+			return nil, err
+		}
 	}
 	var val C.wasmtime_instance_t
 	err := enterWasm(store, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
@@ -46,8 +55,16 @@ func NewInstance(store Storelike, module *Module, imports []AsExtern) (*Instance
 	runtime.KeepAlive(imports)
 	runtime.KeepAlive(importsRaw)
 	if err != nil {
+		if data.quota != nil {
+			data.quota.releaseInstance(data)
+		}
 		return nil, err
 	}
+	recordStoreID(store, val.store_id)
+	data.addInstance(store, val, module)
+	if data.quota != nil {
+		data.quota.charge(store, data, 0)
+	}
 	return mkInstance(val), nil
 }
 
@@ -64,6 +81,7 @@ type externList struct {
 // Each export is returned as a `*Extern` and lines up with the exports list of
 // the associated `Module`.
 func (instance *Instance) Exports(store Storelike) []*Extern {
+	assertStore(store, instance.val.store_id, "instance")
 	ret := make([]*Extern, 0)
 	var name *C.char
 	var name_len C.size_t
@@ -86,10 +104,36 @@ func (instance *Instance) Exports(store Storelike) []*Extern {
 	return ret
 }
 
+// Returns the names of all of the exports of this instance.
+func (i *Instance) exportNames(store Storelike) []string {
+	var ret []string
+	var name *C.char
+	var nameLen C.size_t
+	for n := 0; ; n++ {
+		var item C.wasmtime_extern_t
+		ok := C.wasmtime_instance_export_nth(
+			store.Context(),
+			&i.val,
+			C.size_t(n),
+			&name,
+			&nameLen,
+			&item,
+		)
+		if !ok {
+			break
+		}
+		ret = append(ret, C.GoStringN(name, C.int(nameLen)))
+		C.wasmtime_extern_delete(&item)
+	}
+	runtime.KeepAlive(store)
+	return ret
+}
+
 // GetExport attempts to find an export on this instance by `name`
 //
 // May return `nil` if this instance has no export named `name`
 func (i *Instance) GetExport(store Storelike, name string) *Extern {
+	assertStore(store, i.val.store_id, "instance")
 	var item C.wasmtime_extern_t
 	ok := C.wasmtime_instance_export_get(
 		store.Context(),
@@ -117,3 +161,47 @@ func (i *Instance) GetFunc(store Storelike, name string) *Func {
 	}
 	return f.Func()
 }
+
+// GetMemory attempts to find a memory on this instance by `name`.
+//
+// May return `nil` if this instance has no memory named `name`,
+// it is not a memory, etc.
+func (i *Instance) GetMemory(store Storelike, name string) *Memory {
+	m := i.GetExport(store, name)
+	if m == nil {
+		return nil
+	}
+	return m.Memory()
+}
+
+// Memories returns all of the memories exported by this instance, keyed by
+// export name.
+//
+// With the multi-memory proposal an instance may export several memories, for
+// example to keep scratch space shared with the host separate from the guest's
+// private memory.
+func (i *Instance) Memories(store Storelike) map[string]*Memory {
+	assertStore(store, i.val.store_id, "instance")
+	ret := make(map[string]*Memory)
+	var name *C.char
+	var nameLen C.size_t
+	for n := 0; ; n++ {
+		var item C.wasmtime_extern_t
+		ok := C.wasmtime_instance_export_nth(
+			store.Context(),
+			&i.val,
+			C.size_t(n),
+			&name,
+			&nameLen,
+			&item,
+		)
+		if !ok {
+			break
+		}
+		if memory := mkExtern(&item).Memory(); memory != nil {
+			ret[C.GoStringN(name, C.int(nameLen))] = memory
+		}
+	}
+	runtime.KeepAlive(store)
+	return ret
+}
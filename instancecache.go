@@ -0,0 +1,214 @@
+package wasmtime
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+)
+
+// InstanceCacheOptions configures an `InstanceCache`, see
+// `Engine.InstanceCache`.
+type InstanceCacheOptions struct {
+	// The maximum number of modules to keep instances of. When exceeded, the
+	// instances of the least recently used module are dropped. Zero means
+	// no limit.
+	MaxModules int
+	// The maximum number of idle instances kept for each module. Zero means
+	// one.
+	MaxIdle int
+	// The linker used to instantiate modules, which must only contain
+	// store-independent definitions such as those from `FuncNew`, `FuncWrap`
+	// and `DefineWasi`. If nil, modules can't have imports.
+	Linker *Linker
+	// Creates the store for each instance, for example to attach data or a
+	// call hook. If nil, `NewStore` is used.
+	NewStore func(*Engine) *Store
+	// Configures the store of an instance each time it's checked out with
+	// `Get`, for example to set up WASI, fuel, an epoch deadline or resource
+	// limits for the request, since these are cleared with `Store.Reset`
+	// whenever the instance is returned with `Put`. If it returns an error
+	// the instance is dropped and `Get` fails.
+	Prepare func(*Store) error
+}
+
+// InstanceCache keeps warm instances of modules, each in its own `Store`, so
+// that they can be reused for calls instead of being instantiated each time.
+//
+// Instances are checked out with `Get` and returned with `Put`, which resets
+// the contents of their exported memories and the values of their exported
+// mutable globals to what they were right after instantiation, and resets
+// their store with `Store.Reset`. State which isn't exported, such as
+// unexported globals and tables, is not reset, so this is only suitable for
+// modules which keep all of their mutable state in exported memories and
+// globals.
+//
+// An `InstanceCache` is safe to use from multiple goroutines, but each
+// `CachedInstance` must only be used by one goroutine at a time.
+type InstanceCache struct {
+	engine *Engine
+	opts   InstanceCacheOptions
+
+	mu sync.Mutex
+	// Idle instances of each module, and the modules in order of most
+	// recent use.
+	idle    map[*Module]*list.Element
+	modules list.List
+}
+
+type instanceCacheEntry struct {
+	module *Module
+	idle   []*CachedInstance
+}
+
+// CachedInstance is an instance checked out from an `InstanceCache`.
+type CachedInstance struct {
+	Store    *Store
+	Instance *Instance
+
+	module   *Module
+	memories []memorySnapshot
+	globals  []globalSnapshot
+}
+
+type memorySnapshot struct {
+	memory *Memory
+	data   []byte
+}
+
+type globalSnapshot struct {
+	global *Global
+	val    Val
+}
+
+// InstanceCache creates a new `InstanceCache` for modules compiled with this
+// engine.
+func (engine *Engine) InstanceCache(opts InstanceCacheOptions) *InstanceCache {
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 1
+	}
+	return &InstanceCache{
+		engine: engine,
+		opts:   opts,
+		idle:   make(map[*Module]*list.Element),
+	}
+}
+
+// Get checks out an instance of `module`, reusing an idle one if there is one
+// and instantiating it otherwise.
+func (c *InstanceCache) Get(module *Module) (*CachedInstance, error) {
+	c.mu.Lock()
+	entry := c.touch(module)
+	var ret *CachedInstance
+	if n := len(entry.idle); n > 0 {
+		ret = entry.idle[n-1]
+		entry.idle = entry.idle[:n-1]
+	}
+	c.mu.Unlock()
+	if ret == nil {
+		var err error
+		if ret, err = c.instantiate(module); err != nil {
+			return nil, err
+		}
+	}
+	if c.opts.Prepare != nil {
+		if err := c.opts.Prepare(ret.Store); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Put returns an instance previously checked out with `Get` to the cache,
+// resetting its state so it can be reused.
+//
+// If the instance's memories have grown since it was created they can't be
+// reset, and the instance is dropped instead.
+func (c *InstanceCache) Put(instance *CachedInstance) {
+	if !instance.reset() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.touch(instance.module)
+	if len(entry.idle) < c.opts.MaxIdle {
+		entry.idle = append(entry.idle, instance)
+	}
+}
+
+// Idle returns the number of idle instances currently kept for `module`.
+func (c *InstanceCache) Idle(module *Module) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.idle[module]; ok {
+		return len(elem.Value.(*instanceCacheEntry).idle)
+	}
+	return 0
+}
+
+// Marks `module` as the most recently used, evicting the least recently used
+// modules if there are too many. Must be called with `c.mu` held.
+func (c *InstanceCache) touch(module *Module) *instanceCacheEntry {
+	if elem, ok := c.idle[module]; ok {
+		c.modules.MoveToFront(elem)
+		return elem.Value.(*instanceCacheEntry)
+	}
+	entry := &instanceCacheEntry{module: module}
+	c.idle[module] = c.modules.PushFront(entry)
+	for c.opts.MaxModules > 0 && c.modules.Len() > c.opts.MaxModules {
+		oldest := c.modules.Back()
+		c.modules.Remove(oldest)
+		delete(c.idle, oldest.Value.(*instanceCacheEntry).module)
+	}
+	return entry
+}
+
+func (c *InstanceCache) instantiate(module *Module) (*CachedInstance, error) {
+	var store *Store
+	if c.opts.NewStore != nil {
+		store = c.opts.NewStore(c.engine)
+	} else {
+		store = NewStore(c.engine)
+	}
+	var instance *Instance
+	var err error
+	if c.opts.Linker != nil {
+		instance, err = c.opts.Linker.Instantiate(store, module)
+	} else if len(module.Imports()) > 0 {
+		err = errors.New("instance cache has no linker to satisfy the module's imports")
+	} else {
+		instance, err = NewInstance(store, module, []AsExtern{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &CachedInstance{Store: store, Instance: instance, module: module}
+	for _, export := range instance.Exports(store) {
+		if memory := export.Memory(); memory != nil {
+			data := append([]byte(nil), memory.UnsafeData(store)...)
+			ret.memories = append(ret.memories, memorySnapshot{memory, data})
+		} else if global := export.Global(); global != nil && global.Type(store).Mutable() {
+			ret.globals = append(ret.globals, globalSnapshot{global, global.Get(store)})
+		}
+	}
+	return ret, nil
+}
+
+// Restores the state recorded after instantiation, returning whether it was
+// possible to do so.
+func (instance *CachedInstance) reset() bool {
+	for _, snapshot := range instance.memories {
+		data := snapshot.memory.UnsafeData(instance.Store)
+		if len(data) != len(snapshot.data) {
+			return false
+		}
+		copy(data, snapshot.data)
+	}
+	for _, snapshot := range instance.globals {
+		if err := snapshot.global.Set(instance.Store, snapshot.val); err != nil {
+			return false
+		}
+	}
+	instance.Store.Reset()
+	return true
+}
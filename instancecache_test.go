@@ -0,0 +1,86 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceCache(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (memory (export "memory") 1)
+	  (global $count (export "count") (mut i32) (i32.const 0))
+	  (data (i32.const 0) "\05")
+	  (func (export "bump") (result i32)
+	    (global.set $count (i32.add (global.get $count) (i32.const 1)))
+	    (i32.store8 (i32.const 0) (i32.add (i32.load8_u (i32.const 0)) (i32.const 1)))
+	    (i32.add (global.get $count) (i32.load8_u (i32.const 0))))
+	  (func (export "grow") (drop (memory.grow (i32.const 1))))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	other, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	cache := engine.InstanceCache(InstanceCacheOptions{MaxModules: 1})
+	bump := func(instance *CachedInstance) int32 {
+		result, err := instance.Instance.GetFunc(instance.Store, "bump").Call(instance.Store)
+		require.NoError(t, err)
+		return result.(int32)
+	}
+
+	first, err := cache.Get(module)
+	require.NoError(t, err)
+	require.Equal(t, int32(7), bump(first))
+	require.Equal(t, int32(9), bump(first))
+	cache.Put(first)
+	require.Equal(t, 1, cache.Idle(module))
+
+	// the idle instance is reused with its state reset
+	second, err := cache.Get(module)
+	require.NoError(t, err)
+	require.Same(t, first, second)
+	require.Equal(t, 0, cache.Idle(module))
+	require.Equal(t, int32(7), bump(second))
+
+	// instances whose memory grew can't be reset
+	_, err = second.Instance.GetFunc(second.Store, "grow").Call(second.Store)
+	require.NoError(t, err)
+	cache.Put(second)
+	require.Equal(t, 0, cache.Idle(module))
+
+	// using another module evicts the least recently used one
+	third, err := cache.Get(module)
+	require.NoError(t, err)
+	cache.Put(third)
+	require.Equal(t, 1, cache.Idle(module))
+	fourth, err := cache.Get(other)
+	require.NoError(t, err)
+	cache.Put(fourth)
+	require.Equal(t, 0, cache.Idle(module))
+	require.Equal(t, 1, cache.Idle(other))
+
+	// stores are prepared on every checkout
+	prepared := 0
+	cache = engine.InstanceCache(InstanceCacheOptions{Prepare: func(store *Store) error {
+		prepared++
+		return nil
+	}})
+	fifth, err := cache.Get(module)
+	require.NoError(t, err)
+	cache.Put(fifth)
+	_, err = cache.Get(module)
+	require.NoError(t, err)
+	require.Equal(t, 2, prepared)
+
+	wasm, err = Wat2Wasm(`(module (import "" "f" (func)))`)
+	require.NoError(t, err)
+	module, err = NewModule(engine, wasm)
+	require.NoError(t, err)
+	_, err = cache.Get(module)
+	require.Error(t, err)
+}
@@ -4,8 +4,12 @@ package wasmtime
 // #include "shims.h"
 import "C"
 import (
+	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
+	"unicode"
 )
 
 // Linker implements a wasmtime Linking module, which can link instantiated modules together.
@@ -14,11 +18,24 @@ import (
 type Linker struct {
 	_ptr   *C.wasmtime_linker_t
 	Engine *Engine
+
+	// Names defined in this linker along with the method which defined them,
+	// used to diagnose conflicting definitions.
+	defined   map[linkerName]string
+	shadowing bool
+
+	// The original WASI functions which the wrappers defined by
+	// `DefineWasiWithOptions` forward to, created when first needed.
+	wasiShims *wasiShims
+}
+
+type linkerName struct {
+	module, name string
 }
 
 func NewLinker(engine *Engine) *Linker {
 	ptr := C.wasmtime_linker_new(engine.ptr())
-	linker := &Linker{_ptr: ptr, Engine: engine}
+	linker := &Linker{_ptr: ptr, Engine: engine, defined: make(map[linkerName]string)}
 	runtime.SetFinalizer(linker, func(linker *Linker) {
 		C.wasmtime_linker_delete(linker._ptr)
 	})
@@ -27,21 +44,44 @@ func NewLinker(engine *Engine) *Linker {
 
 func (l *Linker) ptr() *C.wasmtime_linker_t {
 	ret := l._ptr
+	if ret == nil {
+		panic("Linker used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this linker without waiting for it to be garbage
+// collected. Instances created with it remain usable.
+func (l *Linker) Close() {
+	if l._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(l, nil)
+	C.wasmtime_linker_delete(l._ptr)
+	l._ptr = nil
+}
+
 // AllowShadowing configures whether names can be redefined after they've already been defined
 // in this linker.
 func (l *Linker) AllowShadowing(allow bool) {
 	C.wasmtime_linker_allow_shadowing(l.ptr(), C.bool(allow))
 	runtime.KeepAlive(l)
+	l.shadowing = allow
+}
+
+// Records that `module`/`name` was defined in this linker by `by`.
+func (l *Linker) recordDefined(module, name, by string) {
+	l.defined[linkerName{module, name}] = by
 }
 
 // Define defines a new item in this linker with the given module/name pair. Returns
 // an error if shadowing is disallowed and the module/name is already defined.
 func (l *Linker) Define(store Storelike, module, name string, item AsExtern) error {
 	extern := item.AsExtern()
+	if err := checkStore(store, externStoreID(&extern), "item"); err != nil {
+		return err
+	}
 	err := C.wasmtime_linker_define(
 		l.ptr(),
 		store.Context(),
@@ -57,6 +97,7 @@ func (l *Linker) Define(store Storelike, module, name string, item AsExtern) err
 	runtime.KeepAlive(item)
 	runtime.KeepAlive(store)
 	if err == nil {
+		l.recordDefined(module, name, "Define")
 		return nil
 	}
 
@@ -95,6 +136,7 @@ func (l *Linker) FuncNew(module, name string, ty *FuncType, f func(*Caller, []Va
 	runtime.KeepAlive(name)
 	runtime.KeepAlive(ty)
 	if err == nil {
+		l.recordDefined(module, name, "FuncNew")
 		return nil
 	}
 
@@ -128,16 +170,108 @@ func (l *Linker) FuncWrap(module, name string, f interface{}) error {
 	runtime.KeepAlive(name)
 	runtime.KeepAlive(ty)
 	if err == nil {
+		l.recordDefined(module, name, "FuncWrap")
 		return nil
 	}
 
 	return mkError(err)
 }
 
+// DefineStruct defines every exported method of `v` as a function in
+// `module`, as if each was passed to `FuncWrap`, so that a host API can be
+// written as a Go type rather than a list of functions. Parameters and
+// results are mapped to wasm types as described by `WrapFunc`, including a
+// `*Caller` parameter receiving the calling instance, except that only
+// interface types such as `interface{}` become a wasm `externref`.
+//
+// Functions are named after their methods in snake case, so a method named
+// `ReadFile` is defined as `read_file`. Like `FuncWrap` the functions aren't
+// tied to any store, so the same value is shared by every store instantiated
+// with this linker.
+//
+// Returns an error if `v` is nil or has no exported methods, if a method has
+// a parameter or result type with no wasm mapping (such as a `string`, slice
+// or struct), or if shadowing is disabled and a name is already defined.
+func (l *Linker) DefineStruct(module string, v interface{}) error {
+	val := reflect.ValueOf(v)
+	if !val.IsValid() || (val.Kind() == reflect.Ptr && val.IsNil()) {
+		return fmt.Errorf("cannot define the methods of a nil %T", v)
+	}
+	if val.NumMethod() == 0 {
+		return fmt.Errorf("%T has no exported methods to define", v)
+	}
+	for i := 0; i < val.NumMethod(); i++ {
+		method := val.Type().Method(i)
+		if err := checkStructMethod(val.Method(i).Type()); err != nil {
+			return fmt.Errorf("cannot define %T.%s: %v", v, method.Name, err)
+		}
+	}
+	for i := 0; i < val.NumMethod(); i++ {
+		name := snakeCase(val.Type().Method(i).Name)
+		if err := l.FuncWrap(module, name, val.Method(i).Interface()); err != nil {
+			return err
+		}
+		l.recordDefined(module, name, "DefineStruct")
+	}
+	return nil
+}
+
+// Checks that every parameter and result of a `DefineStruct` method has a
+// wasm type, rather than letting `WrapFunc` turn it into an `externref`.
+func checkStructMethod(ty reflect.Type) error {
+	var caller *Caller
+	var trap *Trap
+	for i := 0; i < ty.NumIn(); i++ {
+		if in := ty.In(i); in != reflect.TypeOf(caller) && !isWasmType(in) {
+			return fmt.Errorf("parameter %d has type %v with no wasm mapping", i, in)
+		}
+	}
+	for i := 0; i < ty.NumOut(); i++ {
+		out := ty.Out(i)
+		if i == ty.NumOut()-1 && out == reflect.TypeOf(trap) {
+			continue
+		}
+		if !isWasmType(out) {
+			return fmt.Errorf("result %d has type %v with no wasm mapping", i, out)
+		}
+	}
+	return nil
+}
+
+// Returns whether `ty` is mapped to a wasm type by `typeToValType`, where
+// only interface types are taken as an `externref`.
+func isWasmType(ty reflect.Type) bool {
+	if ty.Kind() == reflect.Interface {
+		return true
+	}
+	return typeToValType(ty).Kind() != KindExternref
+}
+
+// Converts a Go identifier such as `ReadHTTPHeader` to snake case, as in
+// `read_http_header`.
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			startsWord := i > 0 && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+			if startsWord {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // DefineInstance defines all exports of an instance provided under the module name provided.
 //
 // Returns an error if shadowing is disabled and names are already defined.
 func (l *Linker) DefineInstance(store Storelike, module string, instance *Instance) error {
+	if err := checkStore(store, instance.val.store_id, "instance"); err != nil {
+		return err
+	}
 	err := C.wasmtime_linker_define_instance(
 		l.ptr(),
 		store.Context(),
@@ -149,12 +283,56 @@ func (l *Linker) DefineInstance(store Storelike, module string, instance *Instan
 	runtime.KeepAlive(module)
 	runtime.KeepAlive(store)
 	if err == nil {
+		for _, name := range instance.exportNames(store) {
+			l.recordDefined(module, name, "DefineInstance")
+		}
 		return nil
 	}
 
 	return mkError(err)
 }
 
+// Alias defines `asModule`/`asName` to be the item already defined as
+// `module`/`name`, so that guests which import it under another name can be
+// linked against it, for example when composing a plugin with a standard
+// library module whose exports the plugin imports under its own names.
+//
+// As with `Define`, the alias belongs to `store`. Returns an error if
+// `module`/`name` isn't defined, or if shadowing is disabled and the alias
+// is already defined.
+func (l *Linker) Alias(store Storelike, module, name, asModule, asName string) error {
+	item := l.Get(store, module, name)
+	if item == nil {
+		return fmt.Errorf("cannot alias %s::%s, it isn't defined", module, name)
+	}
+	if err := l.Define(store, asModule, asName, item); err != nil {
+		return err
+	}
+	l.recordDefined(asModule, asName, "Alias")
+	return nil
+}
+
+// AliasModule is like `Alias`, but defines every item defined in `module`
+// under the same name in `asModule`.
+func (l *Linker) AliasModule(store Storelike, module, asModule string) error {
+	var names []string
+	for defined := range l.defined {
+		if defined.module == module {
+			names = append(names, defined.name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("cannot alias module %s, nothing is defined in it", module)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := l.Alias(store, module, name, asModule, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DefineModule defines automatic instantiations of the module in this linker.
 //
 // The `name` of the module is the name within the linker, and the `module` is
@@ -175,20 +353,286 @@ func (l *Linker) DefineModule(store Storelike, name string, module *Module) erro
 	runtime.KeepAlive(module)
 	runtime.KeepAlive(store)
 	if err == nil {
+		for _, export := range module.Exports() {
+			l.recordDefined(name, export.Name(), "DefineModule")
+		}
 		return nil
 	}
 
 	return mkError(err)
 }
 
+// The functions defined by `Linker.DefineWasi` in each of the modules it
+// defines.
+var wasiFuncs = map[string][]string{
+	"wasi_snapshot_preview1": append(wasiUnstableFuncs, "sock_accept"),
+	"wasi_unstable":          wasiUnstableFuncs,
+}
+
+var wasiUnstableFuncs = []string{
+	"args_get", "args_sizes_get", "environ_get", "environ_sizes_get",
+	"clock_res_get", "clock_time_get", "fd_advise", "fd_allocate", "fd_close",
+	"fd_datasync", "fd_fdstat_get", "fd_fdstat_set_flags", "fd_fdstat_set_rights",
+	"fd_filestat_get", "fd_filestat_set_size", "fd_filestat_set_times", "fd_pread",
+	"fd_prestat_get", "fd_prestat_dir_name", "fd_pwrite", "fd_read", "fd_readdir",
+	"fd_renumber", "fd_seek", "fd_sync", "fd_tell", "fd_write",
+	"path_create_directory", "path_filestat_get", "path_filestat_set_times",
+	"path_link", "path_open", "path_readlink", "path_remove_directory",
+	"path_rename", "path_symlink", "path_unlink_file", "poll_oneoff", "proc_exit",
+	"proc_raise", "sched_yield", "random_get", "sock_recv", "sock_send",
+	"sock_shutdown",
+}
+
+// LinkerConflict describes a name which is already defined in a `Linker`.
+type LinkerConflict struct {
+	Module string
+	Name   string
+	// The `Linker` method which defined the name, for example "FuncWrap".
+	DefinedBy string
+}
+
+// WasiConflictError is returned by `Linker.DefineWasi` when shadowing is
+// disabled and some of the names that WASI defines are already defined.
+type WasiConflictError struct {
+	Conflicts []LinkerConflict
+}
+
+func (e *WasiConflictError) Error() string {
+	names := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		names[i] = fmt.Sprintf("%s::%s (defined by %s)", conflict.Module, conflict.Name, conflict.DefinedBy)
+	}
+	return "cannot define WASI, names are already defined: " + strings.Join(names, ", ")
+}
+
 // DefineWasi links a WASI module into this linker, ensuring that all exported functions
 // are available for linking.
 //
-// Returns an error if shadowing is disabled and names are already defined.
+// Returns an error if shadowing is disabled and names are already defined. If
+// the names were defined through this `Linker` then the error is a
+// `*WasiConflictError` listing them, and nothing is defined. Use
+// `DefineWasiOverride` to replace the conflicting definitions instead.
 func (l *Linker) DefineWasi() error {
+	if !l.shadowing {
+		var conflicts []LinkerConflict
+		for _, module := range []string{"wasi_snapshot_preview1", "wasi_unstable"} {
+			for _, name := range wasiFuncs[module] {
+				if by, ok := l.defined[linkerName{module, name}]; ok {
+					conflicts = append(conflicts, LinkerConflict{module, name, by})
+				}
+			}
+		}
+		if len(conflicts) > 0 {
+			return &WasiConflictError{Conflicts: conflicts}
+		}
+	}
+	return l.defineWasi()
+}
+
+// DefineWasiOverride is the same as `DefineWasi` except that any names which
+// are already defined are replaced with WASI's definitions, regardless of
+// whether shadowing is allowed.
+func (l *Linker) DefineWasiOverride() error {
+	if !l.shadowing {
+		C.wasmtime_linker_allow_shadowing(l.ptr(), true)
+		defer C.wasmtime_linker_allow_shadowing(l.ptr(), false)
+	}
+	err := l.defineWasi()
+	runtime.KeepAlive(l)
+	return err
+}
+
+// WasiLinkOptions configures `Linker.DefineWasiWithOptions`.
+type WasiLinkOptions struct {
+	// Additional module names to define WASI functions under, mapped to the
+	// WASI module whose functions they get, either "wasi_snapshot_preview1"
+	// or "wasi_unstable". This links guests which import WASI under a
+	// custom name without defining every function by hand.
+	//
+	// `proc_exit` under an alias ends execution with a trap rather than an
+	// `*Error` with an `ExitStatus`, since wasmtime only reports exit
+	// statuses from its own definitions.
+	Aliases map[string]string
+	// Whether names which are already defined are replaced, as with
+	// `DefineWasiOverride`, rather than causing an error.
+	Override bool
+
+	// The functionality this package adds to wasmtime's WASI functions that
+	// guests linked by this linker get. Each wraps the WASI functions
+	// involved in Go host functions, all of them in the case of
+	// `RecordLatencies`, which makes calling them slower, and makes them
+	// trigger the hooks installed with `Store.SetCallHook`.
+	//
+	// `ClockScale` honours `WasiConfig.SetClockScale`, `ScratchLimits`
+	// enforces the limits of `WasiConfig.PreopenScratchDir` and
+	// `RecordLatencies` records `Store.WasiLatencies`. The functions defined
+	// under `Aliases` always have all three.
+	ClockScale      bool
+	ScratchLimits   bool
+	RecordLatencies bool
+
+	// Names of WASI functions which guests may call, if not nil. All other
+	// functions are denied.
+	Allow []string
+	// Names of WASI functions which guests may not call, even if allowed by
+	// `Allow`.
+	//
+	// Names in `Allow` and `Deny` may end in `*` to match every function
+	// starting with what precedes it, as in "sock_*". Denied functions
+	// return ENOTCAPABLE without doing anything, except for `proc_exit`,
+	// which traps since it can't return.
+	Deny []string
+}
+
+// Returns whether the WASI function `name` needs to be wrapped for the
+// functionality enabled in `opts`, or nil if none of it is enabled.
+func (opts *WasiLinkOptions) wrapped() func(name string) bool {
+	if !opts.ClockScale && !opts.ScratchLimits && !opts.RecordLatencies {
+		return nil
+	}
+	return func(name string) bool {
+		// `proc_exit` reports the exit status with an error that wouldn't
+		// survive being returned from a wrapper as a trap, so the original
+		// definition is kept.
+		if name == "proc_exit" {
+			return false
+		}
+		return opts.RecordLatencies ||
+			(opts.ClockScale && wasiClockFuncs[name]) ||
+			(opts.ScratchLimits && wasiScratchFuncs[name])
+	}
+}
+
+// Returns whether the WASI function `name` is denied by `opts`.
+func (opts *WasiLinkOptions) denies(name string) bool {
+	return (opts.Allow != nil && !matchWasiNames(opts.Allow, name)) || matchWasiNames(opts.Deny, name)
+}
+
+// Returns whether `name` matches any of `patterns`, see `WasiLinkOptions.Deny`.
+func matchWasiNames(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == name || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefineWasiWithOptions is like `DefineWasi`, but can also define WASI under
+// other module names, restrict which functions guests may call, and add
+// functionality to them, as configured by `opts`. Both "wasi_snapshot_preview1" and "wasi_unstable",
+// the module name used by older toolchains, are always defined.
+//
+// Returns an error if a name in `opts.Allow` or `opts.Deny` doesn't match
+// any WASI function.
+func (l *Linker) DefineWasiWithOptions(opts WasiLinkOptions) error {
+	for _, pattern := range append(append([]string(nil), opts.Allow...), opts.Deny...) {
+		if !matchesAnyWasiFunc(pattern) {
+			return fmt.Errorf("%q doesn't match any WASI function", pattern)
+		}
+	}
+	aliases := make([]string, 0, len(opts.Aliases))
+	for alias, module := range opts.Aliases {
+		if _, ok := wasiFuncs[module]; !ok {
+			return fmt.Errorf("cannot alias %q to unknown WASI module %q", alias, module)
+		}
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	if opts.Override {
+		if err := l.DefineWasiOverride(); err != nil {
+			return err
+		}
+	} else {
+		var conflicts []LinkerConflict
+		for _, alias := range aliases {
+			for _, name := range wasiFuncs[opts.Aliases[alias]] {
+				if by, ok := l.defined[linkerName{alias, name}]; ok {
+					conflicts = append(conflicts, LinkerConflict{alias, name, by})
+				}
+			}
+		}
+		if len(conflicts) > 0 {
+			return &WasiConflictError{Conflicts: conflicts}
+		}
+		if err := l.DefineWasi(); err != nil {
+			return err
+		}
+	}
+
+	wrapped := opts.wrapped()
+	if wrapped == nil && len(aliases) == 0 && opts.Allow == nil && opts.Deny == nil {
+		return nil
+	}
+	if l.wasiShims == nil {
+		shims, err := newWasiShims(l.Engine)
+		if err != nil {
+			return err
+		}
+		l.wasiShims = shims
+	}
+	if !l.shadowing {
+		C.wasmtime_linker_allow_shadowing(l.ptr(), true)
+		defer C.wasmtime_linker_allow_shadowing(l.ptr(), false)
+	}
+	defer runtime.KeepAlive(l)
+	if wrapped != nil {
+		for module := range wasiFuncs {
+			if err := l.wasiShims.define(l, module, module, wrapped, opts.RecordLatencies); err != nil {
+				return err
+			}
+		}
+	}
+	for _, alias := range aliases {
+		module := opts.Aliases[alias]
+		if err := l.wasiShims.define(l, alias, module, wasiWrapAll, true); err != nil {
+			return err
+		}
+		for _, name := range wasiFuncs[module] {
+			l.recordDefined(alias, name, "DefineWasiWithOptions")
+		}
+	}
+
+	if opts.Allow == nil && opts.Deny == nil {
+		return nil
+	}
+	modules := map[string]string{}
+	for module := range wasiFuncs {
+		modules[module] = module
+	}
+	for alias, module := range opts.Aliases {
+		modules[alias] = module
+	}
+	for as, module := range modules {
+		if err := l.wasiShims.deny(l, as, module, opts.denies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns whether `pattern` matches any function defined by `DefineWasi`.
+func matchesAnyWasiFunc(pattern string) bool {
+	for _, names := range wasiFuncs {
+		for _, name := range names {
+			if matchWasiNames([]string{pattern}, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l *Linker) defineWasi() error {
 	err := C.wasmtime_linker_define_wasi(l.ptr())
 	runtime.KeepAlive(l)
 	if err == nil {
+		for module, names := range wasiFuncs {
+			for _, name := range names {
+				l.recordDefined(module, name, "DefineWasi")
+			}
+		}
 		return nil
 	}
 
@@ -198,8 +642,20 @@ func (l *Linker) DefineWasi() error {
 // Instantiate instantiates a module with all imports defined in this linker.
 //
 // Returns an error if the instance's imports couldn't be satisfied, had the
-// wrong types, or if a trap happened executing the start function.
+// wrong types, or if a trap happened executing the start function. An error
+// is also returned if the WASI configuration of the store needs WASI
+// functions imported by `module` to be wrapped, as described by
+// `WasiLinkOptions`, but they were defined without the wrappers.
 func (l *Linker) Instantiate(store Storelike, module *Module) (*Instance, error) {
+	if err := l.checkWasiShims(store, module); err != nil {
+		return nil, err
+	}
+	data := getDataInStore(store)
+	if data.quota != nil {
+		if err := data.quota.reserveInstance(data); err != nil {
+			return nil, err
+		}
+	}
 	var ret C.wasmtime_instance_t
 	err := enterWasm(store, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
 		return C.wasmtime_linker_instantiate(l.ptr(), store.Context(), module.ptr(), &ret, trap)
@@ -208,8 +664,16 @@ func (l *Linker) Instantiate(store Storelike, module *Module) (*Instance, error)
 	runtime.KeepAlive(module)
 	runtime.KeepAlive(store)
 	if err != nil {
+		if data.quota != nil {
+			data.quota.releaseInstance(data)
+		}
 		return nil, err
 	}
+	recordStoreID(store, ret.store_id)
+	data.addInstance(store, ret, module)
+	if data.quota != nil {
+		data.quota.charge(store, data, 0)
+	}
 	return mkInstance(ret), nil
 }
 
@@ -264,3 +728,67 @@ func (l *Linker) Get(store Storelike, module, name string) *Extern {
 	return nil
 
 }
+
+// LinkerItem describes an item defined in a `Linker`, as returned by
+// `Linker.Items`.
+type LinkerItem struct {
+	Module string
+	Name   string
+	Type   *ExternType
+	// The `Linker` method which defined the item, for example "FuncWrap".
+	DefinedBy string
+}
+
+// Items returns every item defined in this linker, sorted by module and
+// then name, which shows exactly what a guest instantiated with it can
+// import. The types of items are looked up in `store`.
+func (l *Linker) Items(store Storelike) []LinkerItem {
+	ret := make([]LinkerItem, 0, len(l.defined))
+	for n, by := range l.defined {
+		item := l.Get(store, n.module, n.name)
+		if item == nil {
+			continue
+		}
+		ret = append(ret, LinkerItem{Module: n.module, Name: n.name, Type: item.Type(store), DefinedBy: by})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Module != ret[j].Module {
+			return ret[i].Module < ret[j].Module
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// GetFunc returns the function defined as `module`/`name` in this linker, or
+// nil if nothing is defined under that name or it isn't a function.
+func (l *Linker) GetFunc(store Storelike, module, name string) *Func {
+	if item := l.Get(store, module, name); item != nil {
+		return item.Func()
+	}
+	return nil
+}
+
+// GetGlobal is like `GetFunc` but for globals.
+func (l *Linker) GetGlobal(store Storelike, module, name string) *Global {
+	if item := l.Get(store, module, name); item != nil {
+		return item.Global()
+	}
+	return nil
+}
+
+// GetMemory is like `GetFunc` but for memories.
+func (l *Linker) GetMemory(store Storelike, module, name string) *Memory {
+	if item := l.Get(store, module, name); item != nil {
+		return item.Memory()
+	}
+	return nil
+}
+
+// GetTable is like `GetFunc` but for tables.
+func (l *Linker) GetTable(store Storelike, module, name string) *Table {
+	if item := l.Get(store, module, name); item != nil {
+		return item.Table()
+	}
+	return nil
+}
@@ -165,3 +165,362 @@ func TestLinkerFuncs(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 6, called, "expected a call")
 }
+
+func TestLinkerDefineWasiConflicts(t *testing.T) {
+	engine := NewEngine()
+	store := NewStore(engine)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.FuncWrap("wasi_snapshot_preview1", "fd_write", func() {}))
+	require.NoError(t, linker.DefineFunc(store, "wasi_unstable", "proc_exit", func() {}))
+	require.NoError(t, linker.FuncWrap("wasi_snapshot_preview1", "custom", func() {}))
+
+	err := linker.DefineWasi()
+	var conflict *WasiConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.Equal(t, []LinkerConflict{
+		{"wasi_snapshot_preview1", "fd_write", "FuncWrap"},
+		{"wasi_unstable", "proc_exit", "Define"},
+	}, conflict.Conflicts)
+	require.Contains(t, err.Error(), "wasi_snapshot_preview1::fd_write (defined by FuncWrap)")
+	require.Nil(t, linker.Get(store, "wasi_snapshot_preview1", "fd_read"))
+
+	require.NoError(t, linker.DefineWasiOverride())
+	ty := linker.Get(store, "wasi_snapshot_preview1", "fd_write").Func().Type(store)
+	require.Len(t, ty.Params(), 4)
+	require.NotNil(t, linker.Get(store, "wasi_snapshot_preview1", "custom"))
+
+	// shadowing is still disallowed afterwards
+	require.Error(t, linker.FuncWrap("wasi_snapshot_preview1", "fd_write", func() {}))
+	require.Error(t, linker.DefineWasi())
+}
+
+func TestLinkerDefineWasiAliases(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "wasi" "args_sizes_get" (func $args_sizes_get (param i32 i32) (result i32)))
+	  (import "wasi" "proc_exit" (func $proc_exit (param i32)))
+	  (import "wasi_unstable" "args_sizes_get" (func $unstable_args_sizes_get (param i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  (func (export "argc") (result i32)
+	    (drop (call $args_sizes_get (i32.const 0) (i32.const 4)))
+	    (i32.load (i32.const 0)))
+	  (func (export "unstable_argc") (result i32)
+	    (drop (call $unstable_args_sizes_get (i32.const 0) (i32.const 4)))
+	    (i32.load (i32.const 0)))
+	  (func (export "exit") (call $proc_exit (i32.const 3)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineWasiWithOptions(WasiLinkOptions{
+		Aliases: map[string]string{"wasi": "wasi_snapshot_preview1"},
+	}))
+	config := NewWasiConfig()
+	config.SetArgv([]string{"prog", "a", "b"})
+	store := NewStore(engine)
+	store.SetWasiConfig(config)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	for _, name := range []string{"argc", "unstable_argc"} {
+		argc, err := instance.GetFunc(store, name).Call(store)
+		require.NoError(t, err)
+		require.Equal(t, int32(3), argc, name)
+	}
+	_, err = instance.GetFunc(store, "exit").Call(store)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exit status 3")
+
+	// aliases are checked for conflicts like the standard modules
+	linker = NewLinker(engine)
+	require.NoError(t, linker.FuncWrap("wasi", "proc_exit", func(int32) {}))
+	err = linker.DefineWasiWithOptions(WasiLinkOptions{Aliases: map[string]string{"wasi": "wasi_unstable"}})
+	var conflict *WasiConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.Equal(t, []LinkerConflict{{"wasi", "proc_exit", "FuncWrap"}}, conflict.Conflicts)
+	require.NoError(t, linker.DefineWasiWithOptions(WasiLinkOptions{
+		Aliases:  map[string]string{"wasi": "wasi_unstable"},
+		Override: true,
+	}))
+
+	err = NewLinker(engine).DefineWasiWithOptions(WasiLinkOptions{Aliases: map[string]string{"wasi": "wasi_preview3"}})
+	require.Error(t, err)
+}
+
+func TestLinkerDefineWasiInstanceLimit(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  (func (export "run") (result i32)
+	    (call $fd_write (i32.const 1) (i32.const 0) (i32.const 0) (i32.const 8)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	for _, opts := range []WasiLinkOptions{{}, {ScratchLimits: true, RecordLatencies: true}} {
+		linker := NewLinker(engine)
+		require.NoError(t, linker.DefineWasiWithOptions(opts))
+		store := NewStore(engine)
+		store.SetWasiConfig(NewWasiConfig())
+		store.Limiter(-1, -1, 1, -1, -1)
+		instance, err := linker.Instantiate(store, module)
+		require.NoError(t, err)
+
+		// WASI functions wrapped in Go don't use up the instance limit
+		result, err := instance.GetFunc(store, "run").Call(store)
+		require.NoError(t, err)
+		require.Equal(t, int32(0), result)
+		_, err = linker.Instantiate(store, module)
+		require.Error(t, err)
+		require.Equal(t, opts.RecordLatencies, len(store.WasiLatencies()) == 1)
+	}
+}
+
+func TestLinkerAlias(t *testing.T) {
+	engine := NewEngine()
+	store := NewStore(engine)
+	stdlib, err := Wat2Wasm(`
+	(module
+	  (func (export "double") (param i32) (result i32) (i32.mul (local.get 0) (i32.const 2)))
+	  (global (export "base") i32 (i32.const 10))
+	)
+	`)
+	require.NoError(t, err)
+	plugin, err := Wat2Wasm(`
+	(module
+	  (import "env" "twice" (func $twice (param i32) (result i32)))
+	  (import "std" "base" (global $base i32))
+	  (import "std" "double" (func $double (param i32) (result i32)))
+	  (func (export "run") (result i32) (call $twice (call $double (global.get $base))))
+	)
+	`)
+	require.NoError(t, err)
+
+	linker := NewLinker(engine)
+	module, err := NewModule(engine, stdlib)
+	require.NoError(t, err)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	require.NoError(t, linker.DefineInstance(store, "stdlib", instance))
+	require.NoError(t, linker.Alias(store, "stdlib", "double", "env", "twice"))
+	require.NoError(t, linker.AliasModule(store, "stdlib", "std"))
+
+	module, err = NewModule(engine, plugin)
+	require.NoError(t, err)
+	instance, err = linker.Instantiate(store, module)
+	require.NoError(t, err)
+	result, err := instance.GetFunc(store, "run").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(40), result)
+
+	require.Error(t, linker.Alias(store, "stdlib", "missing", "env", "missing"))
+	require.Error(t, linker.AliasModule(store, "missing", "env"))
+	// shadowing is disallowed by default
+	require.Error(t, linker.Alias(store, "stdlib", "base", "std", "double"))
+}
+
+type testHostAPI struct {
+	logged []int32
+}
+
+func (h *testHostAPI) Add(a, b int32) int32 { return a + b }
+
+func (h *testHostAPI) LogValue(v int32) { h.logged = append(h.logged, v) }
+
+func (h *testHostAPI) MemorySize(caller *Caller) int32 {
+	return int32(caller.GetMemory("memory").Size(caller))
+}
+
+func (h *testHostAPI) CheckHTTPStatus(status int32) (int32, *Trap) {
+	if status >= 500 {
+		return 0, NewTrap("server error")
+	}
+	return status / 100, nil
+}
+
+type testRefAPI struct{}
+
+func (testRefAPI) Echo(v interface{}) interface{} { return v }
+
+type testStringAPI struct{}
+
+func (testStringAPI) Greet(name string) int32 { return int32(len(name)) }
+
+type testSliceAPI struct{}
+
+func (testSliceAPI) Bytes() []byte { return nil }
+
+type testStructAPI struct{}
+
+func (testStructAPI) Point(p struct{ X, Y int32 }) int32 { return p.X + p.Y }
+
+func TestLinkerDefineStruct(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "host" "add" (func $add (param i32 i32) (result i32)))
+	  (import "host" "log_value" (func $log_value (param i32)))
+	  (import "host" "memory_size" (func $memory_size (result i32)))
+	  (import "host" "check_http_status" (func $check_http_status (param i32) (result i32)))
+	  (memory (export "memory") 2)
+	  (func (export "run") (result i32)
+	    (call $log_value (call $add (i32.const 1) (i32.const 2)))
+	    (call $log_value (call $memory_size))
+	    (call $check_http_status (i32.const 404)))
+	  (func (export "fail") (drop (call $check_http_status (i32.const 503))))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	api := &testHostAPI{}
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineStruct("host", api))
+	store := NewStore(engine)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	result, err := instance.GetFunc(store, "run").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(4), result)
+	require.Equal(t, []int32{3, 2}, api.logged)
+	_, err = instance.GetFunc(store, "fail").Call(store)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "server error")
+
+	require.Error(t, linker.DefineStruct("host", api))
+	require.Error(t, NewLinker(engine).DefineStruct("host", struct{}{}))
+	require.Error(t, NewLinker(engine).DefineStruct("host", nil))
+	require.Error(t, NewLinker(engine).DefineStruct("host", (*testHostAPI)(nil)))
+
+	wasm, err = Wat2Wasm(`
+	(module
+	  (import "host" "echo" (func $echo (param externref) (result externref)))
+	  (func (export "run") (param externref) (result externref) (call $echo (local.get 0)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err = NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker = NewLinker(engine)
+	require.NoError(t, linker.DefineStruct("host", testRefAPI{}))
+	instance, err = linker.Instantiate(store, module)
+	require.NoError(t, err)
+	result, err = instance.GetFunc(store, "run").Call(store, ValExternref("hello"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", result)
+
+	for _, api := range []interface{}{testStringAPI{}, testSliceAPI{}, testStructAPI{}} {
+		linker = NewLinker(engine)
+		err = linker.DefineStruct("host", api)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no wasm mapping")
+	}
+	err = NewLinker(engine).DefineStruct("host", testStringAPI{})
+	require.EqualError(t, err, "cannot define wasmtime.testStringAPI.Greet: parameter 0 has type string with no wasm mapping")
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"Add":            "add",
+		"ReadFile":       "read_file",
+		"ReadHTTPHeader": "read_http_header",
+		"GetID":          "get_id",
+		"Utf8Decode":     "utf8_decode",
+	} {
+		require.Equal(t, want, snakeCase(name))
+	}
+}
+
+func TestLinkerDefineWasiPolicy(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "wasi_snapshot_preview1" "random_get" (func $random_get (param i32 i32) (result i32)))
+	  (import "wasi_snapshot_preview1" "sock_shutdown" (func $sock_shutdown (param i32 i32) (result i32)))
+	  (import "wasi_snapshot_preview1" "proc_exit" (func $proc_exit (param i32)))
+	  (import "custom" "random_get" (func $custom_random_get (param i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  (func (export "random") (result i32) (call $random_get (i32.const 0) (i32.const 8)))
+	  (func (export "custom_random") (result i32) (call $custom_random_get (i32.const 0) (i32.const 8)))
+	  (func (export "shutdown") (result i32) (call $sock_shutdown (i32.const 3) (i32.const 0)))
+	  (func (export "exit") (call $proc_exit (i32.const 0)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	run := func(opts WasiLinkOptions, name string) (interface{}, error) {
+		opts.Aliases = map[string]string{"custom": "wasi_snapshot_preview1"}
+		linker := NewLinker(engine)
+		require.NoError(t, linker.DefineWasiWithOptions(opts))
+		store := NewStore(engine)
+		store.SetWasiConfig(NewWasiConfig())
+		instance, err := linker.Instantiate(store, module)
+		require.NoError(t, err)
+		return instance.GetFunc(store, name).Call(store)
+	}
+
+	deny := WasiLinkOptions{Deny: []string{"sock_*", "proc_exit"}}
+	result, err := run(deny, "random")
+	require.NoError(t, err)
+	require.Equal(t, int32(0), result)
+	result, err = run(deny, "shutdown")
+	require.NoError(t, err)
+	require.Equal(t, int32(wasiErrnoNotCapable), result)
+	_, err = run(deny, "exit")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "WASI function `proc_exit` is denied")
+
+	allow := WasiLinkOptions{Allow: []string{"args_*", "proc_exit"}}
+	for _, name := range []string{"random", "custom_random"} {
+		result, err = run(allow, name)
+		require.NoError(t, err)
+		require.Equal(t, int32(wasiErrnoNotCapable), result)
+	}
+
+	err = NewLinker(engine).DefineWasiWithOptions(WasiLinkOptions{Deny: []string{"path_frobnicate"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"path_frobnicate" doesn't match any WASI function`)
+}
+
+func TestLinkerItems(t *testing.T) {
+	engine := NewEngine()
+	store := NewStore(engine)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.FuncWrap("host", "add", func(a, b int32) int32 { return a + b }))
+	memory, err := NewMemory(store, NewMemoryType(1, false, 0))
+	require.NoError(t, err)
+	require.NoError(t, linker.Define(store, "env", "memory", memory))
+	global, err := NewGlobal(store, NewGlobalType(NewValType(KindI64), false), ValI64(4))
+	require.NoError(t, err)
+	require.NoError(t, linker.Define(store, "env", "g", global))
+	table, err := NewTable(store, NewTableType(NewValType(KindFuncref), 2, false, 0), ValFuncref(nil))
+	require.NoError(t, err)
+	require.NoError(t, linker.Define(store, "env", "t", table))
+
+	items := linker.Items(store)
+	require.Len(t, items, 4)
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Module+"::"+item.Name+" "+item.DefinedBy)
+	}
+	require.Equal(t, []string{"env::g Define", "env::memory Define", "env::t Define", "host::add FuncWrap"}, names)
+	require.NotNil(t, items[1].Type.MemoryType())
+	require.Len(t, items[3].Type.FuncType().Params(), 2)
+
+	require.NotNil(t, linker.GetFunc(store, "host", "add"))
+	require.Nil(t, linker.GetFunc(store, "env", "memory"))
+	require.Nil(t, linker.GetFunc(store, "host", "missing"))
+	require.Equal(t, uint64(1), linker.GetMemory(store, "env", "memory").Size(store))
+	require.Equal(t, int64(4), linker.GetGlobal(store, "env", "g").Get(store).I64())
+	require.Equal(t, uint32(2), linker.GetTable(store, "env", "t").Size(store))
+	require.Nil(t, linker.GetTable(store, "env", "g"))
+}
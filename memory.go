@@ -3,10 +3,16 @@ package wasmtime
 // #include "shims.h"
 import "C"
 import (
+	"errors"
+	"io"
+	"math"
 	"runtime"
 	"unsafe"
 )
 
+// The size, in bytes, of a WebAssembly page.
+const wasmPageSize = 65536
+
 // Memory instance is the runtime representation of a linear memory.
 // It holds a vector of bytes and an optional maximum size, if one was specified at the definition site of the memory.
 // Read more in [spec](https://webassembly.github.io/spec/core/exec/runtime.html#memory-instances)
@@ -18,12 +24,21 @@ type Memory struct {
 // NewMemory creates a new `Memory` in the given `Store` with the specified `ty`.
 func NewMemory(store Storelike, ty *MemoryType) (*Memory, error) {
 	var ret C.wasmtime_memory_t
-	err := C.wasmtime_memory_new(store.Context(), ty.ptr(), &ret)
+	err := failpointError(FailpointAlloc)
+	if err == nil {
+		err = C.wasmtime_memory_new(store.Context(), ty.ptr(), &ret)
+	}
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(ty)
 	if err != nil {
 		return nil, mkError(err)
 	}
+	recordStoreID(store, ret.store_id)
+	data := getDataInStore(store)
+	data.addMemory(store, ret)
+	if data.quota != nil {
+		data.quota.charge(store, data, 0)
+	}
 	return mkMemory(ret), nil
 }
 
@@ -33,6 +48,7 @@ func mkMemory(val C.wasmtime_memory_t) *Memory {
 
 // Type returns the type of this memory
 func (mem *Memory) Type(store Storelike) *MemoryType {
+	assertStore(store, mem.val.store_id, "memory")
 	ptr := C.wasmtime_memory_type(store.Context(), &mem.val)
 	runtime.KeepAlive(store)
 	return mkMemoryType(ptr, nil)
@@ -40,6 +56,7 @@ func (mem *Memory) Type(store Storelike) *MemoryType {
 
 // Data returns the raw pointer in memory of where this memory starts
 func (mem *Memory) Data(store Storelike) unsafe.Pointer {
+	assertStore(store, mem.val.store_id, "memory")
 	ret := unsafe.Pointer(C.wasmtime_memory_data(store.Context(), &mem.val))
 	runtime.KeepAlive(store)
 	return ret
@@ -55,18 +72,19 @@ func (mem *Memory) Data(store Storelike) unsafe.Pointer {
 // Note that you may need to use `runtime.KeepAlive` to keep the original memory
 // `m` alive for long enough while you're using the `[]byte` slice. If the
 // `[]byte` slice is used after `m` is GC'd then that is undefined behavior.
+//
+// 64-bit memories larger than 4GiB are supported on 64-bit hosts.
 func (mem *Memory) UnsafeData(store Storelike) []byte {
-	// see https://github.com/golang/go/wiki/cgo#turning-c-arrays-into-go-slices
-	const MaxLen = 1 << 32
 	length := mem.DataSize(store)
-	if length >= MaxLen {
+	if length > math.MaxInt {
 		panic("memory is too big")
 	}
-	return (*[MaxLen]byte)(mem.Data(store))[:length:length]
+	return unsafe.Slice((*byte)(mem.Data(store)), int(length))
 }
 
 // DataSize returns the size, in bytes, that `Data()` is valid for
 func (mem *Memory) DataSize(store Storelike) uintptr {
+	assertStore(store, mem.val.store_id, "memory")
 	ret := uintptr(C.wasmtime_memory_data_size(store.Context(), &mem.val))
 	runtime.KeepAlive(store)
 	return ret
@@ -74,6 +92,7 @@ func (mem *Memory) DataSize(store Storelike) uintptr {
 
 // Size returns the size, in wasm pages, of this memory
 func (mem *Memory) Size(store Storelike) uint64 {
+	assertStore(store, mem.val.store_id, "memory")
 	ret := uint64(C.wasmtime_memory_size(store.Context(), &mem.val))
 	runtime.KeepAlive(store)
 	return ret
@@ -81,15 +100,90 @@ func (mem *Memory) Size(store Storelike) uint64 {
 
 // Grow grows this memory by `delta` pages
 func (mem *Memory) Grow(store Storelike, delta uint64) (uint64, error) {
+	if err := checkStore(store, mem.val.store_id, "memory"); err != nil {
+		return 0, err
+	}
+	data := getDataInStore(store)
+	if data.quota != nil {
+		if remaining, ok := data.quota.memoryRemaining(); ok && delta > remaining/wasmPageSize {
+			return 0, data.limitDenied(errors.New("memory growth denied by quota group"))
+		}
+	}
 	prev := C.uint64_t(0)
-	err := C.wasmtime_memory_grow(store.Context(), &mem.val, C.uint64_t(delta), &prev)
+	err := failpointError(FailpointAlloc)
+	if err == nil {
+		err = C.wasmtime_memory_grow(store.Context(), &mem.val, C.uint64_t(delta), &prev)
+	}
 	runtime.KeepAlive(store)
 	if err != nil {
 		return 0, mkError(err)
 	}
+	if data.quota != nil {
+		data.quota.charge(store, data, 0)
+	}
+	data.checkMemoryGrowth(store)
 	return uint64(prev), nil
 }
 
+// ReaderAt returns an `io.ReaderAt` which reads from this memory, so that it
+// can be used with standard I/O utilities such as `io.NewSectionReader`.
+//
+// Unlike the slice returned by `UnsafeData`, the reader looks up the memory's
+// data on every read, so it remains valid when the memory grows. Reads past
+// the end of the memory return `io.EOF` as a file would.
+func (mem *Memory) ReaderAt(store Storelike) io.ReaderAt {
+	return &memoryAt{store, mem}
+}
+
+// WriterAt returns an `io.WriterAt` which writes to this memory, and which
+// like `ReaderAt` remains valid when the memory grows.
+//
+// Writes are bounds checked, and writes which don't fit in the memory fail
+// without writing anything.
+func (mem *Memory) WriterAt(store Storelike) io.WriterAt {
+	return &memoryAt{store, mem}
+}
+
+var errMemoryOutOfBounds = errors.New("out of bounds memory access")
+
+// Implementation of `Memory.ReaderAt` and `Memory.WriterAt`.
+type memoryAt struct {
+	store Storelike
+	mem   *Memory
+}
+
+func (m *memoryAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errMemoryOutOfBounds
+	}
+	// Reads are cut short at the end of the memory.
+	size := m.mem.DataSize(m.store)
+	if uint64(off) >= uint64(size) {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if uint64(n) > uint64(size)-uint64(off) {
+		n = int(uint64(size) - uint64(off))
+	}
+	if err := m.mem.read(m.store, uint64(off), p[:n]); err != nil {
+		return 0, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memoryAt) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errMemoryOutOfBounds
+	}
+	if err := m.mem.write(m.store, uint64(off), p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
 func (mem *Memory) AsExtern() C.wasmtime_extern_t {
 	ret := C.wasmtime_extern_t{kind: C.WASMTIME_EXTERN_MEMORY}
 	C.go_wasmtime_extern_memory_set(&ret, mem.val)
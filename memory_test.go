@@ -0,0 +1,146 @@
+package wasmtime
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory64Large(t *testing.T) {
+	if math.MaxInt < math.MaxUint32 {
+		t.Skip("memories larger than 4GiB require a 64-bit host")
+	}
+	config := NewConfig()
+	config.SetWasmMemory64(true)
+	store := NewStore(NewEngineWithConfig(config))
+	wasm, err := Wat2Wasm(`
+	(module
+	  (memory (export "memory") i64 65537)
+	  (func (export "store") (param i64 i32)
+	    (i32.store8 (local.get 0) (local.get 1)))
+	  (func (export "load") (param i64) (result i32)
+	    (i32.load8_u (local.get 0)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+
+	memory := instance.GetExport(store, "memory").Memory()
+	require.True(t, memory.Type(store).Is64())
+	require.Equal(t, uint64(65537), memory.Size(store))
+	size := uint64(65537) * 65536
+	require.Equal(t, uintptr(size), memory.DataSize(store))
+
+	// bytes past 4GiB are visible from both sides
+	_, err = instance.GetFunc(store, "store").Call(store, int64(size-1), 42)
+	require.NoError(t, err)
+	data := memory.UnsafeData(store)
+	require.Equal(t, int(size), len(data))
+	require.Equal(t, byte(42), data[size-1])
+	data[1<<32+5] = 7
+	result, err := instance.GetFunc(store, "load").Call(store, int64(1<<32+5))
+	require.NoError(t, err)
+	require.Equal(t, int32(7), result)
+
+	prev, err := memory.Grow(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(65537), prev)
+	require.Equal(t, uint64(65538), memory.Size(store))
+
+	memory, err = NewMemory(store, NewMemoryType64(65537, true, 65540))
+	require.NoError(t, err)
+	require.Equal(t, int(size), len(memory.UnsafeData(store)))
+}
+
+func TestMemoryReaderWriterAt(t *testing.T) {
+	store := NewStore(NewEngine())
+	mem, err := NewMemory(store, NewMemoryType(1, true, 2))
+	require.NoError(t, err)
+	r, w := mem.ReaderAt(store), mem.WriterAt(store)
+
+	n, err := w.WriteAt([]byte{1, 0, 0, 0, 2, 0, 0, 0}, 100)
+	require.NoError(t, err)
+	require.Equal(t, 8, n)
+	var pair [2]uint32
+	require.NoError(t, binary.Read(io.NewSectionReader(r, 100, 8), binary.LittleEndian, &pair))
+	require.Equal(t, [2]uint32{1, 2}, pair)
+
+	buf := make([]byte, 8)
+	n, err = r.ReadAt(buf, 65536-4)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 4, n)
+	_, err = r.ReadAt(buf, 65536)
+	require.Equal(t, io.EOF, err)
+	_, err = r.ReadAt(buf, -1)
+	require.Error(t, err)
+
+	_, err = w.WriteAt(buf, 65536-4)
+	require.Error(t, err)
+	_, err = w.WriteAt(buf, -1)
+	require.Error(t, err)
+
+	// Both remain valid after the memory grows.
+	_, err = mem.Grow(store, 1)
+	require.NoError(t, err)
+	_, err = w.WriteAt([]byte("grown"), 65536)
+	require.NoError(t, err)
+	n, err = r.ReadAt(buf[:5], 65536)
+	require.NoError(t, err)
+	require.Equal(t, "grown", string(buf[:n]))
+}
+
+func TestMemoryGrowthCallback(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "" "observe" (func $observe))
+	  (memory (export "memory") 1)
+	  ;; exported twice, but its growth is only reported once
+	  (export "alias" (memory 0))
+	  (func (export "grow") (param i32)
+	    (drop (memory.grow (local.get 0)))
+	    (call $observe)
+	    (drop (memory.grow (i32.const 1)))))
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	type growth struct{ before, after uint64 }
+	var grown []growth
+	var observed []uint64
+	observe := WrapFunc(store, func(c *Caller) {
+		observed = append(observed, uint64(len(grown)))
+	})
+	instance, err := NewInstance(store, module, []AsExtern{observe})
+	require.NoError(t, err)
+	mem := instance.GetExport(store, "memory").Memory()
+
+	store.SetMemoryGrowthCallback(func(m *Memory, before, after uint64) {
+		require.Equal(t, after, m.Size(store))
+		grown = append(grown, growth{before, after})
+	})
+	_, err = instance.GetFunc(store, "grow").Call(store, 2)
+	require.NoError(t, err)
+	require.Equal(t, []growth{{1, 3}, {3, 4}}, grown)
+	require.Equal(t, []uint64{1}, observed, "growth is reported before host functions run")
+
+	_, err = mem.Grow(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, growth{4, 5}, grown[len(grown)-1])
+
+	_, err = instance.GetFunc(store, "grow").Call(store, 0)
+	require.NoError(t, err)
+	require.Equal(t, growth{5, 6}, grown[len(grown)-1])
+	require.Len(t, grown, 4)
+
+	store.SetMemoryGrowthCallback(nil)
+	_, err = mem.Grow(store, 1)
+	require.NoError(t, err)
+	require.Len(t, grown, 4)
+}
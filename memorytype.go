@@ -54,10 +54,25 @@ func mkMemoryType(ptr *C.wasm_memorytype_t, owner interface{}) *MemoryType {
 
 func (ty *MemoryType) ptr() *C.wasm_memorytype_t {
 	ret := ty._ptr
+	if ret == nil {
+		panic("MemoryType used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this type without waiting for it to be garbage collected.
+// It has no effect on types which belong to another object, such as those
+// returned from the methods of other types.
+func (ty *MemoryType) Close() {
+	if ty._ptr == nil || ty._owner != nil {
+		return
+	}
+	runtime.SetFinalizer(ty, nil)
+	C.wasm_memorytype_delete(ty._ptr)
+	ty._ptr = nil
+}
+
 func (ty *MemoryType) owner() interface{} {
 	if ty._owner != nil {
 		return ty._owner
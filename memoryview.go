@@ -0,0 +1,152 @@
+package wasmtime
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// Typed accessors for data at guest pointers within a `Memory`, for glue code
+// which exchanges C-style structures with a guest. Values are little-endian,
+// as WebAssembly itself is, and every access is bounds checked.
+
+// ReadUint32 reads the little-endian `u32` at `ptr` in this memory.
+func (mem *Memory) ReadUint32(store Storelike, ptr uint64) (uint32, error) {
+	var buf [4]byte
+	if err := mem.read(store, ptr, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+// ReadUint64 reads the little-endian `u64` at `ptr` in this memory.
+func (mem *Memory) ReadUint64(store Storelike, ptr uint64) (uint64, error) {
+	var buf [8]byte
+	if err := mem.read(store, ptr, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+// WriteUint32 writes `val` as a little-endian `u32` to `ptr` in this memory.
+func (mem *Memory) WriteUint32(store Storelike, ptr uint64, val uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], val)
+	return mem.write(store, ptr, buf[:])
+}
+
+// WriteUint64 writes `val` as a little-endian `u64` to `ptr` in this memory.
+func (mem *Memory) WriteUint64(store Storelike, ptr uint64, val uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], val)
+	return mem.write(store, ptr, buf[:])
+}
+
+// ReadStruct decodes the data at `ptr` in this memory into `v` with
+// `binary.Read`, so `v` must be a pointer to a fixed-size value, such as a
+// struct of sized integers, floats and arrays of them.
+//
+// Fields are decoded back to back, so a guest struct with padding needs
+// explicit padding fields, such as `_ [4]byte`, to match its layout.
+func (mem *Memory) ReadStruct(store Storelike, ptr uint64, v interface{}) error {
+	size := binary.Size(v)
+	if size < 0 {
+		return fmt.Errorf("%T doesn't have a fixed size", v)
+	}
+	buf := make([]byte, size)
+	if err := mem.read(store, ptr, buf); err != nil {
+		return err
+	}
+	return binary.Read(bytes.NewReader(buf), binary.LittleEndian, v)
+}
+
+// WriteStruct encodes `v` to `ptr` in this memory with `binary.Write`, with
+// the same restrictions as `ReadStruct`.
+func (mem *Memory) WriteStruct(store Storelike, ptr uint64, v interface{}) error {
+	size := binary.Size(v)
+	if size < 0 {
+		return fmt.Errorf("%T doesn't have a fixed size", v)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	if err := binary.Write(buf, binary.LittleEndian, v); err != nil {
+		return err
+	}
+	return mem.write(store, ptr, buf.Bytes())
+}
+
+// FixedSize is satisfied by the fixed-size numeric types which can be viewed
+// in place in guest memory with `MemorySlice`.
+type FixedSize interface {
+	~int8 | ~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64 | ~float32 | ~float64
+}
+
+// MemorySlice returns a slice of the `n` values of type `T` stored at `ptr` in
+// `mem`, which aliases the memory rather than copying it, so that the host
+// and the guest see each other's changes.
+//
+// Returns an error if the values aren't within the memory, if `ptr` isn't
+// aligned for `T`, or if the host is big-endian, since the values are stored
+// in little-endian byte order.
+//
+// As with `Memory.UnsafeData`, the slice is only valid until the memory next
+// grows, see `Store.SetMemoryGrowthCallback`, and `mem` must be kept alive
+// while it's used.
+func MemorySlice[T FixedSize](store Storelike, mem *Memory, ptr, n uint64) ([]T, error) {
+	if !hostIsLittleEndian() {
+		return nil, errors.New("memory slices are only supported on little-endian hosts")
+	}
+	var zero T
+	size := uint64(unsafe.Sizeof(zero))
+	if ptr%size != 0 {
+		return nil, fmt.Errorf("pointer %#x isn't aligned to %d bytes", ptr, size)
+	}
+	data := mem.UnsafeData(store)
+	if n > uint64(len(data))/size || ptr > uint64(len(data))-n*size {
+		return nil, errMemoryOutOfBounds
+	}
+	if n == 0 {
+		return []T{}, nil
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(&data[ptr])), int(n)), nil
+}
+
+// Returns the `n` bytes at `ptr` in this memory, aliasing the memory rather
+// than copying it. This is the bounds check shared by all of the accessors of
+// guest memory.
+func (mem *Memory) region(store Storelike, ptr, n uint64) ([]byte, error) {
+	data := mem.UnsafeData(store)
+	if ptr > uint64(len(data)) || n > uint64(len(data))-ptr {
+		return nil, errMemoryOutOfBounds
+	}
+	return data[ptr : ptr+n], nil
+}
+
+// Copies the data at `ptr` in this memory to `dst`.
+func (mem *Memory) read(store Storelike, ptr uint64, dst []byte) error {
+	region, err := mem.region(store, ptr, uint64(len(dst)))
+	if err != nil {
+		return err
+	}
+	copy(dst, region)
+	runtime.KeepAlive(mem)
+	return nil
+}
+
+// Copies `src` to `ptr` in this memory.
+func (mem *Memory) write(store Storelike, ptr uint64, src []byte) error {
+	region, err := mem.region(store, ptr, uint64(len(src)))
+	if err != nil {
+		return err
+	}
+	copy(region, src)
+	runtime.KeepAlive(mem)
+	return nil
+}
+
+func hostIsLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
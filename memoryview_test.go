@@ -0,0 +1,60 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryView(t *testing.T) {
+	store := NewStore(NewEngine())
+	mem, err := NewMemory(store, NewMemoryType(1, false, 0))
+	require.NoError(t, err)
+
+	require.NoError(t, mem.WriteUint32(store, 8, 0xdeadbeef))
+	require.NoError(t, mem.WriteUint64(store, 16, 1<<40|2))
+	v32, err := mem.ReadUint32(store, 8)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0xdeadbeef), v32)
+	v64, err := mem.ReadUint64(store, 16)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1<<40|2), v64)
+	require.Equal(t, byte(0xef), mem.UnsafeData(store)[8], "little-endian")
+
+	_, err = mem.ReadUint64(store, 65536-4)
+	require.Error(t, err)
+	require.Error(t, mem.WriteUint32(store, 65536-3, 0))
+	require.NoError(t, mem.WriteUint32(store, 65536-4, 0))
+
+	type header struct {
+		Magic uint32
+		_     [4]byte
+		Len   uint64
+		Tag   [2]uint16
+	}
+	require.NoError(t, mem.WriteStruct(store, 32, &header{Magic: 7, Len: 9, Tag: [2]uint16{1, 2}}))
+	var h header
+	require.NoError(t, mem.ReadStruct(store, 32, &h))
+	require.Equal(t, header{Magic: 7, Len: 9, Tag: [2]uint16{1, 2}}, h)
+	v64, err = mem.ReadUint64(store, 40)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), v64)
+	require.Error(t, mem.ReadStruct(store, 65536-8, &h))
+	require.Error(t, mem.ReadStruct(store, 0, &struct{ S string }{}))
+
+	words, err := MemorySlice[uint32](store, mem, 8, 4)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0xdeadbeef), words[0])
+	words[1] = 5
+	v32, err = mem.ReadUint32(store, 12)
+	require.NoError(t, err)
+	require.Equal(t, uint32(5), v32)
+
+	_, err = MemorySlice[uint32](store, mem, 6, 1)
+	require.Error(t, err, "misaligned")
+	_, err = MemorySlice[uint64](store, mem, 65536-8, 2)
+	require.Error(t, err)
+	empty, err := MemorySlice[uint64](store, mem, 65536, 0)
+	require.NoError(t, err)
+	require.Empty(t, empty)
+}
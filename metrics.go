@@ -0,0 +1,86 @@
+package wasmtime
+
+// #include "shims.h"
+import "C"
+import "runtime"
+
+// StoreMetrics is a snapshot of the resources used by a `Store`, see
+// `Store.Metrics`.
+type StoreMetrics struct {
+	// The number of instances created within the store.
+	Instances int
+	// The number of linear memories in the store, and their total size in
+	// bytes.
+	Memories    int
+	MemoryBytes uint64
+	// The number of tables in the store, and their total size in elements.
+	Tables        int
+	TableElements uint64
+	// The fuel consumed so far, or 0 if fuel consumption isn't enabled with
+	// `Config.SetConsumeFuel`.
+	FuelConsumed uint64
+}
+
+// Metrics returns a snapshot of the resources currently used by this store,
+// for example to export per-tenant gauges.
+//
+// Only memories and tables created with `NewMemory` and `NewTable` or
+// exported from instances created with `NewInstance` or `Linker.Instantiate`
+// are counted, since the C API offers no way to enumerate the others. A memory
+// exported more than once is only counted once, but a table is counted for
+// each of its exports, since the C API can't tell whether two table exports
+// refer to the same table.
+func (store *Store) Metrics() StoreMetrics {
+	data := getDataInStore(store)
+	ret := StoreMetrics{
+		Instances: len(data.instanceModules),
+		Memories:  len(data.memories),
+		Tables:    len(data.tables),
+	}
+	for i := range data.memories {
+		ret.MemoryBytes += uint64(C.wasmtime_memory_data_size(store.Context(), &data.memories[i]))
+	}
+	for i := range data.tables {
+		ret.TableElements += uint64(C.wasmtime_table_size(store.Context(), &data.tables[i]))
+	}
+	ret.FuelConsumed, _ = store.FuelConsumed()
+	runtime.KeepAlive(store)
+	return ret
+}
+
+// EngineMetrics is a snapshot of the stores and instances alive in an
+// `Engine`, see `Engine.Metrics`.
+type EngineMetrics struct {
+	// The number of stores created with the engine which haven't been
+	// deallocated yet.
+	Stores int
+	// The number of instances within those stores.
+	Instances int
+}
+
+// Metrics returns a snapshot of the stores and instances currently alive in
+// this engine. Stores are counted until they're closed or garbage collected,
+// and instances are counted as with `SetInstanceHooks`.
+//
+// The occupancy of the pooling allocator isn't included since the C API
+// doesn't expose it. Use `Store.Metrics` for the memory and tables of each
+// store.
+//
+// This method is safe to call from any goroutine.
+func (engine *Engine) Metrics() EngineMetrics {
+	engine.metricsLock.Lock()
+	defer engine.metricsLock.Unlock()
+	return EngineMetrics{Stores: engine.stores, Instances: engine.instances}
+}
+
+func (engine *Engine) trackStores(delta int) {
+	engine.metricsLock.Lock()
+	engine.stores += delta
+	engine.metricsLock.Unlock()
+}
+
+func (engine *Engine) trackInstances(delta int) {
+	engine.metricsLock.Lock()
+	engine.instances += delta
+	engine.metricsLock.Unlock()
+}
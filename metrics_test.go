@@ -0,0 +1,66 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreMetrics(t *testing.T) {
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	store := NewStore(NewEngineWithConfig(config))
+	require.Equal(t, StoreMetrics{}, store.Metrics())
+
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (memory (export "memory") 2)
+	    (export "memory2" (memory 0))
+	    (table (export "table") 3 funcref)
+	    (func (export "run") nop))
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+
+	_, err = NewMemory(store, NewMemoryType(1, false, 0))
+	require.NoError(t, err)
+	_, err = NewTable(store, NewTableType(NewValType(KindExternref), 5, false, 0), ValExternref(nil))
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddFuel(100))
+	_, err = instance.GetFunc(store, "run").Call(store)
+	require.NoError(t, err)
+	fuel, _ := store.FuelConsumed()
+
+	require.Equal(t, StoreMetrics{
+		Instances:     1,
+		Memories:      2,
+		MemoryBytes:   3 * 65536,
+		Tables:        2,
+		TableElements: 8,
+		FuelConsumed:  fuel,
+	}, store.Metrics())
+}
+
+func TestEngineMetrics(t *testing.T) {
+	engine := NewEngine()
+	require.Equal(t, EngineMetrics{}, engine.Metrics())
+
+	module, err := NewModule(engine, []byte("\x00asm\x01\x00\x00\x00"))
+	require.NoError(t, err)
+	store := NewStore(engine)
+	_, err = NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	other := NewStore(engine)
+	require.Equal(t, EngineMetrics{Stores: 2, Instances: 2}, engine.Metrics())
+
+	store.Close()
+	require.Equal(t, EngineMetrics{Stores: 1}, engine.Metrics())
+	other.Close()
+	require.Equal(t, EngineMetrics{}, engine.Metrics())
+}
@@ -5,8 +5,11 @@ package wasmtime
 import "C"
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"runtime"
+	"sync"
 	"unsafe"
 )
 
@@ -15,6 +18,16 @@ import (
 // Modules organized WebAssembly programs as the unit of deployment, loading, and compilation.
 type Module struct {
 	_ptr *C.wasmtime_module_t
+	name string
+	// The custom sections of the binary the module was compiled from, see
+	// `CustomSections`.
+	customSections map[string][][]byte
+	// Offset of the code section's contents in the binary, or -1 if it's
+	// unknown, along with the line table parsed from its DWARF debug info
+	// on first use by `SourceLocation`.
+	codeOffset int
+	linesOnce  sync.Once
+	lines      []sourceLine
 }
 
 // NewModule compiles a new `Module` from the `wasm` provided with the given configuration
@@ -30,15 +43,25 @@ func NewModule(engine *Engine, wasm []byte) (*Module, error) {
 		wasmPtr = (*C.uint8_t)(unsafe.Pointer(&wasm[0]))
 	}
 	var ptr *C.wasmtime_module_t
-	err := C.wasmtime_module_new(engine.ptr(), wasmPtr, C.size_t(len(wasm)), &ptr)
+	err := failpointError(FailpointCompile)
+	if err == nil {
+		err = C.wasmtime_module_new(engine.ptr(), wasmPtr, C.size_t(len(wasm)), &ptr)
+	}
 	runtime.KeepAlive(engine)
 	runtime.KeepAlive(wasm)
 
 	if err != nil {
-		return nil, mkError(err)
+		wrapped := mkError(err)
+		engine.recordEvent(EngineEventCompile, 0, "failed: "+wrapped.Error())
+		return nil, wrapped
 	}
 
-	return mkModule(ptr), nil
+	module := mkModule(ptr)
+	module.name = wasmModuleName(wasm)
+	module.customSections = wasmCustomSections(wasm, engine.sourceLocations)
+	module.codeOffset = wasmCodeOffset(wasm)
+	engine.recordEvent(EngineEventCompile, 0, module.name)
+	return module, nil
 }
 
 // NewModuleFromFile reads the contents of the `file` provided and interprets them as either the
@@ -61,6 +84,47 @@ func NewModuleFromFile(engine *Engine, file string) (*Module, error) {
 	return NewModule(engine, wasm)
 }
 
+// NewModuleFromReader reads WebAssembly from `r` until EOF, in either the text
+// format or the binary format, and compiles it like `NewModule`, for example
+// straight from a network download.
+//
+// The wasmtime C API can only compile complete modules, so compilation starts
+// once all of the module has been read. The module is read into a single
+// buffer which is passed to the compiler without being copied again, and if
+// `r` reports its size, through a `Len` method like `bytes.Reader` or a `Stat`
+// method like `os.File`, the buffer is allocated up front.
+func NewModuleFromReader(engine *Engine, r io.Reader) (*Module, error) {
+	var buf bytes.Buffer
+	if size := readerSize(r); size > 0 {
+		buf.Grow(size + bytes.MinRead)
+	}
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	wasm := buf.Bytes()
+	if len(wasm) > 0 && wasm[0] != 0 {
+		var err error
+		wasm, err = Wat2Wasm(string(wasm))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewModule(engine, wasm)
+}
+
+// Returns how many bytes are left to read from `r`, if it's known, or 0.
+func readerSize(r io.Reader) int {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return r.Len()
+	case interface{ Stat() (os.FileInfo, error) }:
+		if info, err := r.Stat(); err == nil && info.Mode().IsRegular() {
+			return int(info.Size())
+		}
+	}
+	return 0
+}
+
 // ModuleValidate validates whether `wasm` would be a valid wasm module according to the
 // configuration in `store`
 func ModuleValidate(engine *Engine, wasm []byte) error {
@@ -68,7 +132,10 @@ func ModuleValidate(engine *Engine, wasm []byte) error {
 	if len(wasm) > 0 {
 		wasmPtr = (*C.uint8_t)(unsafe.Pointer(&wasm[0]))
 	}
-	err := C.wasmtime_module_validate(engine.ptr(), wasmPtr, C.size_t(len(wasm)))
+	err := failpointError(FailpointCompile)
+	if err == nil {
+		err = C.wasmtime_module_validate(engine.ptr(), wasmPtr, C.size_t(len(wasm)))
+	}
 	runtime.KeepAlive(engine)
 	runtime.KeepAlive(wasm)
 	if err == nil {
@@ -79,7 +146,7 @@ func ModuleValidate(engine *Engine, wasm []byte) error {
 }
 
 func mkModule(ptr *C.wasmtime_module_t) *Module {
-	module := &Module{_ptr: ptr}
+	module := &Module{_ptr: ptr, codeOffset: -1}
 	runtime.SetFinalizer(module, func(module *Module) {
 		C.wasmtime_module_delete(module._ptr)
 	})
@@ -88,10 +155,75 @@ func mkModule(ptr *C.wasmtime_module_t) *Module {
 
 func (m *Module) ptr() *C.wasmtime_module_t {
 	ret := m._ptr
+	if ret == nil {
+		panic("Module used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this module without waiting for it to be garbage
+// collected. Instances created from it remain usable.
+func (m *Module) Close() {
+	if m._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(m, nil)
+	C.wasmtime_module_delete(m._ptr)
+	m._ptr = nil
+}
+
+// Name returns the name of this module as recorded in the `name` custom
+// section of its binary, or an empty string if it doesn't have one.
+//
+// Modules created with `NewModuleDeserialize` or `NewModuleDeserializeFile`
+// never have a name.
+func (m *Module) Name() string {
+	return m.name
+}
+
+// FunctionNames returns the names of functions recorded in the `name` custom
+// section of this module's binary, keyed by function index, which counts
+// imported functions first. This is the index reported by
+// `Frame.FuncIndex`.
+//
+// Returns nil if the module doesn't have a `name` section, which is always
+// the case for modules created with `NewModuleDeserialize` or
+// `NewModuleDeserializeFile`.
+func (m *Module) FunctionNames() map[uint32]string {
+	sections := m.customSections["name"]
+	if len(sections) == 0 {
+		return nil
+	}
+	return wasmFunctionNames(sections[0])
+}
+
+// Producer is a tool recorded in the `producers` custom section of a module,
+// see `Module.Producers`.
+type Producer struct {
+	Name    string
+	Version string
+}
+
+// Producers returns the contents of the `producers` custom section of this
+// module's binary, which records the tools involved in producing it. Tools
+// are keyed by field, such as "language", "processed-by" and "sdk".
+//
+// Returns nil if the module doesn't have a well-formed `producers` section,
+// which is always the case for modules created with `NewModuleDeserialize` or
+// `NewModuleDeserializeFile`.
+func (m *Module) Producers() map[string][]Producer {
+	sections := m.customSections["producers"]
+	if len(sections) == 0 {
+		return nil
+	}
+	ret, err := wasmProducers(sections[0])
+	if err != nil {
+		return nil
+	}
+	return ret
+}
+
 // Imports returns a list of `ImportType` which are the items imported by
 // this module and are required for instantiation
 func (m *Module) Imports() []*ImportType {
@@ -164,6 +296,7 @@ func (list *exportTypeList) mkGoList() []*ExportType {
 // produced with an `Engine` that has the same compilation options as the
 // provided engine, and from the same version of this library.
 func NewModuleDeserialize(engine *Engine, encoded []byte) (*Module, error) {
+	encoded = failpointCorrupt(FailpointDeserialize, encoded)
 	var encodedPtr *C.uint8_t
 	var ptr *C.wasmtime_module_t
 	if len(encoded) > 0 {
@@ -185,12 +318,50 @@ func NewModuleDeserialize(engine *Engine, encoded []byte) (*Module, error) {
 	return mkModule(ptr), nil
 }
 
+// CompatibleEngines returns the indices of the engines in `engines` which are
+// able to load `artifact`, a module serialized with `Module.Serialize`, with
+// `NewModuleDeserialize`. This can be used to route precompiled artifacts to
+// the hosts of a fleet whose engines are configured differently.
+//
+// Compatibility is checked by deserializing the artifact once for each
+// distinct engine configuration, as reported by `Engine.SerializeConfig`.
+//
+// Like `NewModuleDeserialize` this must only be used with trusted artifacts.
+func CompatibleEngines(artifact []byte, engines []*Engine) []int {
+	ret := []int{}
+	compatible := make(map[string]bool)
+	for i, engine := range engines {
+		config := string(engine.SerializeConfig())
+		ok, checked := compatible[config]
+		if !checked {
+			if module, err := NewModuleDeserialize(engine, artifact); err == nil {
+				module.Close()
+				ok = true
+			}
+			compatible[config] = ok
+		}
+		if ok {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
 // NewModuleDeserializeFile is the same as `NewModuleDeserialize` except that
 // the bytes are read from a file instead of provided as an argument.
+//
+// The file is memory-mapped by wasmtime rather than read into memory, so the
+// module's code is paged in from the file on demand and is never copied into
+// Go memory. This keeps startup memory usage down for large precompiled
+// modules, compared to reading the file and calling `NewModuleDeserialize`.
+// The file must not be modified while the module is alive.
 func NewModuleDeserializeFile(engine *Engine, path string) (*Module, error) {
 	cs := C.CString(path)
 	var ptr *C.wasmtime_module_t
-	err := C.wasmtime_module_deserialize_file(engine.ptr(), cs, &ptr)
+	err := failpointError(FailpointDeserialize)
+	if err == nil {
+		err = C.wasmtime_module_deserialize_file(engine.ptr(), cs, &ptr)
+	}
 	runtime.KeepAlive(engine)
 	C.free(unsafe.Pointer(cs))
 
@@ -201,6 +372,27 @@ func NewModuleDeserializeFile(engine *Engine, path string) (*Module, error) {
 	return mkModule(ptr), nil
 }
 
+// CustomSections returns the contents of every custom section named `name` in
+// the binary this module was compiled from, in the order they appear, such as
+// build IDs or other metadata embedded by the toolchain.
+//
+// Custom sections are retained from the binary passed to `NewModule`, and the
+// constructors built on it, so they aren't available for modules created with
+// `NewModuleDeserialize` or `NewModuleDeserializeFile`, for which nil is
+// returned. DWARF sections, whose names start with `.debug_`, are only
+// retained if `Config.SetSourceLocations` is enabled.
+func (m *Module) CustomSections(name string) [][]byte {
+	sections := m.customSections[name]
+	if sections == nil {
+		return nil
+	}
+	ret := make([][]byte, len(sections))
+	for i, section := range sections {
+		ret[i] = append([]byte(nil), section...)
+	}
+	return ret
+}
+
 // Serialize will convert this in-memory compiled module into a list of bytes.
 //
 // The purpose of this method is to extract an artifact which can be stored
@@ -208,6 +400,9 @@ func NewModuleDeserializeFile(engine *Engine, path string) (*Module, error) {
 // on disk or in an object store. The `NewModuleDeserialize` function can be
 // used to deserialize the returned bytes at a later date to get the module
 // back.
+//
+// The returned bytes contain machine code for the target of the `Engine` this
+// module was compiled with, see `Config.SetTarget`.
 func (m *Module) Serialize() ([]byte, error) {
 	retVec := C.wasm_byte_vec_t{}
 	err := C.wasmtime_module_serialize(m.ptr(), &retVec)
@@ -1,8 +1,13 @@
 package wasmtime
 
 import (
+	"bytes"
+	"errors"
 	"os"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/require"
 )
@@ -14,6 +19,108 @@ func TestModule(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestModuleFromReader(t *testing.T) {
+	engine := NewEngine()
+	wat := `(module (func (export "f")))`
+	wasm, err := Wat2Wasm(wat)
+	require.NoError(t, err)
+
+	module, err := NewModuleFromReader(engine, bytes.NewReader(wasm))
+	require.NoError(t, err)
+	require.Len(t, module.Exports(), 1)
+	module, err = NewModuleFromReader(engine, iotest.OneByteReader(strings.NewReader(wat)))
+	require.NoError(t, err)
+	require.Len(t, module.Exports(), 1)
+
+	_, err = NewModuleFromReader(engine, iotest.ErrReader(errors.New("download failed")))
+	require.EqualError(t, err, "download failed")
+}
+
+func TestModuleCustomSections(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`(module (func (export "f")))`)
+	require.NoError(t, err)
+	section := func(name, contents string) []byte {
+		payload := append([]byte{byte(len(name))}, name...)
+		payload = append(payload, contents...)
+		return append([]byte{0, byte(len(payload))}, payload...)
+	}
+	wasm = append(wasm, section("build-id", "abc")...)
+	wasm = append(wasm, section("config", "one")...)
+	wasm = append(wasm, section("config", "two")...)
+	wasm = append(wasm, section(".debug_info", "dwarf")...)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	require.Nil(t, module.CustomSections(".debug_info"), "DWARF isn't kept by default")
+
+	require.Equal(t, [][]byte{[]byte("abc")}, module.CustomSections("build-id"))
+	require.Equal(t, [][]byte{[]byte("one"), []byte("two")}, module.CustomSections("config"))
+	require.Nil(t, module.CustomSections("missing"))
+
+	module.CustomSections("build-id")[0][0] = 'x'
+	require.Equal(t, [][]byte{[]byte("abc")}, module.CustomSections("build-id"))
+
+	config := NewConfig()
+	config.SetSourceLocations(true)
+	module, err = NewModule(NewEngineWithConfig(config), wasm)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("dwarf")}, module.CustomSections(".debug_info"))
+}
+
+func TestModuleFunctionNames(t *testing.T) {
+	wasm, err := Wat2Wasm(`
+	(module $m
+	  (import "" "f" (func $imported))
+	  (func $first)
+	  (func)
+	  (func $third)
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	require.Equal(t, "m", module.Name())
+	require.Equal(t, map[uint32]string{0: "imported", 1: "first", 3: "third"}, module.FunctionNames())
+
+	wasm, err = Wat2Wasm(`(module (func))`)
+	require.NoError(t, err)
+	module, err = NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	require.Empty(t, module.FunctionNames())
+}
+
+func TestModuleProducers(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module)`)
+	require.NoError(t, err)
+	module, err := NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	require.Nil(t, module.Producers())
+
+	vec := func(s string) string { return string(rune(len(s))) + s }
+	producers := "\x02" +
+		vec("language") + "\x01" + vec("Rust") + vec("1.70") +
+		vec("processed-by") + "\x02" + vec("rustc") + vec("1.70") + vec("wasm-opt") + vec("114")
+	payload := vec("producers") + producers
+	wasm = append(wasm, 0, byte(len(payload)))
+	wasm = append(wasm, payload...)
+	module, err = NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]Producer{
+		"language":     {{"Rust", "1.70"}},
+		"processed-by": {{"rustc", "1.70"}, {"wasm-opt", "114"}},
+	}, module.Producers())
+
+	// truncated sections are ignored
+	wasm, err = Wat2Wasm(`(module)`)
+	require.NoError(t, err)
+	payload = vec("producers") + producers[:len(producers)-2]
+	wasm = append(wasm, 0, byte(len(payload)))
+	wasm = append(wasm, payload...)
+	module, err = NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	require.Nil(t, module.Producers())
+}
+
 func TestModuleValidate(t *testing.T) {
 	require.NotNil(t, ModuleValidate(NewEngine(), []byte{}), "expected an error")
 	require.NotNil(t, ModuleValidate(NewEngine(), []byte{1}), "expected an error")
@@ -120,3 +227,48 @@ func TestModuleSerialize(t *testing.T) {
 	_, err = NewModuleDeserializeFile(engine, tmpfile.Name())
 	require.NoError(t, err)
 }
+
+func TestCompatibleEngines(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module (func (export "f")))`)
+	require.NoError(t, err)
+	module, err := NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	artifact, err := module.Serialize()
+	require.NoError(t, err)
+
+	newEngine := func(fuel bool) *Engine {
+		config := NewConfig()
+		config.SetConsumeFuel(fuel)
+		return NewEngineWithConfig(config)
+	}
+	engines := []*Engine{newEngine(true), NewEngine(), newEngine(false), newEngine(true)}
+	require.Equal(t, []int{1, 2}, CompatibleEngines(artifact, engines))
+	require.Equal(t, []int{}, CompatibleEngines([]byte("garbage"), engines))
+}
+
+func TestModuleSerializeForTarget(t *testing.T) {
+	if runtime.GOARCH != "amd64" || runtime.GOOS != "linux" {
+		t.Skip("target triple is only known for linux/amd64")
+	}
+	newEngine := func() *Engine {
+		config := NewConfig()
+		require.NoError(t, config.SetTarget("x86_64-unknown-linux-gnu"))
+		return NewEngineWithConfig(config)
+	}
+	wasm, err := Wat2Wasm(`(module (func (export "f") (result i32) i32.const 1))`)
+	require.NoError(t, err)
+	module, err := NewModule(newEngine(), wasm)
+	require.NoError(t, err)
+	bytes, err := module.Serialize()
+	require.NoError(t, err)
+
+	engine := newEngine()
+	module, err = NewModuleDeserialize(engine, bytes)
+	require.NoError(t, err)
+	store := NewStore(engine)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	result, err := instance.GetFunc(store, "f").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result)
+}
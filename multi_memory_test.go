@@ -74,3 +74,39 @@ func TestMultiMemoryImported(t *testing.T) {
 	require.IsType(t, res, int32(0))
 	require.Equal(t, int32(100), res.(int32))
 }
+
+func TestMultiMemoryByName(t *testing.T) {
+	wasm, err := Wat2Wasm(`
+    (module
+      (import "" "peek" (func $peek))
+      (memory (export "private") 1)
+      (memory $scratch (export "scratch") 2)
+      (func (export "f")
+        (i32.store8 $scratch (i32.const 0) (i32.const 7))
+        call $peek)
+      (func (export "g"))
+    )`)
+	require.NoError(t, err)
+	store := multiMemoryStore()
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+
+	var seen byte
+	peek := WrapFunc(store, func(caller *Caller) {
+		require.Nil(t, caller.GetMemory("f"))
+		require.Nil(t, caller.GetMemory("missing"))
+		seen = caller.GetMemory("scratch").UnsafeData(caller)[0]
+	})
+	instance, err := NewInstance(store, module, []AsExtern{peek})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "f").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, byte(7), seen)
+
+	require.Equal(t, uint64(1), instance.GetMemory(store, "private").Size(store))
+	require.Equal(t, uint64(2), instance.GetMemory(store, "scratch").Size(store))
+	require.Nil(t, instance.GetMemory(store, "g"))
+	memories := instance.Memories(store)
+	require.Len(t, memories, 2)
+	require.Equal(t, uint64(2), memories["scratch"].Size(store))
+}
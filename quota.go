@@ -0,0 +1,189 @@
+package wasmtime
+
+// #include <wasmtime.h>
+import "C"
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// QuotaLimits are the limits shared by all of the stores in a `QuotaGroup`.
+// A limit of zero means that resource isn't limited.
+type QuotaLimits struct {
+	// The total amount of fuel that may be consumed by all stores in the
+	// group. This requires `Config.SetConsumeFuel`.
+	Fuel uint64
+	// The total size, in bytes, of the linear memories in all stores in the
+	// group.
+	MemorySize uint64
+	// The total number of instances alive in all stores in the group.
+	Instances int
+}
+
+// QuotaUsage describes the resources currently used by a `QuotaGroup`.
+type QuotaUsage struct {
+	Fuel       uint64
+	MemorySize uint64
+	Instances  int
+}
+
+// QuotaGroup aggregates the resource limits of a group of stores, for example
+// all of the stores belonging to one tenant of a shared `Engine`, rather than
+// limiting each store individually. Stores are assigned to a group with
+// `QuotaGroup.Add` and leave it when they're garbage collected.
+//
+// Instance limits are enforced exactly. Fuel and memory are accounted when
+// outermost calls into WebAssembly return, and each call is allowed to consume
+// whatever remains of the group's quota when it starts, so concurrent calls in
+// different stores of the same group can together exceed the group's limits.
+// Memory growth is only limited during calls made with `Func.Call`, and by
+// `Memory.Grow`.
+//
+// A `QuotaGroup` is safe to use from multiple goroutines.
+type QuotaGroup struct {
+	engine *Engine
+	limits QuotaLimits
+
+	mu    sync.Mutex
+	usage QuotaUsage
+}
+
+// NewQuotaGroup creates a new `QuotaGroup` for stores of this engine, with the
+// given limits.
+func (engine *Engine) NewQuotaGroup(limits QuotaLimits) *QuotaGroup {
+	return &QuotaGroup{engine: engine, limits: limits}
+}
+
+// Add assigns `store` to this group, charging the group for the resources the
+// store already uses. Returns an error if the store belongs to a different
+// engine or is already part of a group.
+func (g *QuotaGroup) Add(store *Store) error {
+	if store.Engine != g.engine {
+		return errors.New("store does not belong to the quota group's engine")
+	}
+	data := getDataInStore(store)
+	if data.quota != nil {
+		return errors.New("store is already part of a quota group")
+	}
+	data.quota = g
+	data.quotaMemory = data.totalMemory(store)
+	data.quotaInstances = len(data.instanceModules)
+	g.mu.Lock()
+	g.usage.MemorySize += data.quotaMemory
+	g.usage.Instances += data.quotaInstances
+	g.mu.Unlock()
+	return nil
+}
+
+// Usage returns the resources currently used by the stores in this group.
+func (g *QuotaGroup) Usage() QuotaUsage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.usage
+}
+
+// Returns how much more memory the group may use, and whether memory is
+// limited at all.
+func (g *QuotaGroup) memoryRemaining() (uint64, bool) {
+	if g.limits.MemorySize == 0 {
+		return 0, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.usage.MemorySize >= g.limits.MemorySize {
+		return 0, true
+	}
+	return g.limits.MemorySize - g.usage.MemorySize, true
+}
+
+// Reserves one instance for a store in the group, returning an error if the
+// group has no instances left.
+func (g *QuotaGroup) reserveInstance(data *storeData) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.limits.Instances > 0 && g.usage.Instances >= g.limits.Instances {
+		return data.limitDenied(errors.New("quota group instance limit exceeded"))
+	}
+	g.usage.Instances++
+	data.quotaInstances++
+	return nil
+}
+
+// Releases an instance reserved with `reserveInstance` which was never
+// created.
+func (g *QuotaGroup) releaseInstance(data *storeData) {
+	g.mu.Lock()
+	g.usage.Instances--
+	data.quotaInstances--
+	g.mu.Unlock()
+}
+
+// Funds an outermost call into WebAssembly from a store in this group with
+// the group's remaining fuel. Returns a function which charges the group for
+// the fuel and memory used once the call has returned.
+func (g *QuotaGroup) beginCall(store Storelike, data *storeData) func() {
+	grant := uint64(0)
+	if g.limits.Fuel > 0 {
+		g.mu.Lock()
+		if g.usage.Fuel < g.limits.Fuel {
+			grant = g.limits.Fuel - g.usage.Fuel
+		}
+		g.mu.Unlock()
+	}
+	consumed := C.uint64_t(0)
+	if grant > 0 {
+		if err := C.wasmtime_context_add_fuel(store.Context(), C.uint64_t(grant)); err != nil {
+			mkError(err)
+			grant = 0
+		}
+		C.wasmtime_context_fuel_consumed(store.Context(), &consumed)
+	}
+	return func() {
+		used := uint64(0)
+		if grant > 0 {
+			after := C.uint64_t(0)
+			C.wasmtime_context_fuel_consumed(store.Context(), &after)
+			used = uint64(after - consumed)
+			if used > grant {
+				used = grant
+			}
+			// Take back whatever part of the grant wasn't used.
+			remaining := C.uint64_t(0)
+			if err := C.wasmtime_context_consume_fuel(store.Context(), C.uint64_t(grant-used), &remaining); err != nil {
+				mkError(err)
+			}
+		}
+		g.charge(store, data, used)
+	}
+}
+
+// Charges the group for `fuel` consumed by a store and updates the memory
+// that store is charged for.
+func (g *QuotaGroup) charge(store Storelike, data *storeData, fuel uint64) {
+	memory := data.totalMemory(store)
+	g.mu.Lock()
+	g.usage.Fuel += fuel
+	g.usage.MemorySize = g.usage.MemorySize - data.quotaMemory + memory
+	g.mu.Unlock()
+	data.quotaMemory = memory
+}
+
+// Removes a store which is being finalized from its group.
+func (g *QuotaGroup) remove(data *storeData) {
+	g.mu.Lock()
+	g.usage.MemorySize -= data.quotaMemory
+	g.usage.Instances -= data.quotaInstances
+	g.mu.Unlock()
+}
+
+// Returns the total size, in bytes, of all linear memories known to live in
+// this store.
+func (data *storeData) totalMemory(store Storelike) uint64 {
+	total := uint64(0)
+	for i := range data.memories {
+		total += uint64(C.wasmtime_memory_data_size(store.Context(), &data.memories[i]))
+	}
+	runtime.KeepAlive(store)
+	return total
+}
@@ -0,0 +1,88 @@
+package wasmtime
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaGroup(t *testing.T) {
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	engine := NewEngineWithConfig(config)
+	wasm, err := Wat2Wasm(`
+	(module
+	  (memory (export "memory") 1)
+	  (func (export "spin") (param i32)
+	    (loop
+	      (local.set 0 (i32.sub (local.get 0) (i32.const 1)))
+	      (br_if 0 (local.get 0))))
+	  (func (export "grow") (param i32) (result i32)
+	    (memory.grow (local.get 0)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	group := engine.NewQuotaGroup(QuotaLimits{Fuel: 10000, MemorySize: 3 * 65536, Instances: 2})
+	a, b := NewStore(engine), NewStore(engine)
+	require.NoError(t, group.Add(a))
+	require.NoError(t, group.Add(b))
+	require.Error(t, group.Add(a))
+	require.Error(t, NewEngine().NewQuotaGroup(QuotaLimits{}).Add(NewStore(engine)))
+
+	instA, err := NewInstance(a, module, []AsExtern{})
+	require.NoError(t, err)
+	instB, err := NewInstance(b, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = NewInstance(b, module, []AsExtern{})
+	require.Error(t, err)
+	require.Equal(t, QuotaUsage{MemorySize: 2 * 65536, Instances: 2}, group.Usage())
+
+	// fuel is drawn from the group by both stores
+	_, err = instA.GetFunc(a, "spin").Call(a, 100)
+	require.NoError(t, err)
+	used := group.Usage().Fuel
+	require.Greater(t, used, uint64(100))
+	_, err = instB.GetFunc(b, "spin").Call(b, 100)
+	require.NoError(t, err)
+	require.Greater(t, group.Usage().Fuel, used)
+	_, err = instA.GetFunc(a, "spin").Call(a, 100000)
+	require.Error(t, err)
+	require.Equal(t, uint64(10000), group.Usage().Fuel)
+	_, err = instB.GetFunc(b, "spin").Call(b, 1)
+	require.Error(t, err)
+
+	// memory is shared between the stores
+	fuelless := engine.NewQuotaGroup(QuotaLimits{MemorySize: 3 * 65536})
+	c, d := NewStore(engine), NewStore(engine)
+	require.NoError(t, fuelless.Add(c))
+	require.NoError(t, fuelless.Add(d))
+	require.NoError(t, c.AddFuel(100000))
+	require.NoError(t, d.AddFuel(100000))
+	instC, err := NewInstance(c, module, []AsExtern{})
+	require.NoError(t, err)
+	instD, err := NewInstance(d, module, []AsExtern{})
+	require.NoError(t, err)
+	result, err := instC.GetFunc(c, "grow").Call(c, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result)
+	require.Equal(t, uint64(3*65536), fuelless.Usage().MemorySize)
+	result, err = instD.GetFunc(d, "grow").Call(d, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), result)
+	_, err = instD.GetExport(d, "memory").Memory().Grow(d, 1)
+	require.Error(t, err)
+
+	// stores leave their group when they're garbage collected
+	runtime.KeepAlive(c)
+	c = nil
+	for i := 0; i < 10 && fuelless.Usage().Instances > 1; i++ {
+		runtime.GC()
+	}
+	require.Equal(t, QuotaUsage{MemorySize: 65536, Instances: 1}, fuelless.Usage())
+	runtime.KeepAlive(a)
+	runtime.KeepAlive(d)
+}
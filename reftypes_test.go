@@ -242,3 +242,78 @@ func TestFuncFinalizer(t *testing.T) {
 		require.Less(t, i, 10000)
 	}
 }
+
+func TestExternRefOf(t *testing.T) {
+	instance, store := refTypesInstance(t, `
+(module
+  (table $t (export "t") 2 externref)
+  (func (export "set") (param i32 externref)
+    (table.set $t (local.get 0) (local.get 1)))
+)
+	`)
+	type object struct{ name string }
+	a, b := &object{"a"}, &object{"b"}
+	require.Equal(t, store.ExternRefOf(a), store.ExternRefOf(a))
+	require.NotEqual(t, store.ExternRefOf(a), store.ExternRefOf(b))
+	require.Equal(t, store.ExternRefOf("x"), store.ExternRefOf("x"))
+	require.NotEqual(t, store.ExternRefOf([]int{1}), store.ExternRefOf([]int{1}))
+	type boxed struct{ v interface{} }
+	unhashable := boxed{[]int{1}}
+	require.Equal(t, unhashable, store.ExternRefOf(unhashable).Externref())
+	require.NotEqual(t, store.ExternRefOf(unhashable), store.ExternRefOf(unhashable))
+	require.Equal(t, store.ExternRefOf(boxed{1}), store.ExternRefOf(boxed{1}))
+	require.Nil(t, store.ExternRefOf(nil).Externref())
+
+	set := instance.GetFunc(store, "set")
+	_, err := set.Call(store, 0, store.ExternRefOf(a))
+	require.NoError(t, err)
+	_, err = set.Call(store, 1, store.ExternRefOf(a))
+	require.NoError(t, err)
+	table := instance.GetExport(store, "t").Table()
+	for i := uint32(0); i < 2; i++ {
+		val, err := table.Get(store, i)
+		require.NoError(t, err)
+		require.Same(t, a, val.Externref())
+	}
+	require.NotEqual(t, store.ExternRefOf(a), NewStore(store.Engine).ExternRefOf(a))
+}
+
+func TestRefTypesFinalizer(t *testing.T) {
+	instance, store := refTypesInstance(t, `
+(module
+  (table $t 1 externref)
+  (func (export "id") (param externref) (result externref)
+    local.get 0)
+  (func (export "stash") (param externref)
+    (table.set $t (i32.const 0) (local.get 0)))
+  (func (export "clear")
+    (table.set $t (i32.const 0) (ref.null extern)))
+)
+`)
+	type conn struct{ closed bool }
+	c := &conn{}
+	finalized := 0
+	ref := ValExternrefWithFinalizer(c, func(val interface{}) {
+		finalized++
+		val.(*conn).closed = true
+	})
+
+	results := make([]Val, 1)
+	require.NoError(t, instance.GetFunc(store, "id").CallInto(store, []Val{ref}, results))
+	require.Same(t, c, results[0].Externref())
+	require.NoError(t, instance.GetFunc(store, "stash").CallInto(store, []Val{ref}, nil))
+
+	ref.Close()
+	results[0].Close()
+	require.Nil(t, ref.Externref())
+	store.GC()
+	require.Zero(t, finalized, "still referenced by the table")
+
+	require.NoError(t, instance.GetFunc(store, "clear").CallInto(store, nil, nil))
+	store.GC()
+	require.Equal(t, 1, finalized)
+	require.True(t, c.closed)
+
+	ValExternref(1).Close()
+	ValI32(1).Close()
+}
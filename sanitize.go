@@ -0,0 +1,168 @@
+package wasmtime
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// SanitizeOptions configures a `SanitizingWriter`.
+type SanitizeOptions struct {
+	// Remove ANSI escape sequences, such as colors, cursor movement and
+	// terminal title changes.
+	StripANSI bool
+	// Remove control characters other than tab, newline and carriage return.
+	StripControl bool
+	// Replace invalid UTF-8 with U+FFFD, the Unicode replacement character.
+	ReplaceInvalidUTF8 bool
+}
+
+// SanitizingWriter cleans up untrusted output, such as that of a guest, before
+// writing it to another writer, so that it can be safely embedded in logs and
+// web UIs. It's typically used along with `WasiConfig.SetStdoutWriter` and
+// `WasiConfig.SetStderrWriter`.
+//
+// Escape sequences and multi-byte characters may be split across writes, so
+// the end of what's written may be held back until more is written or the
+// writer is flushed.
+type SanitizingWriter struct {
+	w       io.Writer
+	opts    SanitizeOptions
+	pending []byte
+}
+
+// Escape sequences which haven't been terminated after this many bytes are
+// dropped, rather than being held back indefinitely.
+const maxPendingEscape = 1024
+
+// NewSanitizingWriter returns a writer which writes everything written to it
+// to `w`, after sanitizing it according to `opts`.
+func NewSanitizingWriter(w io.Writer, opts SanitizeOptions) *SanitizingWriter {
+	return &SanitizingWriter{w: w, opts: opts}
+}
+
+// Write sanitizes `p` and writes the result to the underlying writer.
+func (s *SanitizingWriter) Write(p []byte) (int, error) {
+	buf := append(s.pending, p...)
+	out, rest := s.sanitize(buf, false)
+	s.pending = append([]byte(nil), rest...)
+	if len(out) > 0 {
+		if _, err := s.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes out anything held back by previous writes, replacing an
+// incomplete character or dropping an incomplete escape sequence, and then
+// flushes the underlying writer if it has a `Flush() error` method.
+func (s *SanitizingWriter) Flush() error {
+	out, _ := s.sanitize(s.pending, true)
+	s.pending = nil
+	if len(out) > 0 {
+		if _, err := s.w.Write(out); err != nil {
+			return err
+		}
+	}
+	if f, ok := s.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Returns the sanitized version of `buf`, along with a suffix of `buf` which
+// can't be sanitized until more is known, unless `final` is set.
+func (s *SanitizingWriter) sanitize(buf []byte, final bool) (out, rest []byte) {
+	out = make([]byte, 0, len(buf))
+	for i := 0; i < len(buf); {
+		b := buf[i]
+		switch {
+		case b == 0x1b && s.opts.StripANSI:
+			n := ansiEscapeLen(buf[i:])
+			if n < 0 {
+				if final || len(buf)-i > maxPendingEscape {
+					return out, nil
+				}
+				return out, buf[i:]
+			}
+			i += n
+		case b < utf8.RuneSelf:
+			if !s.opts.StripControl || !isControl(rune(b)) {
+				out = append(out, b)
+			}
+			i++
+		case !s.opts.StripControl && !s.opts.ReplaceInvalidUTF8:
+			out = append(out, b)
+			i++
+		default:
+			if !final && !utf8.FullRune(buf[i:]) {
+				return out, buf[i:]
+			}
+			r, size := utf8.DecodeRune(buf[i:])
+			switch {
+			case r == utf8.RuneError && size <= 1:
+				if s.opts.ReplaceInvalidUTF8 {
+					out = append(out, "\uFFFD"...)
+				} else {
+					out = append(out, b)
+				}
+				size = 1
+			case !s.opts.StripControl || !isControl(r):
+				out = append(out, buf[i:i+size]...)
+			}
+			i += size
+		}
+	}
+	return out, nil
+}
+
+// Returns whether `r` is a C0 or C1 control character other than tab, newline
+// and carriage return.
+func isControl(r rune) bool {
+	if r == '\t' || r == '\n' || r == '\r' {
+		return false
+	}
+	return r < 0x20 || (r >= 0x7f && r < 0xa0)
+}
+
+// Returns the length of the escape sequence at the start of `p`, which starts
+// with ESC, or -1 if it's incomplete.
+func ansiEscapeLen(p []byte) int {
+	if len(p) < 2 {
+		return -1
+	}
+	switch p[1] {
+	case '[':
+		// Control sequence: parameter bytes, then intermediate bytes, then a
+		// final byte.
+		i := 2
+		for i < len(p) && p[i] >= 0x30 && p[i] <= 0x3f {
+			i++
+		}
+		for i < len(p) && p[i] >= 0x20 && p[i] <= 0x2f {
+			i++
+		}
+		if i == len(p) {
+			return -1
+		}
+		if p[i] >= 0x40 && p[i] <= 0x7e {
+			return i + 1
+		}
+		return i
+	case ']', 'P', 'X', '^', '_':
+		// Command strings, terminated by BEL or ST.
+		for i := 2; i < len(p); i++ {
+			if p[i] == 0x07 {
+				return i + 1
+			}
+			if p[i] == 0x1b && i+1 < len(p) && p[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return -1
+	}
+	if p[1] >= 0x20 && p[1] <= 0x7e {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,38 @@
+package wasmtime
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizingWriter(t *testing.T) {
+	sanitize := func(opts SanitizeOptions, writes ...string) string {
+		var buf bytes.Buffer
+		w := NewSanitizingWriter(&buf, opts)
+		for _, write := range writes {
+			n, err := w.Write([]byte(write))
+			require.NoError(t, err)
+			require.Equal(t, len(write), n)
+		}
+		require.NoError(t, w.Flush())
+		return buf.String()
+	}
+	all := SanitizeOptions{StripANSI: true, StripControl: true, ReplaceInvalidUTF8: true}
+
+	require.Equal(t, "plain\ttext\r\n", sanitize(all, "plain\ttext\r\n"))
+	require.Equal(t, "red plain", sanitize(all, "\x1b[1;31mred\x1b[0m plain"))
+	require.Equal(t, "red plain", sanitize(all, "\x1b[1;", "31mred\x1b", "[0m plain"))
+	require.Equal(t, "ab", sanitize(all, "a\x1b]0;title\x07b"))
+	require.Equal(t, "ab", sanitize(all, "a\x1b]0;title\x1b\\b"))
+	require.Equal(t, "ab", sanitize(all, "a\x00\x08\x7fb"))
+	require.Equal(t, "héllo", sanitize(all, "h\xc3", "\xa9llo"))
+	require.Equal(t, "a�b�", sanitize(all, "a\xffb\xc3"))
+	require.Equal(t, "ab", sanitize(all, "a\u0085b"))
+	require.Equal(t, "a", sanitize(all, "a\x1b[31"))
+
+	require.Equal(t, "\x1b[31mred\xff", sanitize(SanitizeOptions{}, "\x1b[31mred\xff"))
+	require.Equal(t, "[31mred\xff", sanitize(SanitizeOptions{StripControl: true}, "\x1b[31mred\xff"))
+	require.Equal(t, "red�", sanitize(SanitizeOptions{StripANSI: true, ReplaceInvalidUTF8: true}, "\x1b[31mred\xff"))
+}
@@ -0,0 +1,95 @@
+package wasmtime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSignature is returned by `NewModuleDeserializeVerified` when an
+// artifact isn't signed, or its signature doesn't match the public key.
+var ErrInvalidSignature = errors.New("precompiled module has a missing or invalid signature")
+
+// Trailer appended to signed artifacts, after the signature and its
+// little-endian 32-bit length.
+const signedModuleMagic = "wtgosig1"
+
+// SignSerialized signs `artifact`, the output of `Module.Serialize`, with
+// `key`, returning the artifact with the signature appended so that it can be
+// loaded with `NewModuleDeserializeVerified`.
+//
+// `key` must be an Ed25519 or ECDSA private key, such as an
+// `ed25519.PrivateKey` or `*ecdsa.PrivateKey`. ECDSA signatures are computed
+// over the SHA-256 digest of the artifact.
+func SignSerialized(artifact []byte, key crypto.Signer) ([]byte, error) {
+	var sig []byte
+	var err error
+	switch key.Public().(type) {
+	case ed25519.PublicKey:
+		sig, err = key.Sign(rand.Reader, artifact, crypto.Hash(0))
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(artifact)
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key.Public())
+	}
+	if err != nil {
+		return nil, err
+	}
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(sig)))
+	signed := make([]byte, 0, len(artifact)+len(sig)+len(n)+len(signedModuleMagic))
+	signed = append(signed, artifact...)
+	signed = append(signed, sig...)
+	signed = append(signed, n[:]...)
+	return append(signed, signedModuleMagic...), nil
+}
+
+// NewModuleDeserializeVerified is like `NewModuleDeserialize`, but first
+// checks that `signed` was signed by `SignSerialized` with the private key of
+// `pubkey`, which must be an `ed25519.PublicKey` or `*ecdsa.PublicKey`.
+//
+// Deserializing is only safe for trusted artifacts, so this should be used
+// in place of `NewModuleDeserialize` whenever artifacts are loaded from
+// storage that isn't trusted as much as the host itself. Returns
+// `ErrInvalidSignature` if the signature is missing or doesn't verify.
+func NewModuleDeserializeVerified(engine *Engine, signed []byte, pubkey crypto.PublicKey) (*Module, error) {
+	artifact, sig, ok := splitSignedModule(signed)
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	switch key := pubkey.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, artifact, sig)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(artifact)
+		ok = ecdsa.VerifyASN1(key, digest[:], sig)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pubkey)
+	}
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	return NewModuleDeserialize(engine, artifact)
+}
+
+// Splits an artifact produced by `SignSerialized` into the original artifact
+// and its signature.
+func splitSignedModule(signed []byte) (artifact, sig []byte, ok bool) {
+	trailer := 4 + len(signedModuleMagic)
+	if len(signed) < trailer || !bytes.HasSuffix(signed, []byte(signedModuleMagic)) {
+		return nil, nil, false
+	}
+	end := len(signed) - trailer
+	n := uint64(binary.LittleEndian.Uint32(signed[end:]))
+	if n > uint64(end) {
+		return nil, nil, false
+	}
+	return signed[:end-int(n)], signed[end-int(n) : end], true
+}
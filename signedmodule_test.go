@@ -0,0 +1,65 @@
+package wasmtime
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewModuleDeserializeVerified(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`(module (func (export "f")))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	artifact, err := module.Serialize()
+	require.NoError(t, err)
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		name string
+		sign func([]byte) ([]byte, error)
+		pub  interface{}
+	}{
+		{"ed25519", func(b []byte) ([]byte, error) { return SignSerialized(b, edKey) }, edPub},
+		{"ecdsa", func(b []byte) ([]byte, error) { return SignSerialized(b, ecKey) }, &ecKey.PublicKey},
+	} {
+		signed, err := c.sign(artifact)
+		require.NoError(t, err, c.name)
+		verified, err := NewModuleDeserializeVerified(engine, signed, c.pub)
+		require.NoError(t, err, c.name)
+		require.Len(t, verified.Exports(), 1)
+
+		tampered := append([]byte(nil), signed...)
+		tampered[len(artifact)/2] ^= 1
+		_, err = NewModuleDeserializeVerified(engine, tampered, c.pub)
+		require.ErrorIs(t, err, ErrInvalidSignature, c.name)
+	}
+
+	signed, err := SignSerialized(artifact, edKey)
+	require.NoError(t, err)
+	_, err = NewModuleDeserializeVerified(engine, signed, otherPub)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = NewModuleDeserializeVerified(engine, artifact, edPub)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = NewModuleDeserializeVerified(engine, signed[len(signed)-12:], edPub)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = SignSerialized(artifact, rsaKey)
+	require.Error(t, err)
+	_, err = NewModuleDeserializeVerified(engine, signed, &rsaKey.PublicKey)
+	require.Error(t, err)
+}
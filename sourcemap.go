@@ -0,0 +1,136 @@
+package wasmtime
+
+import (
+	"debug/dwarf"
+	"sort"
+)
+
+// SourceLocation is a position in the source code a module was compiled
+// from, see `Frame.SourceLocation`.
+type SourceLocation struct {
+	File string
+	// Line and Column are 1-based, and are 0 when they aren't known.
+	Line   int
+	Column int
+}
+
+// A row of a module's line table, covering the instructions from `address`
+// up to the next row's address.
+type sourceLine struct {
+	address uint64
+	loc     *SourceLocation
+}
+
+// SourceLocation returns the location in the source code of the instruction
+// at `offset` in the binary this module was compiled from, found in the
+// module's DWARF debug info.
+//
+// Returns nil if the module has no DWARF debug info, which toolchains only
+// emit when building with debug info enabled (such as `-g` for clang), if
+// the instruction isn't covered by it, or if the module was created with
+// `NewModuleDeserialize` or `NewModuleDeserializeFile`. The debug info is
+// only kept if the engine's config enabled `Config.SetSourceLocations`.
+func (m *Module) SourceLocation(offset uint) *SourceLocation {
+	m.linesOnce.Do(func() {
+		m.lines = m.parseLines()
+	})
+	if m.codeOffset < 0 || offset < uint(m.codeOffset) {
+		return nil
+	}
+	address := uint64(offset - uint(m.codeOffset))
+	i := sort.Search(len(m.lines), func(i int) bool {
+		return m.lines[i].address > address
+	})
+	if i == 0 {
+		return nil
+	}
+	if loc := m.lines[i-1].loc; loc != nil {
+		ret := *loc
+		return &ret
+	}
+	return nil
+}
+
+// SourceLocation returns the location in the source code of this frame's
+// instruction, as with `Module.SourceLocation`, where `module` is the module
+// the frame's function belongs to.
+func (f *Frame) SourceLocation(module *Module) *SourceLocation {
+	return module.SourceLocation(f.ModuleOffset())
+}
+
+// Parses the line table of this module's DWARF debug info, returning the rows
+// sorted by address. The end of each sequence is recorded as a row with a nil
+// location.
+func (m *Module) parseLines() []sourceLine {
+	section := func(name string) []byte {
+		if sections := m.customSections[name]; len(sections) > 0 {
+			return sections[0]
+		}
+		return nil
+	}
+	if section(".debug_info") == nil || section(".debug_line") == nil {
+		return nil
+	}
+	data, err := dwarf.New(
+		section(".debug_abbrev"),
+		section(".debug_aranges"),
+		section(".debug_frame"),
+		section(".debug_info"),
+		section(".debug_line"),
+		section(".debug_pubnames"),
+		section(".debug_ranges"),
+		section(".debug_str"),
+	)
+	if err != nil {
+		return nil
+	}
+	// Sections which are new in DWARF 5, the default of recent versions of
+	// clang, where the compile unit refers to strings and addresses by index
+	// and the line table keeps file names separately.
+	for _, name := range []string{".debug_addr", ".debug_line_str", ".debug_str_offsets", ".debug_rnglists"} {
+		if contents := section(name); contents != nil {
+			if err := data.AddSection(name, contents); err != nil {
+				return nil
+			}
+		}
+	}
+
+	var ret []sourceLine
+	reader := data.Reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil || entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			reader.SkipChildren()
+			continue
+		}
+		lines, err := data.LineReader(entry)
+		reader.SkipChildren()
+		if err != nil || lines == nil {
+			continue
+		}
+		var row dwarf.LineEntry
+		for lines.Next(&row) == nil {
+			line := sourceLine{address: row.Address}
+			if !row.EndSequence {
+				line.loc = &SourceLocation{Line: row.Line, Column: row.Column}
+				if row.File != nil {
+					line.loc.File = row.File.Name
+				}
+			}
+			ret = append(ret, line)
+		}
+	}
+	// Lookups use the last row at an address, so the end of a sequence goes
+	// before any other rows at the same address, which otherwise keep their
+	// order.
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].address != ret[j].address {
+			return ret[i].address < ret[j].address
+		}
+		return ret[i].loc == nil && ret[j].loc != nil
+	})
+	return ret
+}
@@ -0,0 +1,188 @@
+package wasmtime
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func le32(n int) []byte {
+	var ret [4]byte
+	binary.LittleEndian.PutUint32(ret[:], uint32(n))
+	return ret[:]
+}
+
+// Returns minimal DWARF sections describing a single compile unit, "main.c",
+// whose line table maps code offsets 2 and 4 to lines 10 and 11.
+func testDebugSections() map[string][]byte {
+	abbrev := []byte{
+		1, 0x11, 0, // abbrev 1: DW_TAG_compile_unit, no children
+		0x03, 0x08, // DW_AT_name, DW_FORM_string
+		0x10, 0x17, // DW_AT_stmt_list, DW_FORM_sec_offset
+		0, 0,
+		0,
+	}
+
+	die := []byte{4, 0, 0, 0, 0, 0, 4, 1}
+	die = append(die, "main.c\x00"...)
+	die = append(die, 0, 0, 0, 0)
+	info := le32(len(die))
+	info = append(info, die...)
+
+	header := []byte{1, 1, 1, 0xfb, 14, 13, 0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1}
+	header = append(header, 0)
+	header = append(header, "main.c\x00"...)
+	header = append(header, 0, 0, 0, 0)
+	program := []byte{
+		0, 5, 2, 2, 0, 0, 0, // DW_LNE_set_address 2
+		3, 9, // DW_LNS_advance_line 9
+		1,    // DW_LNS_copy
+		2, 2, // DW_LNS_advance_pc 2
+		3, 1, // DW_LNS_advance_line 1
+		5, 3, // DW_LNS_set_column 3
+		1,    // DW_LNS_copy
+		2, 2, // DW_LNS_advance_pc 2
+		0, 1, 1, // DW_LNE_end_sequence
+	}
+	unit := []byte{4, 0}
+	unit = append(unit, le32(len(header))...)
+	unit = append(unit, header...)
+	unit = append(unit, program...)
+	line := le32(len(unit))
+	line = append(line, unit...)
+
+	return map[string][]byte{".debug_abbrev": abbrev, ".debug_info": info, ".debug_line": line}
+}
+
+// Returns the same debug info as `testDebugSections` in the DWARF 5 format
+// emitted by default by recent versions of clang, where strings and
+// addresses are referred to by index, and the line table's file names are
+// kept in `.debug_line_str`.
+func testDebugSections5() map[string][]byte {
+	abbrev := []byte{
+		1, 0x11, 0, // abbrev 1: DW_TAG_compile_unit, no children
+		0x03, 0x25, // DW_AT_name, DW_FORM_strx1
+		0x72, 0x17, // DW_AT_str_offsets_base, DW_FORM_sec_offset
+		0x10, 0x17, // DW_AT_stmt_list, DW_FORM_sec_offset
+		0x11, 0x1b, // DW_AT_low_pc, DW_FORM_addrx
+		0x73, 0x17, // DW_AT_addr_base, DW_FORM_sec_offset
+		0, 0,
+		0,
+	}
+
+	die := []byte{5, 0, 1, 4, 0, 0, 0, 0, 1}
+	die = append(die, 0)          // string 0
+	die = append(die, le32(8)...) // after the .debug_str_offsets header
+	die = append(die, le32(0)...)
+	die = append(die, 0)          // address 0
+	die = append(die, le32(8)...) // after the .debug_addr header
+	info := le32(len(die))
+	info = append(info, die...)
+
+	// clang puts the producer first.
+	str := []byte("clang version 17\x00main.c\x00")
+	strOffsets := append(le32(8), 5, 0, 0, 0)
+	strOffsets = append(strOffsets, le32(17)...)
+	addr := append(le32(8), 5, 0, 4, 0)
+	addr = append(addr, le32(0)...)
+
+	lineStr := []byte("/src\x00main.c\x00")
+	header := []byte{1, 1, 1, 0xfb, 14, 13, 0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1}
+	header = append(header, 1, 0x01, 0x1f) // directories: DW_LNCT_path, DW_FORM_line_strp
+	header = append(header, 1)
+	header = append(header, le32(0)...)
+	header = append(header, 2, 0x01, 0x1f, 0x02, 0x0f) // files: path, DW_LNCT_directory_index, DW_FORM_udata
+	header = append(header, 1)
+	header = append(header, le32(5)...)
+	header = append(header, 0)
+	program := []byte{
+		4, 0, // DW_LNS_set_file 0
+		0, 5, 2, 2, 0, 0, 0, // DW_LNE_set_address 2
+		3, 9, // DW_LNS_advance_line 9
+		1,    // DW_LNS_copy
+		2, 2, // DW_LNS_advance_pc 2
+		3, 1, // DW_LNS_advance_line 1
+		5, 3, // DW_LNS_set_column 3
+		1,    // DW_LNS_copy
+		2, 2, // DW_LNS_advance_pc 2
+		0, 1, 1, // DW_LNE_end_sequence
+	}
+	unit := []byte{5, 0, 4, 0}
+	unit = append(unit, le32(len(header))...)
+	unit = append(unit, header...)
+	unit = append(unit, program...)
+	line := le32(len(unit))
+	line = append(line, unit...)
+
+	return map[string][]byte{
+		".debug_abbrev":      abbrev,
+		".debug_info":        info,
+		".debug_line":        line,
+		".debug_str":         str,
+		".debug_str_offsets": strOffsets,
+		".debug_addr":        addr,
+		".debug_line_str":    lineStr,
+	}
+}
+
+// Returns `wasm` with a custom section appended for each of `sections`.
+func withCustomSections(wasm []byte, sections map[string][]byte) []byte {
+	for name, contents := range sections {
+		payload := append([]byte{byte(len(name))}, name...)
+		payload = append(payload, contents...)
+		wasm = append(wasm, 0, byte(len(payload)))
+		wasm = append(wasm, payload...)
+	}
+	return wasm
+}
+
+func TestSourceLocation(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module (func (export "f") nop unreachable))`)
+	require.NoError(t, err)
+	wasm = withCustomSections(wasm, testDebugSections())
+	module, err := NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	require.Nil(t, module.SourceLocation(uint(wasmCodeOffset(wasm))+2), "debug info isn't kept by default")
+
+	config := NewConfig()
+	config.SetSourceLocations(true)
+	store := NewStore(NewEngineWithConfig(config))
+	module, err = NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+
+	_, err = instance.GetFunc(store, "f").Call(store)
+	require.Error(t, err)
+	frames := err.(*Trap).Frames()
+	require.Len(t, frames, 1)
+	require.Equal(t, &SourceLocation{File: "main.c", Line: 11, Column: 3}, frames[0].SourceLocation(module))
+
+	require.Equal(t, &SourceLocation{File: "main.c", Line: 10}, module.SourceLocation(frames[0].ModuleOffset()-1))
+	require.Nil(t, module.SourceLocation(frames[0].ModuleOffset()+2))
+	require.Nil(t, module.SourceLocation(0))
+}
+
+func TestSourceLocationDWARF5(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module (func (export "f") nop unreachable))`)
+	require.NoError(t, err)
+	wasm = withCustomSections(wasm, testDebugSections5())
+	config := NewConfig()
+	config.SetSourceLocations(true)
+	module, err := NewModule(NewEngineWithConfig(config), wasm)
+	require.NoError(t, err)
+	code := uint(wasmCodeOffset(wasm))
+	require.Equal(t, &SourceLocation{File: "/src/main.c", Line: 10}, module.SourceLocation(code+2))
+	require.Equal(t, &SourceLocation{File: "/src/main.c", Line: 11, Column: 3}, module.SourceLocation(code+4))
+}
+
+func TestSourceLocationWithoutDebugInfo(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module (func (export "f") unreachable))`)
+	require.NoError(t, err)
+	module, err := NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	for offset := uint(0); offset < uint(len(wasm)); offset++ {
+		require.Nil(t, module.SourceLocation(offset))
+	}
+}
@@ -5,16 +5,25 @@ package wasmtime
 // #include <stdint.h>
 import "C"
 import (
+	"fmt"
+	"math"
 	"os"
 	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
 // Store is a general group of wasm instances, and many objects
 // must all be created with and reference the same `Store`
 type Store struct {
+	// Identifier of the goroutine allowed to use this store, or 0 if any
+	// goroutine may, see `SetGoroutineAffinity`. Accessed atomically.
+	owner int64
+
 	_ptr *C.wasmtime_store_t
 
 	// The `Engine` that this store uses for compilation and environment
@@ -36,15 +45,103 @@ type Storelike interface {
 var gStoreLock sync.Mutex
 var gStoreMap = make(map[int]*storeData)
 var gStoreSlab slab
+var gStoreID uint64
 
 // State associated with a `Store`, currently used to propagate panic
 // information through invocations as well as store Go closures that have been
 // added to the store.
 type storeData struct {
+	id        uint64
 	engine    *Engine
+	ptr       *C.wasmtime_store_t
 	funcNew   []funcNewEntry
 	funcWrap  []funcWrapEntry
 	lastPanic interface{}
+	userData  interface{}
+	callHook  func(Storelike, CallHook) error
+	// Payload of the trap most recently raised by a host function, which is
+	// attached to the trap when it reaches the invocation of WebAssembly it
+	// unwinds to.
+	trapPayload interface{}
+	// Callback installed with `SetMemoryGrowthCallback`, and the size in
+	// pages each memory had when it was last checked for growth.
+	memoryGrown func(mem *Memory, oldPages, newPages uint64)
+	memoryPages map[C.wasmtime_memory_t]uint64
+
+	// Static limits configured through `Store.Limiter`, remembered so they
+	// can be temporarily tightened by `SetCallGrowthLimit`, and the limits
+	// currently installed in the store.
+	limits          storeLimits
+	appliedLimits   storeLimits
+	callGrowthLimit uint64
+	// Internal wasmtime identifier of this store, recorded in debug builds
+	// from the first object it creates to validate objects are used with the
+	// right store.
+	wasmtimeID C.uint64_t
+	// Number of invocations of WebAssembly currently on the stack, and the
+	// scratch space reused by the invocation at each depth.
+	wasmDepth  int
+	callFrames []*callFrame
+
+	// Memories and tables created with `NewMemory` and `NewTable` or exported
+	// by instances created within this store, learned once as they're
+	// created. A memory exported more than once is only recorded once, while
+	// tables can't be told apart and are recorded per export.
+	//
+	// Like the store itself, which doesn't free anything until it's dropped,
+	// these only ever grow: by a handle per memory and exported table, and a
+	// name per instance. Short-lived instances should get their own stores.
+	memories []C.wasmtime_memory_t
+	tables   []C.wasmtime_table_t
+	// Name of the module each instance created within this store was created
+	// from.
+	instanceModules []string
+
+	// Scale applied to WASI clocks, or 0 if they aren't scaled, along with
+	// the first time the guest observed each clock.
+	wasiClockScale   float64
+	wasiClockOrigins map[int32]uint64
+	// Instances used to forward calls to the original WASI functions, and
+	// how many have been created.
+	wasiTrampolines     map[wasiTrampolineKey]C.wasmtime_instance_t
+	trampolineInstances int
+	// Scratch directories preopened by the WASI configuration.
+	wasiScratch []*wasiScratchState
+	// Stops copying the stdio of the WASI configuration, see
+	// `WasiConfig.SetStdioConn`.
+	wasiClosers []func()
+	// How long calls to each WASI function took, see `Store.WasiLatencies`.
+	wasiLatencies map[string]*LatencyHistogram
+
+	// The `QuotaGroup` this store belongs to, if any, and the memory and
+	// instances the group has been charged for on behalf of this store.
+	quota          *QuotaGroup
+	quotaMemory    uint64
+	quotaInstances int
+
+	// Externrefs interned by `Store.ExternRefOf`.
+	externrefs map[interface{}]Val
+
+	// Imports of instances created with `Linker.InstantiateDeferred` which
+	// can be linked with `Store.LinkExportToImport`.
+	deferredImports map[deferredImportKey]*deferredImport
+
+	// Shared with the guest by `Linker.DefineAbortSignal`.
+	abort AbortSignal
+
+	// Guest functions subscribed to each event through
+	// `Linker.DefineEventBridge`, and the payload of the event currently
+	// being delivered by `Store.EmitEvent`.
+	eventCallbacks map[string][]*Func
+	eventPayload   []byte
+}
+
+type storeLimits struct {
+	memorySize    int64
+	tableElements int64
+	instances     int64
+	tables        int64
+	memories      int64
 }
 
 type funcNewEntry struct {
@@ -62,10 +159,19 @@ func NewStore(engine *Engine) *Store {
 	// the store.
 	gStoreLock.Lock()
 	idx := gStoreSlab.allocate()
-	gStoreMap[idx] = &storeData{engine: engine}
+	gStoreID++
+	data := &storeData{
+		id:            gStoreID,
+		engine:        engine,
+		limits:        storeLimits{-1, -1, -1, -1, -1},
+		appliedLimits: storeLimits{-1, -1, -1, -1, -1},
+	}
+	gStoreMap[idx] = data
 	gStoreLock.Unlock()
+	engine.trackStores(1)
 
 	ptr := C.go_store_new(engine.ptr(), C.size_t(idx))
+	data.ptr = ptr
 	store := &Store{
 		_ptr:   ptr,
 		Engine: engine,
@@ -76,6 +182,53 @@ func NewStore(engine *Engine) *Store {
 	return store
 }
 
+// Close deallocates this store, along with all of the instances and other
+// objects within it, without waiting for it to be garbage collected. Nothing
+// belonging to the store may be used afterwards.
+func (store *Store) Close() {
+	if store._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(store, nil)
+	C.wasmtime_store_delete(store._ptr)
+	store._ptr = nil
+}
+
+// Reset clears the per-request state of this store so that it, and the
+// instances within it, can be reused for another request instead of creating
+// a new store. In particular this:
+//
+//   - replaces the WASI context with an empty one, closing any files, sockets
+//     and preopened directories of the previous one,
+//   - drains any remaining fuel,
+//   - sets the epoch deadline to the current epoch, as for a new store,
+//   - removes the limits configured with `Limiter` and
+//     `SetCallGrowthLimit`,
+//   - and resets the store's `AbortSignal`.
+//
+// Instances and the state inside them, such as the contents of their
+// memories, are unaffected, and neither are the data and call hook attached
+// to the store. See `InstanceCache` for resetting exported instance state.
+func (store *Store) Reset() {
+	store.SetWasiConfig(NewWasiConfig())
+
+	remaining := C.uint64_t(0)
+	if err := C.wasmtime_context_consume_fuel(store.Context(), 0, &remaining); err != nil {
+		mkError(err) // fuel isn't enabled
+	} else if err := C.wasmtime_context_consume_fuel(store.Context(), remaining, &remaining); err != nil {
+		mkError(err)
+	}
+	store.SetEpochDeadline(0)
+
+	data := getDataInStore(store)
+	data.callGrowthLimit = 0
+	data.abort.Reset()
+	if data.limits != (storeLimits{-1, -1, -1, -1, -1}) {
+		store.Limiter(-1, -1, -1, -1, -1)
+	}
+	runtime.KeepAlive(store)
+}
+
 //export goFinalizeStore
 func goFinalizeStore(env unsafe.Pointer) {
 	// When a store is finalized this is used as the finalization callback for the
@@ -84,9 +237,64 @@ func goFinalizeStore(env unsafe.Pointer) {
 	// a future store.
 	idx := int(uintptr(env))
 	gStoreLock.Lock()
-	defer gStoreLock.Unlock()
+	data := gStoreMap[idx]
 	delete(gStoreMap, idx)
 	gStoreSlab.deallocate(idx)
+	gStoreLock.Unlock()
+
+	if data.quota != nil {
+		data.quota.remove(data)
+	}
+	data.setWasiScratch(nil)
+	runClosers(data.wasiClosers)
+
+	data.engine.trackStores(-1)
+	data.engine.trackInstances(-len(data.instanceModules))
+	if _, dropped := data.engine.instanceHooks(); dropped != nil {
+		for _, name := range data.instanceModules {
+			dropped(InstanceInfo{ModuleName: name, StoreID: data.id})
+		}
+	}
+}
+
+// ID returns an identifier for this store which is unique among all stores
+// created by this process.
+func (store *Store) ID() uint64 {
+	return getDataInStore(store).id
+}
+
+// Panics if `id` doesn't belong to `store`, see `checkStore`.
+func assertStore(store Storelike, id C.uint64_t, what string) {
+	if err := checkStore(store, id, what); err != nil {
+		panic(err)
+	}
+}
+
+// Records that `instance` was created from `module` within this store, along
+// with the memories and tables it exports.
+func (data *storeData) addInstance(store Storelike, instance C.wasmtime_instance_t, module *Module) {
+	var name *C.char
+	var nameLen C.size_t
+	for i := 0; ; i++ {
+		var item C.wasmtime_extern_t
+		ok := C.wasmtime_instance_export_nth(store.Context(), &instance, C.size_t(i), &name, &nameLen, &item)
+		if !ok {
+			break
+		}
+		switch item.kind {
+		case C.WASMTIME_EXTERN_MEMORY:
+			data.addMemory(store, C.go_wasmtime_extern_memory_get(&item))
+		case C.WASMTIME_EXTERN_TABLE:
+			data.tables = append(data.tables, C.go_wasmtime_extern_table_get(&item))
+		}
+	}
+	runtime.KeepAlive(store)
+	data.instanceModules = append(data.instanceModules, module.name)
+	data.engine.trackInstances(1)
+	data.engine.recordEvent(EngineEventInstantiate, data.id, module.name)
+	if created, _ := data.engine.instanceHooks(); created != nil {
+		created(InstanceInfo{ModuleName: module.name, StoreID: data.id})
+	}
 }
 
 // GC will clean up any `externref` values that are no longer actually
@@ -99,6 +307,53 @@ func (store *Store) GC() {
 	runtime.KeepAlive(store)
 }
 
+// CallHook describes a transition between the host and WebAssembly, and is
+// passed to hooks installed with `Store.SetCallHook`.
+type CallHook int
+
+const (
+	// CallHookCallingWasm indicates the host is about to call into WebAssembly.
+	CallHookCallingWasm CallHook = iota
+	// CallHookReturningFromWasm indicates WebAssembly has returned to the host.
+	CallHookReturningFromWasm
+	// CallHookCallingHost indicates WebAssembly is about to call a host function.
+	CallHookCallingHost
+	// CallHookReturningFromHost indicates a host function is returning to
+	// WebAssembly.
+	CallHookReturningFromHost
+)
+
+// SetCallHook configures a callback which is invoked on every transition from
+// the host into WebAssembly and from WebAssembly into the host, for example to
+// trace calls, account for CPU time, or enforce re-entrancy policies.
+//
+// If the hook returns an error when WebAssembly is being entered then the call
+// is not performed and the error is returned instead. If it returns an error
+// when WebAssembly calls a host function then the host function is not
+// invoked and the error is raised as a trap in WebAssembly. Errors returned on
+// the way back out are returned, or raised as traps, in the same manner.
+// Raising a `*Trap` returned by the hook hands it over to wasmtime, so
+// returning the same trap again panics, as it would from a host function.
+//
+// Note that only host functions defined in Go, for example with `NewFunc`,
+// `WrapFunc`, or through the `Linker`, trigger the hooks when called from
+// WebAssembly. Host functions implemented within wasmtime itself, such as
+// those defined with `Linker.DefineWasi`, do not, unless they're wrapped in Go
+// through `WasiLinkOptions`.
+//
+// Passing nil removes any previously installed hook.
+func (store *Store) SetCallHook(hook func(Storelike, CallHook) error) {
+	getDataInStore(store).callHook = hook
+}
+
+// Invokes the call hook installed in the store, if any, for `kind`.
+func (data *storeData) invokeCallHook(store Storelike, kind CallHook) error {
+	if data.callHook == nil {
+		return nil
+	}
+	return data.callHook(store, kind)
+}
+
 // SetWasiConfig will configure the WASI state to use for instances within this
 // `Store`.
 //
@@ -113,15 +368,123 @@ func (store *Store) SetWasiConfig(wasi *WasiConfig) {
 	}
 	C.wasmtime_context_set_wasi(store.Context(), ptr)
 	runtime.KeepAlive(store)
+	data := getDataInStore(store)
+	data.wasiClockScale = 0
+	if wasi.desc.ClockScale != 1 {
+		data.wasiClockScale = wasi.desc.ClockScale
+	}
+	data.wasiClockOrigins = make(map[int32]uint64)
+	data.setWasiScratch(wasi.scratch)
+	runClosers(data.wasiClosers)
+	data.wasiClosers = wasi.closers
+}
+
+// SetData attaches arbitrary user-defined `data` to this store, replacing any
+// previously attached value.
+//
+// The data can later be retrieved with `Store.Data`, or from within host
+// functions with `Caller.Data`, which allows host callbacks to reach
+// per-store state without maintaining global maps.
+func (store *Store) SetData(data interface{}) {
+	getDataInStore(store).userData = data
+}
+
+// ExternRefOf returns an externref `Val` for `val` which is the same every time
+// it's called with an equal value in this store, so that WebAssembly sees the
+// same reference each time the host passes the same Go object. Interned values
+// are kept alive for as long as the store.
+//
+// Values which can't be compared with `==`, such as slices and maps, or
+// structs and arrays containing them, aren't interned, and a new externref is
+// returned for them each time as with `ValExternref`.
+func (store *Store) ExternRefOf(val interface{}) Val {
+	if val == nil || !reflect.TypeOf(val).Comparable() {
+		return ValExternref(val)
+	}
+	data := getDataInStore(store)
+	ret, ok, hashable := data.internedExternref(val)
+	if ok || !hashable {
+		return ret
+	}
+	if data.externrefs == nil {
+		data.externrefs = make(map[interface{}]Val)
+	}
+	data.externrefs[val] = ret
+	return ret
+}
+
+// Looks up the externref interned for `val`, returning a new one if there
+// isn't any. A type which is comparable can still hold values that aren't,
+// such as a struct with an interface field holding a slice, which panic when
+// used as a map key, so `hashable` is false for them.
+func (data *storeData) internedExternref(val interface{}) (ret Val, ok, hashable bool) {
+	defer func() {
+		if recover() != nil {
+			ret, ok, hashable = ValExternref(val), false, false
+		}
+	}()
+	if ret, ok = data.externrefs[val]; ok {
+		return ret, true, true
+	}
+	return ValExternref(val), false, true
+}
+
+// Data returns the user-defined data previously attached to this store with
+// `SetData`, or nil if no data has been attached.
+func (store *Store) Data() interface{} {
+	return getDataInStore(store).userData
 }
 
 // Implementation of the `Storelike` interface
 func (store *Store) Context() *C.wasmtime_context_t {
+	if store._ptr == nil {
+		panic("Store used after Close")
+	}
+	if owner := atomic.LoadInt64(&store.owner); owner != 0 {
+		if id := goroutineID(); id != owner {
+			panic(fmt.Sprintf("wasmtime: store owned by goroutine %d used from goroutine %d; "+
+				"stores must not be used concurrently, pass ownership with SetGoroutineAffinity", owner, id))
+		}
+	}
 	ret := C.wasmtime_store_context(store._ptr)
 	maybeGC()
 	return ret
 }
 
+// SetGoroutineAffinity configures whether this store is restricted to being
+// used from the goroutine calling this function.
+//
+// A `Store` isn't safe to use from multiple goroutines at once, and doing so
+// causes memory corruption rather than an error. When enabled, using the store
+// from any other goroutine panics with a diagnostic naming both goroutines,
+// which helps to track down such data races. Ownership can be passed to
+// another goroutine by calling this function again from that goroutine.
+//
+// This adds overhead to every use of the store, so it's intended for testing
+// and debugging.
+func (store *Store) SetGoroutineAffinity(enabled bool) {
+	owner := int64(0)
+	if enabled {
+		owner = goroutineID()
+	}
+	atomic.StoreInt64(&store.owner, owner)
+}
+
+// Returns the identifier of the calling goroutine, which the Go runtime only
+// exposes in the header of stack traces.
+func goroutineID() int64 {
+	var buf [64]byte
+	stack := strings.TrimPrefix(string(buf[:runtime.Stack(buf[:], false)]), "goroutine ")
+	if i := strings.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+	id, err := strconv.ParseInt(stack, 10, 64)
+	if err != nil {
+		panic("failed to determine goroutine id: " + err.Error())
+	}
+	return id
+}
+
 // SetEpochDeadline will configure the relative deadline, from the current
 // engine's epoch number, after which wasm code will be interrupted.
 func (store *Store) SetEpochDeadline(deadline uint64) {
@@ -135,7 +498,9 @@ func getDataInStore(store Storelike) *storeData {
 	data := uintptr(C.wasmtime_context_get_data(store.Context()))
 	gStoreLock.Lock()
 	defer gStoreLock.Unlock()
-	return gStoreMap[int(data)]
+	ret := gStoreMap[int(data)]
+	runtime.KeepAlive(store)
+	return ret
 }
 
 var gEngineFuncLock sync.Mutex
@@ -292,6 +657,10 @@ func (store *Store) ConsumeFuel(fuel uint64) (uint64, error) {
 // Limiter provides limits for a store. Used by hosts to limit resource
 // consumption of instances. Use negative value to keep the default value
 // for the limit.
+//
+// These limits are static: the Wasmtime 13 C API doesn't call back into the
+// host when WebAssembly grows a memory or table, so growth can't be decided
+// dynamically from Go.
 func (store *Store) Limiter(
 	memorySize int64,
 	tableElements int64,
@@ -299,16 +668,167 @@ func (store *Store) Limiter(
 	tables int64,
 	memories int64,
 ) {
+	limits := storeLimits{memorySize, tableElements, instances, tables, memories}
+	getDataInStore(store).limits = limits
+	store.setLimits(limits)
+}
+
+func (store *Store) setLimits(limits storeLimits) {
+	if store._ptr == nil {
+		panic("Store used after Close")
+	}
+	getDataInStore(store).applyLimits(limits)
+	runtime.KeepAlive(store)
+}
+
+// Installs `limits` in the store, allowing for the trampoline instances
+// created by the WASI wrappers of `WasiLinkOptions` on top of its instance
+// limit.
+func (data *storeData) applyLimits(limits storeLimits) {
+	data.appliedLimits = limits
+	if limits.instances >= 0 {
+		limits.instances += int64(data.trampolineInstances)
+	}
 	C.wasmtime_store_limiter(
-		store._ptr,
-		C.int64_t(memorySize),
-		C.int64_t(tableElements),
-		C.int64_t(instances),
-		C.int64_t(tables),
-		C.int64_t(memories),
+		data.ptr,
+		C.int64_t(limits.memorySize),
+		C.int64_t(limits.tableElements),
+		C.int64_t(limits.instances),
+		C.int64_t(limits.tables),
+		C.int64_t(limits.memories),
 	)
 }
 
+// SetCallGrowthLimit restricts how many bytes linear memory may grow by during
+// a single call into WebAssembly made with `Func.Call`. The restriction is
+// lifted again once the call returns, so memory can still grow over the course
+// of many calls, up to the limits configured with `Store.Limiter`.
+//
+// This is implemented by temporarily lowering the maximum memory size of
+// `Store.Limiter` to the size of the largest linear memory in the store, plus
+// `bytes`, for the duration of the call. For the common case of a store with
+// a single linear memory this bounds the growth of that memory to `bytes`.
+// Only memories created with `NewMemory` or exported from instances created
+// with `NewInstance` or `Linker.Instantiate` are taken into account.
+//
+// When the limit is hit the growth fails as it would for any other limit, for
+// example `memory.grow` returns -1. A limit of 0 removes the restriction.
+func (store *Store) SetCallGrowthLimit(bytes uint64) {
+	getDataInStore(store).callGrowthLimit = bytes
+}
+
+// Applies the limit configured with `SetCallGrowthLimit`, if any, and the
+// memory remaining in the store's `QuotaGroup` to an outermost invocation of
+// WebAssembly. Returns a function to lift the limit again once the invocation
+// has finished, or nil if nothing was applied.
+func (data *storeData) applyCallGrowthLimit(store Storelike) func() {
+	growth, limited := data.callGrowthLimit, data.callGrowthLimit != 0
+	if data.quota != nil {
+		if remaining, ok := data.quota.memoryRemaining(); ok && (!limited || remaining < growth) {
+			growth, limited = remaining, true
+		}
+	}
+	if !limited || data.wasmDepth > 0 {
+		return nil
+	}
+	s, ok := store.(*Store)
+	if !ok {
+		return nil
+	}
+	// Saturate at the largest limit wasmtime accepts rather than wrapping
+	// around, which would shrink or remove the limit.
+	limit := uint64(math.MaxInt64)
+	if largest := data.largestMemory(store); growth < limit && largest < limit-growth {
+		limit = largest + growth
+	}
+	limits := data.limits
+	if limits.memorySize < 0 || uint64(limits.memorySize) > limit {
+		limits.memorySize = int64(limit)
+	}
+	s.setLimits(limits)
+	return func() {
+		s.setLimits(data.limits)
+	}
+}
+
+// Returns the size, in bytes, of the largest linear memory known to live in
+// this store.
+func (data *storeData) largestMemory(store Storelike) uint64 {
+	largest := uint64(0)
+	for i := range data.memories {
+		size := uint64(C.wasmtime_memory_data_size(store.Context(), &data.memories[i]))
+		if size > largest {
+			largest = size
+		}
+	}
+	runtime.KeepAlive(store)
+	return largest
+}
+
+// Records that `mem` lives in this store, unless it's already known. Each
+// export of a memory has its own handle, so memories are told apart by the
+// address of their data instead.
+func (data *storeData) addMemory(store Storelike, mem C.wasmtime_memory_t) {
+	base := C.wasmtime_memory_data(store.Context(), &mem)
+	for i := range data.memories {
+		if C.wasmtime_memory_data(store.Context(), &data.memories[i]) == base {
+			return
+		}
+	}
+	data.memories = append(data.memories, mem)
+	runtime.KeepAlive(store)
+}
+
+// SetMemoryGrowthCallback installs `callback` to be notified whenever a linear
+// memory in this store grows, replacing any previously installed callback.
+// Passing nil removes the callback.
+//
+// `callback` is passed the memory along with its size, in wasm pages, before
+// and after it grew. Growth performed by WebAssembly with `memory.grow` isn't
+// reported by the C API as it happens, so it's noticed, and the callback
+// invoked, the next time control passes from WebAssembly to the host: when
+// WebAssembly calls a host function implemented in Go, before the function
+// runs, or when an invocation of WebAssembly returns. Growth through
+// `Memory.Grow` is reported before it returns. Either way the callback runs
+// before the host can observe the memory's new size, which makes it a safe
+// place to invalidate slices returned by `Memory.UnsafeData`.
+//
+// Memories which are created or first found in an instance after the
+// callback is installed are tracked from the size they're first seen with.
+func (store *Store) SetMemoryGrowthCallback(callback func(mem *Memory, oldPages, newPages uint64)) {
+	data := getDataInStore(store)
+	data.memoryGrown = callback
+	data.memoryPages = nil
+	if callback != nil {
+		data.memoryPages = make(map[C.wasmtime_memory_t]uint64)
+		data.checkMemoryGrowth(store)
+	}
+}
+
+// Invokes the callback installed with `SetMemoryGrowthCallback` for each
+// memory which has grown since it was last checked.
+func (data *storeData) checkMemoryGrowth(store Storelike) {
+	if data.memoryGrown == nil {
+		return
+	}
+	type growth struct {
+		mem           C.wasmtime_memory_t
+		before, after uint64
+	}
+	var grown []growth
+	for _, mem := range data.memories {
+		pages := uint64(C.wasmtime_memory_size(store.Context(), &mem))
+		if before, ok := data.memoryPages[mem]; ok && pages > before {
+			grown = append(grown, growth{mem, before, pages})
+		}
+		data.memoryPages[mem] = pages
+	}
+	runtime.KeepAlive(store)
+	for _, g := range grown {
+		data.memoryGrown(mkMemory(g.mem), g.before, g.after)
+	}
+}
+
 /// Refraction-Networking changes begin here
 
 // SetWasiCtx sets the `WasiCtx` within this store.
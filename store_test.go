@@ -1,6 +1,8 @@
 package wasmtime
 
 import (
+	"errors"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -119,3 +121,241 @@ func TestLimiterMemorySizeSuccess(t *testing.T) {
 	_, err = NewInstance(store, module, []AsExtern{})
 	require.NoError(t, err)
 }
+
+func TestStoreData(t *testing.T) {
+	store := NewStore(NewEngine())
+	require.Nil(t, store.Data())
+	store.SetData("hello")
+	require.Equal(t, "hello", store.Data())
+
+	other := NewStore(store.Engine)
+	require.Nil(t, other.Data())
+	other.SetData(42)
+	require.Equal(t, "hello", store.Data())
+	require.Equal(t, 42, other.Data())
+}
+
+func TestCallGrowthLimit(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	(module
+	  (memory (export "memory") 1)
+	  (func (export "grow") (param i32) (result i32)
+	    local.get 0
+	    memory.grow)
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	grow := instance.GetFunc(store, "grow")
+
+	store.SetCallGrowthLimit(2 * 65536)
+	result, err := grow.Call(store, 3)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), result)
+	result, err = grow.Call(store, 2)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result)
+	result, err = grow.Call(store, 2)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), result)
+
+	// long-term limits still apply and are restored after each call
+	store.Limiter(6*65536, -1, -1, -1, -1)
+	result, err = grow.Call(store, 2)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), result)
+	result, err = grow.Call(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(5), result)
+
+	store.SetCallGrowthLimit(0)
+	store.Limiter(-1, -1, -1, -1, -1)
+	result, err = grow.Call(store, 10)
+	require.NoError(t, err)
+	require.Equal(t, int32(6), result)
+
+	// limits beyond what fits saturate instead of wrapping around
+	store.SetCallGrowthLimit(math.MaxUint64)
+	result, err = grow.Call(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(16), result)
+	store.SetCallGrowthLimit(math.MaxInt64 - 65536)
+	store.Limiter(18*65536, -1, -1, -1, -1)
+	result, err = grow.Call(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(17), result)
+	result, err = grow.Call(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), result)
+}
+
+func TestCallHook(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "" "host" (func $host))
+	  (func (export "run") call $host)
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	host := WrapFunc(store, func() {})
+	instance, err := NewInstance(store, module, []AsExtern{host})
+	require.NoError(t, err)
+	run := instance.GetFunc(store, "run")
+
+	var hooks []CallHook
+	store.SetCallHook(func(_ Storelike, kind CallHook) error {
+		hooks = append(hooks, kind)
+		return nil
+	})
+	_, err = run.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, []CallHook{
+		CallHookCallingWasm,
+		CallHookCallingHost,
+		CallHookReturningFromHost,
+		CallHookReturningFromWasm,
+	}, hooks)
+
+	store.SetCallHook(func(_ Storelike, kind CallHook) error {
+		if kind == CallHookCallingHost {
+			return errors.New("host calls denied")
+		}
+		return nil
+	})
+	_, err = run.Call(store)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "host calls denied")
+
+	store.SetCallHook(func(_ Storelike, kind CallHook) error {
+		return errors.New("wasm calls denied")
+	})
+	_, err = run.Call(store)
+	require.EqualError(t, err, "wasm calls denied")
+
+	store.SetCallHook(nil)
+	_, err = run.Call(store)
+	require.NoError(t, err)
+}
+
+func TestCallHookTrapTwice(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "" "wrapped" (func $wrapped))
+	  (import "" "new" (func $new))
+	  (func (export "wrapped") call $wrapped)
+	  (func (export "new") call $new)
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	wrapped := WrapFunc(store, func() {})
+	newFunc := NewFunc(store, NewFuncType(nil, nil), func(*Caller, []Val) ([]Val, *Trap) { return nil, nil })
+	instance, err := NewInstance(store, module, []AsExtern{wrapped, newFunc})
+	require.NoError(t, err)
+
+	for _, name := range []string{"wrapped", "new"} {
+		denied := NewTrap("denied")
+		store.SetCallHook(func(_ Storelike, kind CallHook) error {
+			if kind == CallHookCallingHost {
+				return denied
+			}
+			return nil
+		})
+		run := instance.GetFunc(store, name)
+		_, err = run.Call(store)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "denied")
+		require.PanicsWithValue(t, "cannot return trap twice", func() { run.Call(store) })
+	}
+}
+
+func TestGoroutineAffinity(t *testing.T) {
+	store := NewStore(NewEngine())
+	store.SetGoroutineAffinity(true)
+	store.SetData(1)
+
+	use := func() (err interface{}) {
+		defer func() { err = recover() }()
+		store.SetData(2)
+		return nil
+	}
+	done := make(chan interface{})
+	go func() { done <- use() }()
+	require.Contains(t, <-done, "used from goroutine")
+
+	// ownership can be handed off to another goroutine
+	go func() {
+		store.SetGoroutineAffinity(true)
+		done <- use()
+	}()
+	require.Nil(t, <-done)
+	require.NotNil(t, use())
+
+	store.SetGoroutineAffinity(false)
+	require.Nil(t, use())
+	require.Equal(t, 2, store.Data())
+}
+
+func TestStoreClose(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module (memory (export "m") 1))`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	_, err = NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	id := store.ID()
+
+	dropped := make(chan InstanceInfo, 1)
+	store.Engine.SetInstanceHooks(nil, func(info InstanceInfo) { dropped <- info })
+	store.Close()
+	store.Close()
+	require.Equal(t, id, (<-dropped).StoreID)
+	require.Panics(t, func() { store.Context() })
+}
+
+func TestStoreReset(t *testing.T) {
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	store := NewStore(NewEngineWithConfig(config))
+	wasm, err := Wat2Wasm(`
+	(module
+	  (memory (export "memory") 1)
+	  (func (export "grow") (result i32) (memory.grow (i32.const 1))))
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	grow := instance.GetFunc(store, "grow")
+
+	store.SetData("request")
+	store.Limiter(65536, -1, -1, -1, -1)
+	require.NoError(t, store.AddFuel(10000))
+	result, err := grow.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), result)
+
+	store.Reset()
+	remaining, err := store.ConsumeFuel(0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), remaining)
+	_, err = grow.Call(store)
+	require.Error(t, err)
+
+	require.NoError(t, store.AddFuel(10000))
+	result, err = grow.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result)
+	require.Equal(t, "request", store.Data())
+}
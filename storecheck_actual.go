@@ -0,0 +1,59 @@
+//go:build debug
+// +build debug
+
+package wasmtime
+
+// #include <wasmtime.h>
+import "C"
+import (
+	"fmt"
+	"runtime"
+)
+
+// Returns an error if `id`, the internal store identifier carried by a `what`,
+// does not belong to the store that `store` refers to.
+//
+// Using an object with a store other than the one it was created in is
+// otherwise undefined behavior, so this validation is performed in debug builds
+// to provide a descriptive error instead.
+func checkStore(store Storelike, id C.uint64_t, what string) error {
+	data := getDataInStore(store)
+	if data.wasmtimeID == 0 {
+		data.wasmtimeID = learnStoreID(store)
+	}
+	if id != data.wasmtimeID {
+		return fmt.Errorf("%s used with a store it does not belong to", what)
+	}
+	return nil
+}
+
+// Records `id`, the internal store identifier carried by an object just created
+// within `store`, as the identifier of that store.
+//
+// The wasmtime C API doesn't expose the identifier of a store directly, so it's
+// taken from the first function, global, memory, table or instance the store
+// creates.
+func recordStoreID(store Storelike, id C.uint64_t) {
+	data := getDataInStore(store)
+	if data.wasmtimeID == 0 {
+		data.wasmtimeID = id
+	}
+}
+
+// Learns the identifier of a store which hasn't recorded one with
+// `recordStoreID` yet by creating a global within it and looking at its
+// identifier. This only happens if an object is first checked against a store
+// before the store created anything itself, for example with a function
+// returned by `Linker.Get`.
+func learnStoreID(store Storelike) C.uint64_t {
+	ty := C.wasm_globaltype_new(C.wasm_valtype_new(C.WASM_I32), C.WASM_CONST)
+	val := C.wasmtime_val_t{kind: C.WASMTIME_I32}
+	var ret C.wasmtime_global_t
+	err := C.wasmtime_global_new(store.Context(), ty, &val, &ret)
+	runtime.KeepAlive(store)
+	C.wasm_globaltype_delete(ty)
+	if err != nil {
+		panic(mkError(err))
+	}
+	return ret.store_id
+}
@@ -0,0 +1,46 @@
+//go:build debug
+// +build debug
+
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStore(t *testing.T) {
+	engine := NewEngine()
+	store := NewStore(engine)
+	other := NewStore(engine)
+
+	f := WrapFunc(store, func() {})
+	require.Equal(t, f.val.store_id, getDataInStore(store).wasmtimeID)
+	_, err := f.Call(store)
+	require.NoError(t, err)
+	_, err = f.Call(other)
+	require.EqualError(t, err, "func used with a store it does not belong to")
+
+	mem, err := NewMemory(store, NewMemoryType(1, true, 2))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), mem.Size(store))
+	_, err = mem.Grow(other, 1)
+	require.EqualError(t, err, "memory used with a store it does not belong to")
+	require.Panics(t, func() { mem.Size(other) })
+
+	global, err := NewGlobal(store, NewGlobalType(NewValType(KindI32), true), ValI32(1))
+	require.NoError(t, err)
+	require.Error(t, global.Set(other, ValI32(2)))
+	require.Panics(t, func() { global.Get(other) })
+
+	require.Error(t, NewLinker(engine).Define(other, "", "f", f))
+
+	wasm, err := Wat2Wasm(`(module (import "" "f" (func)))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	_, err = NewInstance(other, module, []AsExtern{f})
+	require.EqualError(t, err, "import used with a store it does not belong to")
+	_, err = NewInstance(store, module, []AsExtern{f})
+	require.NoError(t, err)
+}
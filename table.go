@@ -23,13 +23,19 @@ type Table struct {
 // `ty`.
 func NewTable(store Storelike, ty *TableType, init Val) (*Table, error) {
 	var ret C.wasmtime_table_t
-	err := C.wasmtime_table_new(store.Context(), ty.ptr(), init.ptr(), &ret)
+	err := failpointError(FailpointAlloc)
+	if err == nil {
+		err = C.wasmtime_table_new(store.Context(), ty.ptr(), init.ptr(), &ret)
+	}
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(ty)
 	runtime.KeepAlive(init)
 	if err != nil {
 		return nil, mkError(err)
 	}
+	recordStoreID(store, ret.store_id)
+	data := getDataInStore(store)
+	data.tables = append(data.tables, ret)
 	return mkTable(ret), nil
 }
 
@@ -39,6 +45,7 @@ func mkTable(val C.wasmtime_table_t) *Table {
 
 // Size returns the size of this table in units of elements.
 func (t *Table) Size(store Storelike) uint32 {
+	assertStore(store, t.val.store_id, "table")
 	ret := C.wasmtime_table_size(store.Context(), &t.val)
 	runtime.KeepAlive(store)
 	return uint32(ret)
@@ -50,8 +57,14 @@ func (t *Table) Size(store Storelike) uint32 {
 // Returns an error if the table failed to grow, or the previous size of the
 // table if growth was successful.
 func (t *Table) Grow(store Storelike, delta uint32, init Val) (uint32, error) {
+	if err := checkStore(store, t.val.store_id, "table"); err != nil {
+		return 0, err
+	}
 	var prev C.uint32_t
-	err := C.wasmtime_table_grow(store.Context(), &t.val, C.uint32_t(delta), init.ptr(), &prev)
+	err := failpointError(FailpointAlloc)
+	if err == nil {
+		err = C.wasmtime_table_grow(store.Context(), &t.val, C.uint32_t(delta), init.ptr(), &prev)
+	}
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(init)
 	if err != nil {
@@ -67,6 +80,9 @@ func (t *Table) Grow(store Storelike, delta uint32, init Val) (uint32, error) {
 // may be internally null) if the index is in bounds corresponding to the entry
 // at the specified index.
 func (t *Table) Get(store Storelike, idx uint32) (Val, error) {
+	if err := checkStore(store, t.val.store_id, "table"); err != nil {
+		return Val{}, err
+	}
 	var val C.wasmtime_val_t
 	ok := C.wasmtime_table_get(store.Context(), &t.val, C.uint32_t(idx), &val)
 	runtime.KeepAlive(store)
@@ -80,6 +96,9 @@ func (t *Table) Get(store Storelike, idx uint32) (Val, error) {
 //
 // Returns an error if the index is out of bounds.
 func (t *Table) Set(store Storelike, idx uint32, val Val) error {
+	if err := checkStore(store, t.val.store_id, "table"); err != nil {
+		return err
+	}
 	err := C.wasmtime_table_set(store.Context(), &t.val, C.uint32_t(idx), val.ptr())
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(val)
@@ -89,8 +108,94 @@ func (t *Table) Set(store Storelike, idx uint32, val Val) error {
 	return nil
 }
 
+// Fill sets the `count` items of this table starting at index `start` to
+// `val`, as the `table.fill` instruction does.
+//
+// Returns an error, without changing the table, if the range is out of
+// bounds.
+func (t *Table) Fill(store Storelike, start uint32, val Val, count uint32) error {
+	if err := checkStore(store, t.val.store_id, "table"); err != nil {
+		return err
+	}
+	if !tableRangeInBounds(t.Size(store), start, count) {
+		return errors.New("table fill out of bounds")
+	}
+	for i := uint32(0); i < count; i++ {
+		if err := t.Set(store, start+i, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy copies the `count` items of this table starting at index `srcIdx` to
+// `dst` starting at index `dstIdx`, as the `table.copy` instruction does.
+// `dst` may be this table, in which case the ranges may overlap.
+//
+// Returns an error, without changing `dst`, if either range is out of
+// bounds. The tables must have the same element type.
+func (t *Table) Copy(store Storelike, dst *Table, dstIdx, srcIdx, count uint32) error {
+	if err := checkStore(store, t.val.store_id, "table"); err != nil {
+		return err
+	}
+	if err := checkStore(store, dst.val.store_id, "table"); err != nil {
+		return err
+	}
+	if !tableRangeInBounds(t.Size(store), srcIdx, count) || !tableRangeInBounds(dst.Size(store), dstIdx, count) {
+		return errors.New("table copy out of bounds")
+	}
+	if t.Type(store).Element().Kind() != dst.Type(store).Element().Kind() {
+		return errors.New("table copy between tables with different element types")
+	}
+	// Copy backwards when copying to later indices of the same table so that
+	// overlapping items are read before they're overwritten.
+	backwards := t.val == dst.val && dstIdx > srcIdx
+	for i := uint32(0); i < count; i++ {
+		offset := i
+		if backwards {
+			offset = count - 1 - i
+		}
+		val, err := t.Get(store, srcIdx+offset)
+		if err != nil {
+			return err
+		}
+		if err := dst.Set(store, dstIdx+offset, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Range calls `f` with the index and value of each item of this table in
+// order, stopping early if `f` returns false.
+//
+// `f` may modify the table. The table's size is checked before each item, so
+// items added by growing the table from `f` are visited too.
+func (t *Table) Range(store Storelike, f func(idx uint32, val Val) bool) error {
+	if err := checkStore(store, t.val.store_id, "table"); err != nil {
+		return err
+	}
+	for i := uint32(0); i < t.Size(store); i++ {
+		val, err := t.Get(store, i)
+		if err != nil {
+			return err
+		}
+		if !f(i, val) {
+			break
+		}
+	}
+	return nil
+}
+
+// Returns whether the `count` items starting at `start` are within a table
+// of `size` items.
+func tableRangeInBounds(size, start, count uint32) bool {
+	return start <= size && count <= size-start
+}
+
 // Type returns the underlying type of this table
 func (t *Table) Type(store Storelike) *TableType {
+	assertStore(store, t.val.store_id, "table")
 	ptr := C.wasmtime_table_type(store.Context(), &t.val)
 	runtime.KeepAlive(store)
 	return mkTableType(ptr, nil)
@@ -51,3 +51,51 @@ func TestTable(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, called)
 }
+
+func TestTableFillCopyRange(t *testing.T) {
+	store := NewStore(NewEngine())
+	ty := NewTableType(NewValType(KindExternref), 6, false, 0)
+	table, err := NewTable(store, ty, ValExternref(nil))
+	require.NoError(t, err)
+	contents := func(table *Table) []interface{} {
+		var ret []interface{}
+		require.NoError(t, table.Range(store, func(idx uint32, val Val) bool {
+			require.Equal(t, uint32(len(ret)), idx)
+			ret = append(ret, val.Externref())
+			return true
+		}))
+		return ret
+	}
+
+	require.NoError(t, table.Fill(store, 1, ValExternref(1), 2))
+	require.Equal(t, []interface{}{nil, 1, 1, nil, nil, nil}, contents(table))
+	require.Error(t, table.Fill(store, 5, ValExternref(2), 2))
+	require.NoError(t, table.Fill(store, 6, ValExternref(2), 0))
+	require.Equal(t, []interface{}{nil, 1, 1, nil, nil, nil}, contents(table))
+
+	for i := uint32(0); i < 6; i++ {
+		require.NoError(t, table.Set(store, i, ValExternref(int(i))))
+	}
+	require.NoError(t, table.Copy(store, table, 2, 0, 3))
+	require.Equal(t, []interface{}{0, 1, 0, 1, 2, 5}, contents(table))
+	require.NoError(t, table.Copy(store, table, 0, 1, 4))
+	require.Equal(t, []interface{}{1, 0, 1, 2, 2, 5}, contents(table))
+
+	other, err := NewTable(store, NewTableType(NewValType(KindExternref), 2, false, 0), ValExternref(nil))
+	require.NoError(t, err)
+	require.NoError(t, table.Copy(store, other, 0, 4, 2))
+	require.Equal(t, []interface{}{2, 5}, contents(other))
+	require.Error(t, table.Copy(store, other, 1, 0, 2))
+	require.Error(t, table.Copy(store, other, 0, 5, 2))
+
+	funcs, err := NewTable(store, NewTableType(NewValType(KindFuncref), 2, false, 0), ValFuncref(nil))
+	require.NoError(t, err)
+	require.Error(t, table.Copy(store, funcs, 0, 0, 1))
+
+	visited := 0
+	require.NoError(t, table.Range(store, func(uint32, Val) bool {
+		visited++
+		return visited < 3
+	}))
+	require.Equal(t, 3, visited)
+}
@@ -43,10 +43,25 @@ func mkTableType(ptr *C.wasm_tabletype_t, owner interface{}) *TableType {
 
 func (ty *TableType) ptr() *C.wasm_tabletype_t {
 	ret := ty._ptr
+	if ret == nil {
+		panic("TableType used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this type without waiting for it to be garbage collected.
+// It has no effect on types which belong to another object, such as those
+// returned from the methods of other types.
+func (ty *TableType) Close() {
+	if ty._ptr == nil || ty._owner != nil {
+		return
+	}
+	runtime.SetFinalizer(ty, nil)
+	C.wasm_tabletype_delete(ty._ptr)
+	ty._ptr = nil
+}
+
 func (ty *TableType) owner() interface{} {
 	if ty._owner != nil {
 		return ty._owner
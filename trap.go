@@ -6,6 +6,7 @@ package wasmtime
 import "C"
 
 import (
+	"fmt"
 	"runtime"
 	"unsafe"
 )
@@ -13,7 +14,8 @@ import (
 // Trap is the trap instruction which represents the occurrence of a trap.
 // Traps are bubbled up through nested instruction sequences, ultimately reducing the entire program to a single trap instruction, signalling abrupt termination.
 type Trap struct {
-	_ptr *C.wasm_trap_t
+	_ptr    *C.wasm_trap_t
+	payload interface{}
 }
 
 // Frame is one of activation frames which carry the return arity n of the respective function,
@@ -50,8 +52,40 @@ const (
 	UnreachableCodeReached
 	// Interrupt: execution has been interrupted.
 	Interrupt
+	// OutOfFuel: execution ran out of the fuel it was given.
+	OutOfFuel
 )
 
+var trapCodeNames = [...]string{
+	StackOverflow:          "call stack exhausted",
+	MemoryOutOfBounds:      "out of bounds memory access",
+	HeapMisaligned:         "unaligned atomic",
+	TableOutOfBounds:       "undefined element: out of bounds table access",
+	IndirectCallToNull:     "uninitialized element",
+	BadSignature:           "indirect call type mismatch",
+	IntegerOverflow:        "integer overflow",
+	IntegerDivisionByZero:  "integer divide by zero",
+	BadConversionToInteger: "invalid conversion to integer",
+	UnreachableCodeReached: "wasm `unreachable` instruction executed",
+	Interrupt:              "interrupt",
+	OutOfFuel:              "all fuel consumed by WebAssembly",
+}
+
+// String returns the description wasmtime uses for traps with this code.
+func (code TrapCode) String() string {
+	if int(code) < len(trapCodeNames) {
+		return trapCodeNames[code]
+	}
+	return fmt.Sprintf("trap code %d", uint8(code))
+}
+
+// Error implements the `error` interface so that codes can be used with
+// `errors.Is` to check how a `*Trap` was raised, for example
+// `errors.Is(err, wasmtime.OutOfFuel)`.
+func (code TrapCode) Error() string {
+	return "wasm trap: " + code.String()
+}
+
 // NewTrap creates a new `Trap` with the `name` and the type provided.
 func NewTrap(message string) *Trap {
 	ptr := C.wasmtime_trap_new(C._GoStringPtr(message), C._GoStringLen(message))
@@ -59,6 +93,26 @@ func NewTrap(message string) *Trap {
 	return mkTrap(ptr)
 }
 
+// NewTrapWithPayload creates a new `Trap` with the `message` provided which
+// also carries `payload`, an arbitrary value for the host's own use.
+//
+// When a host function returns such a trap, the error returned by the
+// `Func.Call` or other invocation of WebAssembly that it unwinds to, which
+// wasmtime reports as an `*Error`, carries the same payload. It's retrieved
+// with `Error.Payload`, or with `errors.As` and `errors.Is` when it's an
+// error. The payload never passes
+// through WebAssembly, so guests can't forge or inspect it, which lets hosts
+// tell traps they raised on purpose, such as for cancellation, apart from
+// faults in the guest.
+//
+// Errors returned by host callbacks, such as call hooks, are raised as traps
+// with the error as their payload.
+func NewTrapWithPayload(message string, payload interface{}) *Trap {
+	trap := NewTrap(message)
+	trap.payload = payload
+	return trap
+}
+
 func mkTrap(ptr *C.wasm_trap_t) *Trap {
 	trap := &Trap{_ptr: ptr}
 	runtime.SetFinalizer(trap, func(trap *Trap) {
@@ -69,10 +123,23 @@ func mkTrap(ptr *C.wasm_trap_t) *Trap {
 
 func (t *Trap) ptr() *C.wasm_trap_t {
 	ret := t._ptr
+	if ret == nil {
+		panic("Trap used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this trap without waiting for it to be garbage collected.
+func (t *Trap) Close() {
+	if t._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(t, nil)
+	C.wasm_trap_delete(t._ptr)
+	t._ptr = nil
+}
+
 // Message returns the message of the `Trap`
 func (t *Trap) Message() string {
 	message := C.wasm_byte_vec_t{}
@@ -99,6 +166,31 @@ func (t *Trap) Error() string {
 	return t.Message()
 }
 
+// Payload returns the payload of this trap, see `NewTrapWithPayload`, or nil
+// if it doesn't have one.
+func (t *Trap) Payload() interface{} {
+	return t.payload
+}
+
+// Unwrap returns the payload of this trap if it's an error, so that
+// `errors.As` and `errors.Is` see through the trap to the error.
+func (t *Trap) Unwrap() error {
+	err, _ := t.payload.(error)
+	return err
+}
+
+// Is reports whether this trap was raised with the `TrapCode` `target`,
+// which makes traps usable with `errors.Is`. Traps created with `NewTrap`
+// don't have a code.
+func (t *Trap) Is(target error) bool {
+	code, ok := target.(TrapCode)
+	if !ok {
+		return false
+	}
+	ours := t.Code()
+	return ours != nil && *ours == code
+}
+
 func unwrapStrOr(s *string, other string) string {
 	if s == nil {
 		return other
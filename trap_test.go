@@ -1,6 +1,8 @@
 package wasmtime
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -71,3 +73,85 @@ func TestTrapModuleName(t *testing.T) {
 	require.Len(t, frames, 1, "expected 1 frame")
 	require.Equal(t, "f", *frames[0].ModuleName(), "bad function name")
 }
+
+func TestTrapCodeIs(t *testing.T) {
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	store := NewStore(NewEngineWithConfig(config))
+	wasm, err := Wat2Wasm(`(module
+	  (memory 1)
+	  (func (export "oob") (drop (i32.load (i32.const 65536))))
+	  (func (export "spin") (loop br 0)))`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddFuel(1000))
+	_, err = instance.GetFunc(store, "oob").Call(store)
+	require.ErrorIs(t, err, MemoryOutOfBounds)
+	require.False(t, errors.Is(err, OutOfFuel))
+	wrapped := fmt.Errorf("calling guest: %w", err)
+	require.ErrorIs(t, wrapped, MemoryOutOfBounds)
+	var trap *Trap
+	require.True(t, errors.As(wrapped, &trap))
+
+	_, err = instance.GetFunc(store, "spin").Call(store)
+	require.ErrorIs(t, err, OutOfFuel)
+
+	require.False(t, errors.Is(NewTrap("x"), UnreachableCodeReached))
+	require.Equal(t, "wasm trap: out of bounds memory access", MemoryOutOfBounds.Error())
+}
+
+type cancelled struct{ reason string }
+
+func (c *cancelled) Error() string { return "cancelled: " + c.reason }
+
+func TestTrapPayload(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module
+	  (import "" "cancel" (func $cancel))
+	  (import "" "fail" (func $fail))
+	  (func (export "cancel") (call $cancel))
+	  (func (export "fail") (call $fail))
+	  (func (export "fault") unreachable))`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	token := &struct{}{}
+	cancel := WrapFunc(store, func() *Trap {
+		return NewTrapWithPayload("cancelled", &cancelled{"shutdown"})
+	})
+	fail := NewFunc(store, NewFuncType(nil, nil), func(*Caller, []Val) ([]Val, *Trap) {
+		return nil, NewTrapWithPayload("token", token)
+	})
+	instance, err := NewInstance(store, module, []AsExtern{cancel, fail})
+	require.NoError(t, err)
+
+	_, err = instance.GetFunc(store, "cancel").Call(store)
+	var c *cancelled
+	require.True(t, errors.As(err, &c))
+	require.Equal(t, "shutdown", c.reason)
+	var wrapped *Error
+	require.True(t, errors.As(err, &wrapped))
+	require.Equal(t, c, wrapped.Payload())
+
+	_, err = instance.GetFunc(store, "fail").Call(store)
+	require.Same(t, token, err.(*Error).Payload())
+	require.Nil(t, err.(*Error).Unwrap())
+
+	_, err = instance.GetFunc(store, "fault").Call(store)
+	require.Nil(t, err.(*Trap).Payload())
+	require.False(t, errors.As(err, &c))
+
+	expected := errors.New("hook failed")
+	store.SetCallHook(func(_ Storelike, hook CallHook) error {
+		if hook == CallHookCallingHost {
+			return expected
+		}
+		return nil
+	})
+	_, err = instance.GetFunc(store, "cancel").Call(store)
+	require.ErrorIs(t, err, expected)
+}
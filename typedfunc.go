@@ -0,0 +1,218 @@
+package wasmtime
+
+// #include <wasmtime.h>
+import "C"
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// WasmScalar is satisfied by the Go types of wasm's numeric values, which
+// are the parameter and result types of the functions returned by
+// `GetTypedFunc0` and friends.
+type WasmScalar interface {
+	int32 | int64 | float32 | float64
+}
+
+// GetTypedFunc0 returns a Go function which calls the exported function
+// `name` of `instance`, which must have no parameters and a single result of
+// type `R`.
+//
+// Unlike `Func.Call` the returned function doesn't box its arguments or
+// results, use reflection, or look up the function's type on every call,
+// which makes it suitable for calling the same function in a hot loop. As
+// with `Func.Call` the error returned by a call is a `*Trap` if the function
+// trapped.
+//
+// The returned function belongs to `store` and, like the store, mustn't be
+// called from multiple goroutines at once. Returns an error if `instance`
+// doesn't export a function named `name` with the expected type.
+func GetTypedFunc0[R WasmScalar](store Storelike, instance *Instance, name string) (func() (R, error), error) {
+	t, err := newTypedFunc(store, instance, name, nil, []ValKind{scalarKind[R]()})
+	if err != nil {
+		return nil, err
+	}
+	return func() (R, error) {
+		err := t.invoke()
+		return getScalar[R](&t.results[0]), err
+	}, nil
+}
+
+// GetTypedFunc1 is like `GetTypedFunc0` for functions with one parameter.
+func GetTypedFunc1[P1, R WasmScalar](store Storelike, instance *Instance, name string) (func(P1) (R, error), error) {
+	t, err := newTypedFunc(store, instance, name, []ValKind{scalarKind[P1]()}, []ValKind{scalarKind[R]()})
+	if err != nil {
+		return nil, err
+	}
+	return func(p1 P1) (R, error) {
+		setScalar(&t.params[0], p1)
+		err := t.invoke()
+		return getScalar[R](&t.results[0]), err
+	}, nil
+}
+
+// GetTypedFunc2 is like `GetTypedFunc0` for functions with two parameters.
+func GetTypedFunc2[P1, P2, R WasmScalar](store Storelike, instance *Instance, name string) (func(P1, P2) (R, error), error) {
+	t, err := newTypedFunc(store, instance, name, []ValKind{scalarKind[P1](), scalarKind[P2]()}, []ValKind{scalarKind[R]()})
+	if err != nil {
+		return nil, err
+	}
+	return func(p1 P1, p2 P2) (R, error) {
+		setScalar(&t.params[0], p1)
+		setScalar(&t.params[1], p2)
+		err := t.invoke()
+		return getScalar[R](&t.results[0]), err
+	}, nil
+}
+
+// GetTypedFunc3 is like `GetTypedFunc0` for functions with three parameters.
+func GetTypedFunc3[P1, P2, P3, R WasmScalar](store Storelike, instance *Instance, name string) (func(P1, P2, P3) (R, error), error) {
+	t, err := newTypedFunc(store, instance, name, []ValKind{scalarKind[P1](), scalarKind[P2](), scalarKind[P3]()}, []ValKind{scalarKind[R]()})
+	if err != nil {
+		return nil, err
+	}
+	return func(p1 P1, p2 P2, p3 P3) (R, error) {
+		setScalar(&t.params[0], p1)
+		setScalar(&t.params[1], p2)
+		setScalar(&t.params[2], p3)
+		err := t.invoke()
+		return getScalar[R](&t.results[0]), err
+	}, nil
+}
+
+// GetTypedFuncN0 is like `GetTypedFunc0` for functions with no parameters and
+// no results, such as initializers or callbacks run for their side effects.
+func GetTypedFuncN0(store Storelike, instance *Instance, name string) (func() error, error) {
+	t, err := newTypedFunc(store, instance, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return t.invoke, nil
+}
+
+// GetTypedFuncN1 is like `GetTypedFuncN0` for functions with one parameter.
+func GetTypedFuncN1[P1 WasmScalar](store Storelike, instance *Instance, name string) (func(P1) error, error) {
+	t, err := newTypedFunc(store, instance, name, []ValKind{scalarKind[P1]()}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return func(p1 P1) error {
+		setScalar(&t.params[0], p1)
+		return t.invoke()
+	}, nil
+}
+
+// GetTypedFuncN2 is like `GetTypedFuncN0` for functions with two parameters.
+func GetTypedFuncN2[P1, P2 WasmScalar](store Storelike, instance *Instance, name string) (func(P1, P2) error, error) {
+	t, err := newTypedFunc(store, instance, name, []ValKind{scalarKind[P1](), scalarKind[P2]()}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return func(p1 P1, p2 P2) error {
+		setScalar(&t.params[0], p1)
+		setScalar(&t.params[1], p2)
+		return t.invoke()
+	}, nil
+}
+
+// GetTypedFuncN3 is like `GetTypedFuncN0` for functions with three parameters.
+func GetTypedFuncN3[P1, P2, P3 WasmScalar](store Storelike, instance *Instance, name string) (func(P1, P2, P3) error, error) {
+	t, err := newTypedFunc(store, instance, name, []ValKind{scalarKind[P1](), scalarKind[P2](), scalarKind[P3]()}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return func(p1 P1, p2 P2, p3 P3) error {
+		setScalar(&t.params[0], p1)
+		setScalar(&t.params[1], p2)
+		setScalar(&t.params[2], p3)
+		return t.invoke()
+	}, nil
+}
+
+// The state of a function returned by `GetTypedFunc0` and friends, which is
+// allocated once rather than on every call.
+type typedFunc struct {
+	store   Storelike
+	f       *Func
+	params  []C.wasmtime_val_t
+	results []C.wasmtime_val_t
+	call    func(**C.wasm_trap_t) *C.wasmtime_error_t
+}
+
+func newTypedFunc(store Storelike, instance *Instance, name string, params, results []ValKind) (*typedFunc, error) {
+	f := instance.GetFunc(store, name)
+	if f == nil {
+		return nil, fmt.Errorf("instance has no exported function `%s`", name)
+	}
+	valTypes := func(kinds []ValKind) []*ValType {
+		ret := make([]*ValType, len(kinds))
+		for i, kind := range kinds {
+			ret[i] = NewValType(kind)
+		}
+		return ret
+	}
+	if !sameFuncType(f.Type(store), NewFuncType(valTypes(params), valTypes(results))) {
+		return nil, fmt.Errorf("function `%s` doesn't have the type requested", name)
+	}
+
+	// Allocate at least one parameter and result so there's always a pointer
+	// to pass.
+	t := &typedFunc{
+		store:   store,
+		f:       f,
+		params:  make([]C.wasmtime_val_t, len(params)+1),
+		results: make([]C.wasmtime_val_t, len(results)+1),
+	}
+	for i, kind := range params {
+		switch kind {
+		case KindI32:
+			t.params[i].kind = C.WASMTIME_I32
+		case KindI64:
+			t.params[i].kind = C.WASMTIME_I64
+		case KindF32:
+			t.params[i].kind = C.WASMTIME_F32
+		case KindF64:
+			t.params[i].kind = C.WASMTIME_F64
+		}
+	}
+	t.call = func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
+		return C.wasmtime_func_call(t.store.Context(), &t.f.val, &t.params[0], C.size_t(len(params)), &t.results[0], C.size_t(len(results)), trap)
+	}
+	return t, nil
+}
+
+func (t *typedFunc) invoke() error {
+	if lift := getDataInStore(t.store).applyCallGrowthLimit(t.store); lift != nil {
+		defer lift()
+	}
+	err := enterWasm(t.store, t.call)
+	runtime.KeepAlive(t)
+	return err
+}
+
+// Returns the kind of wasm value represented by `T`.
+func scalarKind[T WasmScalar]() ValKind {
+	var zero T
+	switch interface{}(zero).(type) {
+	case int32:
+		return KindI32
+	case int64:
+		return KindI64
+	case float32:
+		return KindF32
+	}
+	return KindF64
+}
+
+// Every numeric member of `wasmtime_valunion_t` is at its start, so values
+// are accessed directly rather than through the accessors in shims.c, which
+// would cost a cgo call each.
+
+func setScalar[T WasmScalar](dst *C.wasmtime_val_t, val T) {
+	*(*T)(unsafe.Pointer(&dst.of)) = val
+}
+
+func getScalar[T WasmScalar](src *C.wasmtime_val_t) T {
+	return *(*T)(unsafe.Pointer(&src.of))
+}
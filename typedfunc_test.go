@@ -0,0 +1,126 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTypedFunc(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`
+	(module
+	  (global $n (mut i64) (i64.const 0))
+	  (func (export "next") (result i64)
+	    (global.set $n (i64.add (global.get $n) (i64.const 1)))
+	    (global.get $n))
+	  (func (export "neg") (param f32) (result f32) (f32.neg (local.get 0)))
+	  (func (export "add") (param i32 i32) (result i32) (i32.add (local.get 0) (local.get 1)))
+	  (func (export "mad") (param f64 f64 i64) (result f64)
+	    (f64.add (f64.mul (local.get 0) (local.get 1)) (f64.convert_i64_s (local.get 2))))
+	  (func (export "div") (param i32 i32) (result i32) (i32.div_s (local.get 0) (local.get 1)))
+	  (func (export "reset") (global.set $n (i64.const 0)))
+	  (func (export "set") (param i64) (global.set $n (local.get 0)))
+	  (func (export "set_sum") (param i64 i32) (global.set $n (i64.add (local.get 0) (i64.extend_i32_s (local.get 1)))))
+	  (func (export "set_if") (param i32 i64 i64)
+	    (if (i32.eqz (local.get 0)) (then unreachable))
+	    (global.set $n (i64.mul (local.get 1) (local.get 2))))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+
+	next, err := GetTypedFunc0[int64](store, instance, "next")
+	require.NoError(t, err)
+	n, err := next()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	neg, err := GetTypedFunc1[float32, float32](store, instance, "neg")
+	require.NoError(t, err)
+	f, err := neg(1.5)
+	require.NoError(t, err)
+	require.Equal(t, float32(-1.5), f)
+
+	add, err := GetTypedFunc2[int32, int32, int32](store, instance, "add")
+	require.NoError(t, err)
+	sum, err := add(-3, 10)
+	require.NoError(t, err)
+	require.Equal(t, int32(7), sum)
+
+	mad, err := GetTypedFunc3[float64, float64, int64, float64](store, instance, "mad")
+	require.NoError(t, err)
+	d, err := mad(2, 3.5, -1)
+	require.NoError(t, err)
+	require.Equal(t, float64(6), d)
+
+	div, err := GetTypedFunc2[int32, int32, int32](store, instance, "div")
+	require.NoError(t, err)
+	_, err = div(1, 0)
+	var trap *Trap
+	require.ErrorAs(t, err, &trap)
+	require.Equal(t, IntegerDivisionByZero, *trap.Code())
+
+	reset, err := GetTypedFuncN0(store, instance, "reset")
+	require.NoError(t, err)
+	require.NoError(t, reset())
+	n, err = next()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	set, err := GetTypedFuncN1[int64](store, instance, "set")
+	require.NoError(t, err)
+	require.NoError(t, set(41))
+	n, err = next()
+	require.NoError(t, err)
+	require.Equal(t, int64(42), n)
+
+	setSum, err := GetTypedFuncN2[int64, int32](store, instance, "set_sum")
+	require.NoError(t, err)
+	require.NoError(t, setSum(10, -3))
+	n, err = next()
+	require.NoError(t, err)
+	require.Equal(t, int64(8), n)
+
+	setIf, err := GetTypedFuncN3[int32, int64, int64](store, instance, "set_if")
+	require.NoError(t, err)
+	require.NoError(t, setIf(1, 6, 7))
+	n, err = next()
+	require.NoError(t, err)
+	require.Equal(t, int64(43), n)
+	require.ErrorAs(t, setIf(0, 1, 1), &trap)
+	require.Equal(t, UnreachableCodeReached, *trap.Code())
+
+	_, err = GetTypedFuncN0(store, instance, "next")
+	require.Error(t, err)
+	_, err = GetTypedFuncN1[int32](store, instance, "set")
+	require.Error(t, err)
+	_, err = GetTypedFunc2[int32, int64, int32](store, instance, "add")
+	require.Error(t, err)
+	_, err = GetTypedFunc0[int32](store, instance, "next")
+	require.Error(t, err)
+	_, err = GetTypedFunc0[int32](store, instance, "missing")
+	require.Error(t, err)
+}
+
+func TestGetTypedFuncAllocs(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module (func (export "add") (param i32 i32) (result i32) (i32.add (local.get 0) (local.get 1))))`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+	add, err := GetTypedFunc2[int32, int32, int32](store, instance, "add")
+	require.NoError(t, err)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := add(1, 2); err != nil {
+			panic(err)
+		}
+	})
+	require.Zero(t, allocs)
+}
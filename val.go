@@ -11,6 +11,7 @@ import (
 
 var gExternrefLock sync.Mutex
 var gExternrefMap = make(map[int]interface{})
+var gExternrefFinalizers = make(map[int]func(interface{}))
 var gExternrefSlab slab
 
 // Val is a primitive numeric value.
@@ -47,6 +48,14 @@ func ValF64(val float64) Val {
 	return ret
 }
 
+// ValV128 converts 16 bytes to a v128 Val, where `val[0]` is the vector's
+// least significant byte, as it's stored in memory.
+func ValV128(val [16]byte) Val {
+	ret := Val{_raw: &C.wasmtime_val_t{kind: C.WASMTIME_V128}}
+	*(*[16]byte)(unsafe.Pointer(&ret._raw.of)) = val
+	return ret
+}
+
 // ValFuncref converts a Func to a funcref Val
 //
 // Note that `f` can be `nil` to represent a null `funcref`.
@@ -64,6 +73,27 @@ func ValFuncref(f *Func) Val {
 // module for it to store. Later, when you get a `Val`, you can extract the type
 // with the `Externref()` method.
 func ValExternref(val interface{}) Val {
+	return newExternref(val, nil)
+}
+
+// ValExternrefWithFinalizer is like `ValExternref`, but calls `finalizer`
+// with `val` once the externref is no longer referenced by anything.
+//
+// References are held by `Val`s in Go, until they're garbage collected or
+// closed with `Val.Close`, and by WebAssembly, such as in tables, globals and
+// the stacks of running functions. Wasmtime only notices that WebAssembly has
+// dropped a reference the next time it collects garbage, which
+// `Store.GC` triggers, so closing the host's `Val`s and calling `Store.GC`
+// releases the externref deterministically.
+//
+// `finalizer` may be invoked on any goroutine using the store, including
+// during calls to WebAssembly, so it should only release resources, such as
+// closing a connection, rather than calling into wasmtime.
+func ValExternrefWithFinalizer(val interface{}, finalizer func(interface{})) Val {
+	return newExternref(val, finalizer)
+}
+
+func newExternref(val interface{}, finalizer func(interface{})) Val {
 	ret := Val{_raw: &C.wasmtime_val_t{kind: C.WASMTIME_EXTERNREF}}
 
 	// If we have a non-nil value then store it in our global map of all
@@ -77,6 +107,9 @@ func ValExternref(val interface{}) Val {
 		defer gExternrefLock.Unlock()
 		index := gExternrefSlab.allocate()
 		gExternrefMap[index] = val
+		if finalizer != nil {
+			gExternrefFinalizers[index] = finalizer
+		}
 		ptr := C.go_externref_new(C.size_t(index + 1))
 		C.go_wasmtime_val_externref_set(ret.ptr(), ptr)
 		ret.setDtor()
@@ -88,9 +121,15 @@ func ValExternref(val interface{}) Val {
 func goFinalizeExternref(env unsafe.Pointer) {
 	idx := int(uintptr(env)) - 1
 	gExternrefLock.Lock()
-	defer gExternrefLock.Unlock()
+	val, finalizer := gExternrefMap[idx], gExternrefFinalizers[idx]
 	delete(gExternrefMap, idx)
+	delete(gExternrefFinalizers, idx)
 	gExternrefSlab.deallocate(idx)
+	gExternrefLock.Unlock()
+
+	if finalizer != nil {
+		finalizer(val)
+	}
 }
 
 func mkVal(src *C.wasmtime_val_t) Val {
@@ -119,6 +158,22 @@ func (v Val) ptr() *C.wasmtime_val_t {
 	return ret
 }
 
+// Close releases this value's reference to an externref without waiting for
+// it to be garbage collected, after which it's a null externref. This also
+// affects every copy of this `Val`, but not values obtained separately for the
+// same externref, such as by reading it back from a table.
+//
+// Closing values of other kinds has no effect. Values returned by
+// `Store.ExternRefOf` are kept by the store and mustn't be closed.
+func (v Val) Close() {
+	if v._raw == nil || v._raw.kind != C.WASMTIME_EXTERNREF {
+		return
+	}
+	runtime.SetFinalizer(v._raw, nil)
+	C.wasmtime_val_delete(v._raw)
+	*v._raw = C.wasmtime_val_t{kind: C.WASMTIME_EXTERNREF}
+}
+
 // Kind returns the kind of value that this `Val` contains.
 func (v Val) Kind() ValKind {
 	switch v.ptr().kind {
@@ -134,6 +189,8 @@ func (v Val) Kind() ValKind {
 		return KindFuncref
 	case C.WASMTIME_EXTERNREF:
 		return KindExternref
+	case C.WASMTIME_V128:
+		return KindV128
 	}
 	panic("failed to get kind of `Val`")
 }
@@ -170,6 +227,17 @@ func (v Val) F64() float64 {
 	return float64(C.go_wasmtime_val_f64_get(v.ptr()))
 }
 
+// V128 returns the bytes of the underlying vector if this is a `v128`, or
+// panics. The first byte is the vector's least significant byte.
+func (v Val) V128() [16]byte {
+	if v.Kind() != KindV128 {
+		panic("not a v128")
+	}
+	ret := *(*[16]byte)(unsafe.Pointer(&v.ptr().of))
+	runtime.KeepAlive(v)
+	return ret
+}
+
 // Funcref returns the underlying function if this is a `funcref`, or panics.
 //
 // Note that a null `funcref` is returned as `nil`.
@@ -218,6 +286,8 @@ func (v Val) Get() interface{} {
 		return v.Funcref()
 	case KindExternref:
 		return v.Externref()
+	case KindV128:
+		return v.V128()
 	}
 	panic("failed to get value of `Val`")
 }
@@ -0,0 +1,102 @@
+package wasmtime
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidationErrorKind classifies why a module failed validation, see
+// `ValidationError`.
+type ValidationErrorKind int
+
+const (
+	// The module was decoded but isn't valid, for example because of a type
+	// mismatch or a reference to something which doesn't exist.
+	ValidationInvalid ValidationErrorKind = iota
+	// The module isn't a well-formed WebAssembly binary, for example because
+	// it's truncated or has an unknown section.
+	ValidationMalformed
+	// The module uses a proposal which isn't enabled in the engine's `Config`.
+	ValidationFeatureDisabled
+)
+
+func (kind ValidationErrorKind) String() string {
+	switch kind {
+	case ValidationInvalid:
+		return "invalid"
+	case ValidationMalformed:
+		return "malformed"
+	case ValidationFeatureDisabled:
+		return "feature disabled"
+	}
+	return "unknown"
+}
+
+// ValidationError is returned by `ModuleValidateDetailed` to describe why a
+// module failed validation.
+type ValidationError struct {
+	Kind ValidationErrorKind
+	// The offset within the binary at which validation failed, or -1 if it
+	// isn't known.
+	Offset int64
+	// What was wrong with the module at `Offset`, such as "type mismatch:
+	// expected i32, found i64".
+	Reason string
+
+	err *Error
+}
+
+func (e *ValidationError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying `*Error` from wasmtime.
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+var validationOffset = regexp.MustCompile(`^(.*) \(at offset 0x([0-9a-f]+)\)$`)
+
+// Fragments of the messages of decoding errors, as opposed to validation
+// errors, which the C API doesn't otherwise distinguish.
+var malformedMessages = []string{
+	"unexpected end", "magic header", "unknown binary version", "malformed",
+	"invalid leb128", "integer too large", "integer representation too long",
+	"section size mismatch", "unexpected content", "unknown section",
+	"invalid section", "illegal opcode",
+}
+
+// ModuleValidateDetailed is the same as `ModuleValidate` except that, when the
+// module fails validation, it returns a `*ValidationError` with the offset at
+// which it failed and a classification of the reason, for example so that a
+// service accepting uploaded modules can reject them with actionable messages
+// without compiling them.
+//
+// The classification is derived from wasmtime's error message, since the C API
+// doesn't expose structured validation errors.
+func ModuleValidateDetailed(engine *Engine, wasm []byte) error {
+	err := ModuleValidate(engine, wasm)
+	var wrapped *Error
+	if err == nil || !errors.As(err, &wrapped) {
+		return err
+	}
+	ret := &ValidationError{Offset: -1, Reason: wrapped.Error(), err: wrapped}
+	if m := validationOffset.FindStringSubmatch(ret.Reason); m != nil {
+		ret.Reason = m[1]
+		ret.Offset, _ = strconv.ParseInt(m[2], 16, 64)
+	}
+	switch {
+	case strings.Contains(ret.Reason, "must be enabled") || strings.Contains(ret.Reason, "not enabled"):
+		ret.Kind = ValidationFeatureDisabled
+	default:
+		for _, message := range malformedMessages {
+			if strings.Contains(ret.Reason, message) {
+				ret.Kind = ValidationMalformed
+				break
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,44 @@
+package wasmtime
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleValidateDetailed(t *testing.T) {
+	engine := NewEngine()
+	validate := func(wasm []byte) *ValidationError {
+		err := ModuleValidateDetailed(engine, wasm)
+		require.Error(t, err)
+		var ret *ValidationError
+		require.True(t, errors.As(err, &ret))
+		var wrapped *Error
+		require.True(t, errors.As(err, &wrapped))
+		return ret
+	}
+
+	wasm, err := Wat2Wasm(`(module (func (result i32) i64.const 1))`)
+	require.NoError(t, err)
+	e := validate(wasm)
+	require.Equal(t, ValidationInvalid, e.Kind)
+	require.Equal(t, "type mismatch: expected i32, found i64", e.Reason)
+	require.Equal(t, int64(0x1a), e.Offset)
+	require.Contains(t, e.Error(), "at offset 0x1a")
+
+	e = validate([]byte{0, 'a', 's', 'm', 1, 0, 0, 0, 1, 5})
+	require.Equal(t, ValidationMalformed, e.Kind)
+	require.Equal(t, int64(10), e.Offset)
+
+	wasm, err = Wat2Wasm(`(module (memory i64 1))`)
+	require.NoError(t, err)
+	config := NewConfig()
+	config.SetWasmMemory64(false)
+	err = ModuleValidateDetailed(NewEngineWithConfig(config), wasm)
+	require.Equal(t, ValidationFeatureDisabled, err.(*ValidationError).Kind)
+
+	wasm, err = Wat2Wasm(`(module)`)
+	require.NoError(t, err)
+	require.NoError(t, ModuleValidateDetailed(engine, wasm))
+}
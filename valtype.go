@@ -1,6 +1,7 @@
 package wasmtime
 
 // #include <wasm.h>
+// #include <wasmtime.h>
 import "C"
 import "runtime"
 
@@ -20,6 +21,11 @@ const (
 	KindExternref ValKind = C.WASM_ANYREF
 	// KindFuncref is the infinite union of all function types.
 	KindFuncref ValKind = C.WASM_FUNCREF
+	// KindV128 is the type v128, which classifies 128 bit vectors of packed
+	// integer or floating-point data, used by the SIMD proposal. `wasm.h`
+	// doesn't name it, but wasmtime uses the same value for it as
+	// `wasmtime_valkind_t` does.
+	KindV128 ValKind = C.WASMTIME_V128
 )
 
 // String renders this kind as a string, similar to the `*.wat` format
@@ -37,6 +43,8 @@ func (ty ValKind) String() string {
 		return "externref"
 	case KindFuncref:
 		return "funcref"
+	case KindV128:
+		return "v128"
 	}
 	panic("unknown kind")
 }
@@ -78,10 +86,25 @@ func (t *ValType) String() string {
 
 func (t *ValType) ptr() *C.wasm_valtype_t {
 	ret := t._ptr
+	if ret == nil {
+		panic("ValType used after Close")
+	}
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this type without waiting for it to be garbage collected.
+// It has no effect on types which belong to another object, such as those
+// returned from the methods of other types.
+func (t *ValType) Close() {
+	if t._ptr == nil || t._owner != nil {
+		return
+	}
+	runtime.SetFinalizer(t, nil)
+	C.wasm_valtype_delete(t._ptr)
+	t._ptr = nil
+}
+
 func (t *ValType) owner() interface{} {
 	if t._owner != nil {
 		return t._owner
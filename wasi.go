@@ -6,30 +6,103 @@ package wasmtime
 import "C"
 import (
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
 	"os"
+	"reflect"
 	"runtime"
+	"sort"
+	"time"
 	"unsafe"
 )
 
 type WasiConfig struct {
 	_ptr *C.wasi_config_t
+
+	// What has been configured so far, see `Describe`.
+	desc WasiDescription
+	// The number of preopened directories, and which of them are scratch
+	// directories.
+	preopens int
+	scratch  []*wasiScratch
+	// Stops the background goroutines copying stdio to and from connections,
+	// see `SetStdioConn`.
+	closers []func()
+	// The guest path of every preopened directory, including duplicates,
+	// and misconfigurations noticed while configuring, see `Validate`.
+	mounts   []string
+	problems []string
+}
+
+// WasiDescription describes what a `WasiConfig` grants a guest access to, as
+// returned by `WasiConfig.Describe`.
+type WasiDescription struct {
+	Argv        []string
+	InheritArgv bool
+	Env         map[string]string
+	InheritEnv  bool
+	// Where each stdio stream is connected, such as "inherit", "file:<path>",
+	// or "conn:<address>", or empty if it isn't connected.
+	Stdin  string
+	Stdout string
+	Stderr string
+	// Preopened directories, mapping the guest path to the host path.
+	Preopens map[string]string
+	// The rate at which time passes for the guest, see
+	// `WasiConfig.SetClockScale`.
+	ClockScale float64
 }
 
 func NewWasiConfig() *WasiConfig {
 	ptr := C.wasi_config_new()
-	config := &WasiConfig{_ptr: ptr}
+	config := &WasiConfig{_ptr: ptr, desc: WasiDescription{
+		Env:        map[string]string{},
+		Preopens:   map[string]string{},
+		ClockScale: 1,
+	}}
 	runtime.SetFinalizer(config, func(config *WasiConfig) {
 		C.wasi_config_delete(config._ptr)
+		config.release()
 	})
 	return config
 }
 
+// Removes the scratch directories of a configuration which was never used,
+// and stops copying its stdio.
+func (c *WasiConfig) release() {
+	for _, s := range c.scratch {
+		s.remove()
+	}
+	runClosers(c.closers)
+}
+
+func runClosers(closers []func()) {
+	for _, f := range closers {
+		f()
+	}
+}
+
 func (c *WasiConfig) ptr() *C.wasi_config_t {
 	ret := c._ptr
 	maybeGC()
 	return ret
 }
 
+// Close deallocates this configuration without waiting for it to be garbage
+// collected, if it hasn't already been passed to `Store.SetWasiConfig`. The
+// result of `Describe` remains available.
+func (c *WasiConfig) Close() {
+	if c._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(c, nil)
+	C.wasi_config_delete(c._ptr)
+	c._ptr = nil
+	c.release()
+}
+
 // SetArgv will explicitly configure the argv for this WASI configuration.
 // Note that this field can only be set, it cannot be read
 func (c *WasiConfig) SetArgv(argv []string) {
@@ -46,11 +119,15 @@ func (c *WasiConfig) SetArgv(argv []string) {
 	for _, ptr := range ptrs {
 		C.free(unsafe.Pointer(ptr))
 	}
+	c.desc.Argv = append([]string{}, argv...)
+	c.desc.InheritArgv = false
 }
 
 func (c *WasiConfig) InheritArgv() {
 	C.wasi_config_inherit_argv(c.ptr())
 	runtime.KeepAlive(c)
+	c.desc.Argv = nil
+	c.desc.InheritArgv = true
 }
 
 // SetEnv configures environment variables to be returned for this WASI configuration.
@@ -79,11 +156,21 @@ func (c *WasiConfig) SetEnv(keys, values []string) {
 		C.free(unsafe.Pointer(ptr))
 		C.free(unsafe.Pointer(valuePtrs[i]))
 	}
+	c.desc.Env = make(map[string]string, len(keys))
+	for i, key := range keys {
+		if _, ok := c.desc.Env[key]; ok {
+			c.problems = append(c.problems, fmt.Sprintf("environment variable %q is set more than once", key))
+		}
+		c.desc.Env[key] = values[i]
+	}
+	c.desc.InheritEnv = false
 }
 
 func (c *WasiConfig) InheritEnv() {
 	C.wasi_config_inherit_env(c.ptr())
 	runtime.KeepAlive(c)
+	c.desc.Env = map[string]string{}
+	c.desc.InheritEnv = true
 }
 
 func (c *WasiConfig) SetStdinFile(path string) error {
@@ -92,6 +179,7 @@ func (c *WasiConfig) SetStdinFile(path string) error {
 	runtime.KeepAlive(c)
 	C.free(unsafe.Pointer(pathC))
 	if ok {
+		c.setStdio("stdin", &c.desc.Stdin, "file:"+path)
 		return nil
 	}
 
@@ -101,6 +189,7 @@ func (c *WasiConfig) SetStdinFile(path string) error {
 func (c *WasiConfig) InheritStdin() {
 	C.wasi_config_inherit_stdin(c.ptr())
 	runtime.KeepAlive(c)
+	c.setStdio("stdin", &c.desc.Stdin, "inherit")
 }
 
 func (c *WasiConfig) SetStdoutFile(path string) error {
@@ -109,6 +198,7 @@ func (c *WasiConfig) SetStdoutFile(path string) error {
 	runtime.KeepAlive(c)
 	C.free(unsafe.Pointer(pathC))
 	if ok {
+		c.setStdio("stdout", &c.desc.Stdout, "file:"+path)
 		return nil
 	}
 
@@ -118,6 +208,7 @@ func (c *WasiConfig) SetStdoutFile(path string) error {
 func (c *WasiConfig) InheritStdout() {
 	C.wasi_config_inherit_stdout(c.ptr())
 	runtime.KeepAlive(c)
+	c.setStdio("stdout", &c.desc.Stdout, "inherit")
 }
 
 func (c *WasiConfig) SetStderrFile(path string) error {
@@ -126,6 +217,7 @@ func (c *WasiConfig) SetStderrFile(path string) error {
 	runtime.KeepAlive(c)
 	C.free(unsafe.Pointer(pathC))
 	if ok {
+		c.setStdio("stderr", &c.desc.Stderr, "file:"+path)
 		return nil
 	}
 
@@ -135,6 +227,138 @@ func (c *WasiConfig) SetStderrFile(path string) error {
 func (c *WasiConfig) InheritStderr() {
 	C.wasi_config_inherit_stderr(c.ptr())
 	runtime.KeepAlive(c)
+	c.setStdio("stderr", &c.desc.Stderr, "inherit")
+}
+
+// SetStdioConn configures stdin to read from `conn` and stdout to write to
+// `conn`, so a guest which speaks a protocol over stdio can be attached
+// directly to a network connection.
+//
+// Data is copied between `conn` and the guest through pipes by background
+// goroutines, which stop once the `Store` this configuration is used with is
+// closed or garbage collected, or its WASI configuration is replaced, or once
+// this configuration is closed or garbage collected without being used. To
+// stop copying from `conn` its read deadline is set to the past, so it must
+// be reset with `SetReadDeadline` before reading from `conn` again. Note that
+// `conn` is never closed by this function.
+//
+// This is not supported on Windows.
+func (c *WasiConfig) SetStdioConn(conn net.Conn) error {
+	if runtime.GOOS == "windows" {
+		return errors.New("stdio connections are not supported on windows")
+	}
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer stdinR.Close()
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinW.Close()
+		return err
+	}
+	defer stdoutW.Close()
+
+	// wasmtime opens its own handles to the guest's ends of the pipes, so ours
+	// are closed once configured to ensure EOF is delivered on both sides.
+	err = c.SetStdinFile(fdPath(stdinR))
+	if err == nil {
+		err = c.SetStdoutFile(fdPath(stdoutW))
+	}
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return err
+	}
+	c.desc.Stdin = "conn:" + conn.RemoteAddr().String()
+	c.desc.Stdout = c.desc.Stdin
+	// Copying to `conn` ends by itself once wasmtime closes its end of the
+	// stdout pipe, but reading from `conn` may block indefinitely.
+	stopped := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(stdinW, conn)
+		stdinW.Close()
+		close(stopped)
+	}()
+	go func() {
+		_, _ = io.Copy(conn, stdoutR)
+		stdoutR.Close()
+	}()
+	c.closers = append(c.closers, func() {
+		select {
+		case <-stopped:
+		default:
+			_ = conn.SetReadDeadline(time.Unix(1, 0))
+		}
+	})
+	return nil
+}
+
+// SetStdoutWriter configures stdout to be written to `w`, for example a
+// `SanitizingWriter` to clean up untrusted output before it reaches logs.
+//
+// Output is copied to `w` through a pipe by a background goroutine, so it's
+// delivered asynchronously. Copying stops, and `w` is flushed if it has a
+// `Flush() error` method, once the `Store` this configuration is used with is
+// closed or garbage collected, or its WASI configuration is replaced. Note
+// that `w` is never closed by this function.
+//
+// This is not supported on Windows.
+func (c *WasiConfig) SetStdoutWriter(w io.Writer) error {
+	if err := c.pipeTo(w, c.SetStdoutFile); err != nil {
+		return err
+	}
+	c.desc.Stdout = fmt.Sprintf("writer:%T", w)
+	return nil
+}
+
+// SetStderrWriter is the same as `SetStdoutWriter` except that it configures
+// stderr.
+func (c *WasiConfig) SetStderrWriter(w io.Writer) error {
+	if err := c.pipeTo(w, c.SetStderrFile); err != nil {
+		return err
+	}
+	c.desc.Stderr = fmt.Sprintf("writer:%T", w)
+	return nil
+}
+
+// Connects a stdio stream, configured with `set`, to `w` through a pipe.
+func (c *WasiConfig) pipeTo(w io.Writer, set func(path string) error) error {
+	if runtime.GOOS == "windows" {
+		return errors.New("stdio writers are not supported on windows")
+	}
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	// As with `SetStdioConn`, wasmtime opens its own handle to the write end.
+	defer pw.Close()
+	if err := set(fdPath(pw)); err != nil {
+		r.Close()
+		return err
+	}
+	go func() {
+		_, _ = io.Copy(w, r)
+		if f, ok := w.(interface{ Flush() error }); ok {
+			_ = f.Flush()
+		}
+		r.Close()
+	}()
+	return nil
+}
+
+// Records that a stdio stream is now connected to `value`, noting a problem if
+// it was already connected elsewhere, since only the last setting takes effect.
+func (c *WasiConfig) setStdio(name string, stream *string, value string) {
+	if *stream != "" && *stream != value {
+		c.problems = append(c.problems, fmt.Sprintf("%s is connected to both %q and %q", name, *stream, value))
+	}
+	*stream = value
+}
+
+// Returns a path which opens the same file as `file`.
+func fdPath(file *os.File) string {
+	return fmt.Sprintf("/dev/fd/%d", file.Fd())
 }
 
 func (c *WasiConfig) PreopenDir(path, guestPath string) error {
@@ -145,12 +369,107 @@ func (c *WasiConfig) PreopenDir(path, guestPath string) error {
 	C.free(unsafe.Pointer(pathC))
 	C.free(unsafe.Pointer(guestPathC))
 	if ok {
+		c.desc.Preopens[guestPath] = path
+		c.mounts = append(c.mounts, guestPath)
+		c.preopens++
 		return nil
 	}
 
 	return errors.New("failed to preopen directory")
 }
 
+// SetClockScale configures the rate at which time passes for the guest relative
+// to the host, for example to accelerate simulations or to test timeout logic.
+// A `factor` of 2 makes the guest's realtime and monotonic clocks advance twice
+// as fast, and sleeps through `poll_oneoff` complete in half the time.
+//
+// Time is scaled from the first time the guest reads each clock. Returns an
+// error if `factor` isn't a finite positive number.
+//
+// The scale is applied by the wrappers of `WasiLinkOptions.ClockScale`, so
+// instantiating a guest which reads the clocks with a linker which doesn't
+// define them returns an error, rather than letting the guest see real time.
+func (c *WasiConfig) SetClockScale(factor float64) error {
+	if !(factor > 0) || math.IsInf(factor, 0) {
+		return fmt.Errorf("invalid clock scale %v", factor)
+	}
+	c.desc.ClockScale = factor
+	return nil
+}
+
+// Describe returns a description of everything this configuration grants a
+// guest access to, which can be used to audit sandbox configurations.
+//
+// This continues to work after the configuration has been passed to
+// `Store.SetWasiConfig`.
+func (c *WasiConfig) Describe() WasiDescription {
+	ret := c.desc
+	ret.Argv = append([]string(nil), c.desc.Argv...)
+	ret.Env = make(map[string]string, len(c.desc.Env))
+	for key, value := range c.desc.Env {
+		ret.Env[key] = value
+	}
+	ret.Preopens = make(map[string]string, len(c.desc.Preopens))
+	for guest, host := range c.desc.Preopens {
+		ret.Preopens[guest] = host
+	}
+	return ret
+}
+
+// DiffWasiConfigs returns a human-readable list of the differences between what
+// the configurations `a` and `b` grant a guest access to, or an empty list if
+// they grant the same access.
+func DiffWasiConfigs(a, b *WasiConfig) []string {
+	var ret []string
+	da, db := a.Describe(), b.Describe()
+	diff := func(what string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			ret = append(ret, fmt.Sprintf("%s: %q != %q", what, a, b))
+		}
+	}
+	diffMap := func(what string, a, b map[string]string) {
+		for _, key := range sortedKeys(a, b) {
+			va, oka := a[key]
+			vb, okb := b[key]
+			switch {
+			case !okb:
+				ret = append(ret, fmt.Sprintf("%s %q: only in first (%q)", what, key, va))
+			case !oka:
+				ret = append(ret, fmt.Sprintf("%s %q: only in second (%q)", what, key, vb))
+			case va != vb:
+				ret = append(ret, fmt.Sprintf("%s %q: %q != %q", what, key, va, vb))
+			}
+		}
+	}
+	diff("argv", da.Argv, db.Argv)
+	diff("inherit argv", da.InheritArgv, db.InheritArgv)
+	diffMap("env", da.Env, db.Env)
+	diff("inherit env", da.InheritEnv, db.InheritEnv)
+	diff("stdin", da.Stdin, db.Stdin)
+	diff("stdout", da.Stdout, db.Stdout)
+	diff("stderr", da.Stderr, db.Stderr)
+	diffMap("preopen", da.Preopens, db.Preopens)
+	if da.ClockScale != db.ClockScale {
+		ret = append(ret, fmt.Sprintf("clock scale: %v != %v", da.ClockScale, db.ClockScale))
+	}
+	return ret
+}
+
+// Returns the keys present in either `a` or `b`, sorted.
+func sortedKeys(a, b map[string]string) []string {
+	var ret []string
+	for key := range a {
+		ret = append(ret, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			ret = append(ret, key)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
 // FileAccessMode Indicates whether the file-like object being inserted into the
 // WASI configuration (by PushFile and InsertFile) can be used to read, write,
 // or both using bitflags. This seems to be a wasmtime specific mapping as it
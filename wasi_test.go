@@ -1,8 +1,13 @@
 package wasmtime
 
 import (
+	"bufio"
+	"bytes"
+	"net"
 	"os"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -240,3 +245,166 @@ func TestWasiCtx(t *testing.T) {
 	require.Equal(t, "thank you\n", string(out))
 	t.Logf("WASI output: %s", string(out))
 }
+
+func TestWasiStdioConn(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stdio connections are not supported on windows")
+	}
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "wasi_snapshot_preview1" "fd_read" (func $fd_read (param i32 i32 i32 i32) (result i32)))
+	  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  ;; reads a chunk from stdin and writes it back to stdout
+	  (func (export "echo")
+	    (i32.store (i32.const 0) (i32.const 16))
+	    (i32.store (i32.const 4) (i32.const 64))
+	    (drop (call $fd_read (i32.const 0) (i32.const 0) (i32.const 1) (i32.const 8)))
+	    (i32.store (i32.const 4) (i32.load (i32.const 8)))
+	    (drop (call $fd_write (i32.const 1) (i32.const 0) (i32.const 1) (i32.const 8))))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineWasi())
+
+	host, guest := net.Pipe()
+	defer host.Close()
+	defer guest.Close()
+	config := NewWasiConfig()
+	require.NoError(t, config.SetStdioConn(guest))
+	store := NewStore(engine)
+	store.SetWasiConfig(config)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(host)
+	for _, msg := range []string{"ping\n", "pong\n"} {
+		go func(msg string) { _, _ = host.Write([]byte(msg)) }(msg)
+		_, err = instance.GetFunc(store, "echo").Call(store)
+		require.NoError(t, err)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, msg, line)
+	}
+}
+
+func TestWasiStdioConnLeak(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stdio connections are not supported on windows")
+	}
+	engine := NewEngine()
+	before := runtime.NumGoroutine()
+	var conns []net.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	connect := func() *WasiConfig {
+		host, guest := net.Pipe()
+		conns = append(conns, host, guest)
+		config := NewWasiConfig()
+		require.NoError(t, config.SetStdioConn(guest))
+		return config
+	}
+
+	// closing the store, replacing its configuration, and closing an unused
+	// configuration all stop the copies even though the connections are open
+	store := NewStore(engine)
+	store.SetWasiConfig(connect())
+	store.SetWasiConfig(connect())
+	store.Close()
+	connect().Close()
+	require.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= before
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+type flushNotifier struct {
+	bytes.Buffer
+	flushed chan struct{}
+}
+
+func (w *flushNotifier) Flush() error {
+	close(w.flushed)
+	return nil
+}
+
+func TestWasiStdoutWriter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stdio writers are not supported on windows")
+	}
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  (data (i32.const 16) "\1b[31mhello\ff\n")
+	  (func (export "run")
+	    (i32.store (i32.const 0) (i32.const 16))
+	    (i32.store (i32.const 4) (i32.const 12))
+	    (drop (call $fd_write (i32.const 1) (i32.const 0) (i32.const 1) (i32.const 8))))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineWasi())
+
+	out := &flushNotifier{flushed: make(chan struct{})}
+	config := NewWasiConfig()
+	require.NoError(t, config.SetStdoutWriter(NewSanitizingWriter(out, SanitizeOptions{
+		StripANSI:          true,
+		ReplaceInvalidUTF8: true,
+	})))
+	require.Equal(t, "writer:*wasmtime.SanitizingWriter", config.Describe().Stdout)
+	store := NewStore(engine)
+	store.SetWasiConfig(config)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "run").Call(store)
+	require.NoError(t, err)
+	store.Close()
+
+	<-out.flushed
+	require.Equal(t, "hello\uFFFD\n", out.String())
+}
+
+func TestWasiConfigDescribe(t *testing.T) {
+	dir := t.TempDir()
+	a := NewWasiConfig()
+	a.SetArgv([]string{"prog", "-v"})
+	a.SetEnv([]string{"HOME", "LANG"}, []string{"/home", "C"})
+	a.InheritStdout()
+	require.NoError(t, a.PreopenDir(dir, "/data"))
+
+	desc := a.Describe()
+	require.Equal(t, []string{"prog", "-v"}, desc.Argv)
+	require.Equal(t, map[string]string{"HOME": "/home", "LANG": "C"}, desc.Env)
+	require.Equal(t, "inherit", desc.Stdout)
+	require.Equal(t, "", desc.Stdin)
+	require.Equal(t, map[string]string{"/data": dir}, desc.Preopens)
+
+	b := NewWasiConfig()
+	b.SetArgv([]string{"prog", "-v"})
+	b.SetEnv([]string{"HOME", "PATH"}, []string{"/root", "/bin"})
+	b.InheritStdout()
+	b.InheritStderr()
+	require.Empty(t, DiffWasiConfigs(a, a))
+	require.Equal(t, []string{
+		`env "HOME": "/home" != "/root"`,
+		`env "LANG": only in first ("C")`,
+		`env "PATH": only in second ("/bin")`,
+		`stderr: "" != "inherit"`,
+		`preopen "/data": only in first ("` + dir + `")`,
+	}, DiffWasiConfigs(a, b))
+
+	// the description survives the config being consumed by a store
+	NewStore(NewEngine()).SetWasiConfig(a)
+	require.Equal(t, desc, a.Describe())
+}
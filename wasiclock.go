@@ -0,0 +1,123 @@
+package wasmtime
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// WASI clock identifiers which are affected by `WasiConfig.SetClockScale`.
+const (
+	wasiClockRealtime  = 0
+	wasiClockMonotonic = 1
+)
+
+// WASI functions wrapped for `WasiLinkOptions.ClockScale`.
+var wasiClockFuncs = map[string]bool{"clock_time_get": true, "poll_oneoff": true}
+
+// Layout of a clock `subscription` passed to `poll_oneoff` in each WASI
+// module, as byte offsets within the subscription.
+type wasiSubscriptionLayout struct {
+	size, clockID, timeout, flags int
+}
+
+var wasiSubscriptionLayouts = map[string]wasiSubscriptionLayout{
+	"wasi_snapshot_preview1": {size: 48, clockID: 16, timeout: 24, flags: 40},
+	"wasi_unstable":          {size: 56, clockID: 24, timeout: 32, flags: 48},
+}
+
+// Wraps `clock_time_get` to apply the clock scale configured for the calling
+// store.
+func (shims *wasiShims) timeGet(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	results, trap := shims.call(caller, module, "clock_time_get", args)
+	data := getDataInStore(caller)
+	id := args[0].I32()
+	if trap != nil || results[0].I32() != 0 || data.wasiClockScale == 0 ||
+		(id != wasiClockRealtime && id != wasiClockMonotonic) {
+		return results, trap
+	}
+	mem := wasiMemory(caller)
+	ptr := uint64(uint32(args[2].I32()))
+	if ptr+8 > uint64(len(mem)) {
+		return results, nil
+	}
+	now := binary.LittleEndian.Uint64(mem[ptr:])
+	origin, ok := data.wasiClockOrigins[id]
+	if !ok {
+		data.wasiClockOrigins[id] = now
+		return results, nil
+	}
+	if now > origin {
+		now = origin + scaleDuration(now-origin, data.wasiClockScale)
+	}
+	binary.LittleEndian.PutUint64(mem[ptr:], now)
+	return results, nil
+}
+
+// Wraps `poll_oneoff` to apply the clock scale configured for the calling store
+// to the timeouts of clock subscriptions.
+func (shims *wasiShims) pollOneoff(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	data := getDataInStore(caller)
+	if data.wasiClockScale == 0 {
+		return shims.call(caller, module, "poll_oneoff", args)
+	}
+
+	// Rewrite the timeouts of clock subscriptions in guest memory from guest
+	// time to host time for the duration of the call.
+	type rewrite struct {
+		offset   uint64
+		old, new uint64
+	}
+	var rewrites []rewrite
+	layout := wasiSubscriptionLayouts[module]
+	mem := wasiMemory(caller)
+	in := uint64(uint32(args[0].I32()))
+	n := uint64(uint32(args[2].I32()))
+	if in+n*uint64(layout.size) <= uint64(len(mem)) {
+		for i := uint64(0); i < n; i++ {
+			sub := mem[in+i*uint64(layout.size):]
+			if sub[8] != 0 {
+				continue // not a clock subscription
+			}
+			id := int32(binary.LittleEndian.Uint32(sub[layout.clockID:]))
+			if id != wasiClockRealtime && id != wasiClockMonotonic {
+				continue
+			}
+			offset := in + i*uint64(layout.size) + uint64(layout.timeout)
+			timeout := binary.LittleEndian.Uint64(mem[offset:])
+			hostTimeout := scaleDuration(timeout, 1/data.wasiClockScale)
+			if binary.LittleEndian.Uint16(sub[layout.flags:])&1 != 0 {
+				// An absolute deadline is only in guest time if the guest has
+				// observed scaled time from this clock.
+				origin, ok := data.wasiClockOrigins[id]
+				if !ok || timeout <= origin {
+					continue
+				}
+				hostTimeout = origin + scaleDuration(timeout-origin, 1/data.wasiClockScale)
+			}
+			binary.LittleEndian.PutUint64(mem[offset:], hostTimeout)
+			rewrites = append(rewrites, rewrite{offset, timeout, hostTimeout})
+		}
+	}
+
+	results, trap := shims.call(caller, module, "poll_oneoff", args)
+
+	// Restore what the guest originally wrote, unless the call has since
+	// overwritten it with events.
+	mem = wasiMemory(caller)
+	for _, r := range rewrites {
+		if r.offset+8 <= uint64(len(mem)) && binary.LittleEndian.Uint64(mem[r.offset:]) == r.new {
+			binary.LittleEndian.PutUint64(mem[r.offset:], r.old)
+		}
+	}
+	return results, trap
+}
+
+// Scales the duration `d`, in nanoseconds, by `factor`, saturating on
+// overflow.
+func scaleDuration(d uint64, factor float64) uint64 {
+	scaled := float64(d) * factor
+	if scaled >= math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return uint64(scaled)
+}
@@ -0,0 +1,76 @@
+package wasmtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasiClockScale(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "wasi_snapshot_preview1" "clock_time_get" (func $time_get (param i32 i64 i32) (result i32)))
+	  (import "wasi_snapshot_preview1" "poll_oneoff" (func $poll (param i32 i32 i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  ;; sleeps for the given number of nanoseconds, returning how long the
+	  ;; monotonic clock says the sleep took
+	  (func (export "sleep") (param $ns i64) (result i64)
+	    (drop (call $time_get (i32.const 1) (i64.const 0) (i32.const 0)))
+	    (i32.store8 (i32.const 24) (i32.const 0))
+	    (i32.store (i32.const 32) (i32.const 1))
+	    (i64.store (i32.const 40) (local.get $ns))
+	    (i64.store (i32.const 48) (i64.const 0))
+	    (i32.store16 (i32.const 56) (i32.const 0))
+	    (drop (call $poll (i32.const 16) (i32.const 128) (i32.const 1) (i32.const 8)))
+	    (drop (call $time_get (i32.const 1) (i64.const 0) (i32.const 8)))
+	    (i64.sub (i64.load (i32.const 8)) (i64.load (i32.const 0))))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineWasiWithOptions(WasiLinkOptions{ClockScale: true}))
+
+	run := func(scale float64, sleep time.Duration) (guest, host time.Duration) {
+		config := NewWasiConfig()
+		require.NoError(t, config.SetClockScale(scale))
+		store := NewStore(engine)
+		store.SetWasiConfig(config)
+		instance, err := linker.Instantiate(store, module)
+		require.NoError(t, err)
+		start := time.Now()
+		result, err := instance.GetFunc(store, "sleep").Call(store, int64(sleep))
+		require.NoError(t, err)
+		return time.Duration(result.(int64)), time.Since(start)
+	}
+
+	guest, host := run(100, time.Second)
+	require.GreaterOrEqual(t, guest, time.Second)
+	require.Less(t, host, 500*time.Millisecond)
+
+	guest, host = run(1, 10*time.Millisecond)
+	require.GreaterOrEqual(t, guest, 10*time.Millisecond)
+	require.GreaterOrEqual(t, host, 10*time.Millisecond)
+
+	config := NewWasiConfig()
+	require.Error(t, config.SetClockScale(0))
+	require.Error(t, config.SetClockScale(-1))
+	require.Equal(t, float64(1), config.Describe().ClockScale)
+
+	// wasmtime's own definitions would silently ignore the scale
+	linker = NewLinker(engine)
+	require.NoError(t, linker.DefineWasi())
+	store := NewStore(engine)
+	defer store.Close()
+	require.NoError(t, config.SetClockScale(2))
+	store.SetWasiConfig(config)
+	_, err = linker.Instantiate(store, module)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "WasiLinkOptions.ClockScale")
+	store.SetWasiConfig(NewWasiConfig())
+	_, err = linker.Instantiate(store, module)
+	require.NoError(t, err)
+}
@@ -0,0 +1,73 @@
+package wasmtime
+
+import (
+	"time"
+)
+
+// LatencyHistogram is a histogram of how long calls to a function took, see
+// `Store.WasiLatencies`.
+type LatencyHistogram struct {
+	// The number of calls, and the total and maximum time they took.
+	Count uint64
+	Total time.Duration
+	Max   time.Duration
+	// Counts of calls by duration. The first bucket counts calls which took
+	// less than 1µs, the following buckets count calls which took up to ten
+	// times longer than the previous bucket, so 1µs-10µs, 10µs-100µs and so
+	// on, and the last bucket counts calls which took 1s or longer.
+	Buckets [8]uint64
+}
+
+// Add records a call which took `d`.
+func (h *LatencyHistogram) Add(d time.Duration) {
+	h.Count++
+	h.Total += d
+	if d > h.Max {
+		h.Max = d
+	}
+	bucket, bound := 0, time.Microsecond
+	for bucket < len(h.Buckets)-1 && d >= bound {
+		bucket++
+		bound *= 10
+	}
+	h.Buckets[bucket]++
+}
+
+// Mean returns the average time calls took.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(h.Count)
+}
+
+// WasiLatencies returns a latency histogram for each WASI function, such as
+// `fd_read` or `poll_oneoff`, which guests in this store have called, keyed by
+// function name. This helps diagnose whether slowness is in the guest itself
+// or in the I/O performed on its behalf by the host.
+//
+// Latencies are only recorded for guests linked with
+// `WasiLinkOptions.RecordLatencies`, for all WASI functions except
+// `proc_exit`, and include the time taken by any functionality this package
+// adds to them, such as clock scaling. They're accumulated from when the store
+// was created.
+func (store *Store) WasiLatencies() map[string]LatencyHistogram {
+	data := getDataInStore(store)
+	ret := make(map[string]LatencyHistogram, len(data.wasiLatencies))
+	for name, h := range data.wasiLatencies {
+		ret[name] = *h
+	}
+	return ret
+}
+
+func (data *storeData) recordWasiLatency(name string, d time.Duration) {
+	h, ok := data.wasiLatencies[name]
+	if !ok {
+		if data.wasiLatencies == nil {
+			data.wasiLatencies = make(map[string]*LatencyHistogram)
+		}
+		h = &LatencyHistogram{}
+		data.wasiLatencies[name] = h
+	}
+	h.Add(d)
+}
@@ -0,0 +1,49 @@
+package wasmtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasiLatencies(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+	  (import "wasi_snapshot_preview1" "sched_yield" (func $sched_yield (result i32)))
+	  (memory (export "memory") 1)
+	  (func (export "run") (result i32)
+	    (drop (call $sched_yield))
+	    (drop (call $sched_yield))
+	    (call $fd_write (i32.const 1) (i32.const 0) (i32.const 0) (i32.const 8)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineWasiWithOptions(WasiLinkOptions{RecordLatencies: true}))
+	store := NewStore(engine)
+	store.SetWasiConfig(NewWasiConfig())
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	require.Empty(t, store.WasiLatencies())
+
+	result, err := instance.GetFunc(store, "run").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), result)
+	latencies := store.WasiLatencies()
+	require.Len(t, latencies, 2)
+	require.Equal(t, uint64(2), latencies["sched_yield"].Count)
+	require.Equal(t, uint64(1), latencies["fd_write"].Count)
+
+	var h LatencyHistogram
+	h.Add(500 * time.Nanosecond)
+	h.Add(50 * time.Microsecond)
+	h.Add(time.Minute)
+	require.Equal(t, [8]uint64{1, 0, 1, 0, 0, 0, 0, 1}, h.Buckets)
+	require.Equal(t, time.Minute, h.Max)
+	require.Equal(t, h.Total/3, h.Mean())
+}
@@ -0,0 +1,356 @@
+package wasmtime
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+)
+
+// ScratchLimits limits the contents of a scratch directory created with
+// `WasiConfig.PreopenScratchDir`. A limit of zero means it isn't limited.
+type ScratchLimits struct {
+	// The maximum number of files and directories in the scratch directory,
+	// including those in its subdirectories.
+	MaxFiles int
+	// The maximum total size, in bytes, of the files in the scratch directory.
+	MaxSize int64
+	// Whether the scratch directory is kept when the store is done with it,
+	// for example so the host can collect files written by the guest. By
+	// default it's removed along with its contents.
+	Keep bool
+}
+
+// A scratch directory preopened by `WasiConfig.PreopenScratchDir`.
+type wasiScratch struct {
+	dir    string
+	fd     uint32
+	limits ScratchLimits
+}
+
+// The state of a scratch directory in a store: the host path of each file
+// descriptor the guest has opened within it, including the preopen itself.
+type wasiScratchState struct {
+	*wasiScratch
+	paths map[uint32]string
+	// The number of files and directories in the scratch directory and their
+	// total size, counted on first use and then kept up to date by the
+	// wrappers. Removals aren't wrapped, so these may be too high, and are
+	// recounted before they're used to deny the guest.
+	counted bool
+	files   int
+	size    int64
+}
+
+// WASI functions wrapped for `WasiLinkOptions.ScratchLimits`.
+var wasiScratchFuncs = map[string]bool{
+	"path_open": true, "path_create_directory": true, "fd_write": true,
+	"fd_pwrite": true, "fd_allocate": true, "fd_filestat_set_size": true,
+	"fd_close": true, "fd_renumber": true, "path_link": true, "path_symlink": true,
+}
+
+// WASI errno and flags used by the wrappers in this file.
+const (
+	wasiErrnoDquot  = 19
+	wasiOflagsCreat = 1
+)
+
+// PreopenScratchDir preopens a new, empty, temporary directory at `guestPath`
+// for the guest to use as scratch space, and returns its path on the host.
+//
+// The guest is denied from creating files, or writing to them, with
+// `ERRNO_DQUOT` when that would exceed `limits`. Sizes are checked before each
+// write assuming that all of it extends the file, so writes near the limit may
+// be denied even if they overwrite existing data. Hard links into or out of a
+// limited scratch directory are denied with `ERRNO_NOTCAPABLE`, since writes
+// through the other name couldn't be accounted for.
+//
+// Limits are enforced by the wrappers of `WasiLinkOptions.ScratchLimits`, so
+// instantiating a guest which imports the WASI functions involved with a
+// linker which doesn't define them returns an error.
+//
+// Unless `limits.Keep` is set the directory is removed when the store using
+// this configuration is closed or garbage collected, or when the
+// configuration is replaced by another `Store.SetWasiConfig` or
+// `Store.Reset`, so that scratch files can't accumulate across requests.
+func (c *WasiConfig) PreopenScratchDir(guestPath string, limits ScratchLimits) (string, error) {
+	dir, err := os.MkdirTemp("", "wasi-scratch-")
+	if err != nil {
+		return "", err
+	}
+	if err := c.PreopenDir(dir, guestPath); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	// Preopened directories are numbered after stdio in the order they were
+	// added.
+	c.scratch = append(c.scratch, &wasiScratch{dir: dir, fd: uint32(2 + c.preopens), limits: limits})
+	return dir, nil
+}
+
+// Returns whether the contents of the scratch directory are limited.
+func (s *wasiScratch) limited() bool {
+	return s.limits.MaxFiles > 0 || s.limits.MaxSize > 0
+}
+
+// Removes a scratch directory which is no longer used, unless it's meant to
+// be kept.
+func (s *wasiScratch) remove() {
+	if !s.limits.Keep {
+		os.RemoveAll(s.dir)
+	}
+}
+
+// Installs the scratch directories of the configuration passed to
+// `Store.SetWasiConfig`, removing those of the previous configuration.
+func (data *storeData) setWasiScratch(scratch []*wasiScratch) {
+	for _, s := range data.wasiScratch {
+		s.remove()
+	}
+	data.wasiScratch = nil
+	for _, s := range scratch {
+		state := &wasiScratchState{wasiScratch: s, paths: map[uint32]string{s.fd: s.dir}}
+		data.wasiScratch = append(data.wasiScratch, state)
+	}
+}
+
+// Returns the scratch directory that `fd` was opened within, along with its
+// host path, or nil if it isn't in one.
+func (data *storeData) scratchFD(fd uint32) (*wasiScratchState, string) {
+	for _, s := range data.wasiScratch {
+		if path, ok := s.paths[fd]; ok {
+			return s, path
+		}
+	}
+	return nil, ""
+}
+
+// Counts the files and directories in the scratch directory and their total
+// size by walking it. Returns false if it can't be walked, in which case the
+// previous counts are kept.
+func (s *wasiScratchState) recount() bool {
+	files, size := 0, int64(0)
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == s.dir {
+			return nil
+		}
+		files++
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+	s.counted, s.files, s.size = true, files, size
+	return true
+}
+
+// Returns whether one more file may be created in the scratch directory.
+func (s *wasiScratchState) allowCreate() bool {
+	if s.limits.MaxFiles <= 0 {
+		return true
+	}
+	if !s.counted || s.files >= s.limits.MaxFiles {
+		if !s.recount() {
+			return false
+		}
+	}
+	return s.files < s.limits.MaxFiles
+}
+
+// Returns whether the files in the scratch directory may grow by `growth`
+// bytes.
+func (s *wasiScratchState) allowGrowth(growth int64) bool {
+	if s.limits.MaxSize <= 0 || growth <= 0 {
+		return true
+	}
+	if !s.counted || s.size+growth > s.limits.MaxSize {
+		if !s.recount() {
+			return false
+		}
+	}
+	return s.size+growth <= s.limits.MaxSize
+}
+
+// Records that a file or directory was created in the scratch directory.
+func (s *wasiScratchState) created() {
+	if s.counted {
+		s.files++
+	}
+}
+
+// Invokes the original WASI function `name`, which may change the size of the
+// file at `path`, and records by how much it did.
+func (s *wasiScratchState) resize(shims *wasiShims, caller *Caller, module, name, path string, args []Val) ([]Val, *Trap) {
+	if s.limits.MaxSize <= 0 {
+		return shims.call(caller, module, name, args)
+	}
+	before := fileSize(path)
+	results, trap := shims.call(caller, module, name, args)
+	if s.counted {
+		s.size += fileSize(path) - before
+	}
+	return results, trap
+}
+
+var wasiDquot = []Val{ValI32(wasiErrnoDquot)}
+
+// Wraps `path_open` to count files created in scratch directories and to
+// learn which file descriptors refer to them.
+func (shims *wasiShims) pathOpen(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	s, base := getDataInStore(caller).scratchFD(uint32(args[0].I32()))
+	if s == nil {
+		return shims.call(caller, module, "path_open", args)
+	}
+	name, trap := guestRegion(caller, args[2].I32(), args[3].I32())
+	if trap != nil {
+		return shims.call(caller, module, "path_open", args)
+	}
+	path := filepath.Join(base, string(name))
+	create := false
+	if args[4].I32()&wasiOflagsCreat != 0 {
+		if _, err := os.Lstat(path); err != nil {
+			if !s.allowCreate() {
+				return wasiDquot, nil
+			}
+			create = true
+		}
+	}
+	results, trap := shims.call(caller, module, "path_open", args)
+	if trap == nil && results[0].I32() == 0 {
+		if create {
+			s.created()
+		}
+		if out, trap := guestRegion(caller, args[8].I32(), 4); trap == nil {
+			s.paths[binary.LittleEndian.Uint32(out)] = path
+		}
+	}
+	return results, trap
+}
+
+func (shims *wasiShims) pathCreateDirectory(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	s, _ := getDataInStore(caller).scratchFD(uint32(args[0].I32()))
+	if s == nil {
+		return shims.call(caller, module, "path_create_directory", args)
+	}
+	if !s.allowCreate() {
+		return wasiDquot, nil
+	}
+	results, trap := shims.call(caller, module, "path_create_directory", args)
+	if trap == nil && results[0].I32() == 0 {
+		s.created()
+	}
+	return results, trap
+}
+
+// Wraps `fd_write` and `fd_pwrite`, which both take a list of buffers as
+// their second and third arguments.
+func (shims *wasiShims) fdWrite(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	name := "fd_write"
+	if len(args) == 5 {
+		name = "fd_pwrite"
+	}
+	s, path := getDataInStore(caller).scratchFD(uint32(args[0].I32()))
+	if s == nil {
+		return shims.call(caller, module, name, args)
+	}
+	growth := int64(0)
+	if iovs, trap := guestRegion(caller, args[1].I32(), args[2].I32()*8); trap == nil {
+		for i := 0; i+8 <= len(iovs); i += 8 {
+			growth += int64(binary.LittleEndian.Uint32(iovs[i+4:]))
+		}
+	}
+	if !s.allowGrowth(growth) {
+		return wasiDquot, nil
+	}
+	return s.resize(shims, caller, module, name, path, args)
+}
+
+func (shims *wasiShims) fdAllocate(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	s, path := getDataInStore(caller).scratchFD(uint32(args[0].I32()))
+	if s == nil {
+		return shims.call(caller, module, "fd_allocate", args)
+	}
+	if !s.allowGrowth(args[1].I64() + args[2].I64() - fileSize(path)) {
+		return wasiDquot, nil
+	}
+	return s.resize(shims, caller, module, "fd_allocate", path, args)
+}
+
+func (shims *wasiShims) fdSetSize(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	s, path := getDataInStore(caller).scratchFD(uint32(args[0].I32()))
+	if s == nil {
+		return shims.call(caller, module, "fd_filestat_set_size", args)
+	}
+	if !s.allowGrowth(args[1].I64() - fileSize(path)) {
+		return wasiDquot, nil
+	}
+	return s.resize(shims, caller, module, "fd_filestat_set_size", path, args)
+}
+
+// Wraps `path_symlink` to count the links created in scratch directories.
+func (shims *wasiShims) pathSymlink(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	s, _ := getDataInStore(caller).scratchFD(uint32(args[2].I32()))
+	if s == nil {
+		return shims.call(caller, module, "path_symlink", args)
+	}
+	if !s.allowCreate() {
+		return wasiDquot, nil
+	}
+	results, trap := shims.call(caller, module, "path_symlink", args)
+	if trap == nil && results[0].I32() == 0 {
+		s.created()
+	}
+	return results, trap
+}
+
+// Wraps `path_link` to deny hard links into or out of limited scratch
+// directories.
+func (shims *wasiShims) pathLink(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	data := getDataInStore(caller)
+	for _, fd := range []Val{args[0], args[4]} {
+		if s, _ := data.scratchFD(uint32(fd.I32())); s != nil && s.limited() {
+			return []Val{ValI32(wasiErrnoNotCapable)}, nil
+		}
+	}
+	return shims.call(caller, module, "path_link", args)
+}
+
+func (shims *wasiShims) fdClose(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	results, trap := shims.call(caller, module, "fd_close", args)
+	if trap == nil && results[0].I32() == 0 {
+		for _, s := range getDataInStore(caller).wasiScratch {
+			delete(s.paths, uint32(args[0].I32()))
+		}
+	}
+	return results, trap
+}
+
+func (shims *wasiShims) fdRenumber(caller *Caller, module string, args []Val) ([]Val, *Trap) {
+	results, trap := shims.call(caller, module, "fd_renumber", args)
+	if trap == nil && results[0].I32() == 0 {
+		from, to := uint32(args[0].I32()), uint32(args[1].I32())
+		for _, s := range getDataInStore(caller).wasiScratch {
+			if path, ok := s.paths[from]; ok {
+				s.paths[to] = path
+				delete(s.paths, from)
+			} else {
+				delete(s.paths, to)
+			}
+		}
+	}
+	return results, trap
+}
+
+// Returns the size of the file at `path`, or 0 if it can't be determined.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
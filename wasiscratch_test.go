@@ -0,0 +1,120 @@
+package wasmtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasiScratchDir(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	(module
+	  (import "wasi_snapshot_preview1" "path_open" (func $path_open
+	    (param i32 i32 i32 i32 i32 i64 i64 i32 i32) (result i32)))
+	  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+	  (import "wasi_snapshot_preview1" "path_link" (func $path_link
+	    (param i32 i32 i32 i32 i32 i32 i32) (result i32)))
+	  (import "wasi_snapshot_preview1" "path_symlink" (func $path_symlink (param i32 i32 i32 i32 i32) (result i32)))
+	  (memory (export "memory") 1)
+	  (data (i32.const 100) "a.txt")
+	  (data (i32.const 200) "b.txt")
+	  (data (i32.const 300) "c.txt")
+	  ;; creates the file whose name is at the given offset, storing its fd at 0
+	  (func (export "create") (param $name i32) (result i32)
+	    (call $path_open (i32.const 3) (i32.const 0) (local.get $name) (i32.const 5)
+	      (i32.const 1) (i64.const 0x1fffffff) (i64.const 0x1fffffff) (i32.const 0) (i32.const 0)))
+	  (func (export "fd") (result i32) (i32.load (i32.const 0)))
+	  ;; links the file named at the given offset to the one at another
+	  (func (export "link") (param $from i32) (param $to i32) (result i32)
+	    (call $path_link (i32.const 3) (i32.const 0) (local.get $from) (i32.const 5)
+	      (i32.const 3) (local.get $to) (i32.const 5)))
+	  (func (export "symlink") (param $from i32) (param $to i32) (result i32)
+	    (call $path_symlink (local.get $from) (i32.const 5) (i32.const 3) (local.get $to) (i32.const 5)))
+	  (func (export "write") (param $fd i32) (param $len i32) (result i32)
+	    (i32.store (i32.const 16) (i32.const 1024))
+	    (i32.store (i32.const 20) (local.get $len))
+	    (call $fd_write (local.get $fd) (i32.const 16) (i32.const 1) (i32.const 24)))
+	)
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineWasiWithOptions(WasiLinkOptions{ScratchLimits: true}))
+
+	config := NewWasiConfig()
+	dir, err := config.PreopenScratchDir("/scratch", ScratchLimits{MaxFiles: 2, MaxSize: 10})
+	require.NoError(t, err)
+	store := NewStore(engine)
+	store.SetWasiConfig(config)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	call := func(name string, args ...interface{}) int32 {
+		result, err := instance.GetFunc(store, name).Call(store, args...)
+		require.NoError(t, err)
+		return result.(int32)
+	}
+
+	require.Equal(t, int32(0), call("create", 100))
+	fd := call("fd")
+	require.Equal(t, int32(0), call("write", fd, 8))
+	require.Equal(t, int32(wasiErrnoDquot), call("write", fd, 8))
+	require.Equal(t, int32(0), call("write", 1, 0))
+
+	require.Equal(t, int32(0), call("create", 200))
+	require.Equal(t, int32(wasiErrnoDquot), call("create", 300))
+	// opening an existing file doesn't count as creating one
+	require.Equal(t, int32(0), call("create", 100))
+	// removals aren't tracked, but are noticed before a creation is denied
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.txt")))
+	require.Equal(t, int32(0), call("create", 300))
+	require.Equal(t, int32(wasiErrnoDquot), call("create", 200))
+	// hard links are denied, and symbolic links count as files
+	require.Equal(t, int32(wasiErrnoNotCapable), call("link", 100, 200))
+	require.Equal(t, int32(wasiErrnoDquot), call("symlink", 100, 200))
+	require.NoError(t, os.Remove(filepath.Join(dir, "c.txt")))
+	require.Equal(t, int32(0), call("symlink", 100, 200))
+	require.Equal(t, int32(wasiErrnoDquot), call("create", 300))
+
+	info, err := os.Stat(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, int64(8), info.Size())
+
+	store.Reset()
+	_, err = os.Stat(dir)
+	require.True(t, os.IsNotExist(err))
+
+	config = NewWasiConfig()
+	dir, err = config.PreopenScratchDir("/scratch", ScratchLimits{Keep: true})
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store.SetWasiConfig(config)
+	store.Close()
+	_, err = os.Stat(dir)
+	require.NoError(t, err)
+
+	// limits can't be enforced by wasmtime's own definitions
+	linker = NewLinker(engine)
+	require.NoError(t, linker.DefineWasi())
+	config = NewWasiConfig()
+	_, err = config.PreopenScratchDir("/scratch", ScratchLimits{MaxFiles: 1})
+	require.NoError(t, err)
+	store = NewStore(engine)
+	defer store.Close()
+	store.SetWasiConfig(config)
+	_, err = linker.Instantiate(store, module)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "WasiLinkOptions.ScratchLimits")
+	_, err = linker.InstantiateDeferred(store, module)
+	require.Error(t, err)
+
+	config = NewWasiConfig()
+	_, err = config.PreopenScratchDir("/scratch", ScratchLimits{})
+	require.NoError(t, err)
+	store.SetWasiConfig(config)
+	_, err = linker.Instantiate(store, module)
+	require.NoError(t, err)
+}
@@ -0,0 +1,273 @@
+package wasmtime
+
+// #include <wasmtime.h>
+import "C"
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Wrappers of WASI functions which implement functionality that wasmtime's
+// WASI doesn't provide, such as `WasiConfig.SetClockScale` and
+// `WasiConfig.PreopenScratchDir`, see `WasiLinkOptions`. Other wrapped WASI
+// functions forward to the original directly, so that `Store.WasiLatencies`
+// can time them.
+var wasiShimWrappers = map[string]func(shims *wasiShims, caller *Caller, module string, args []Val) ([]Val, *Trap){
+	"clock_time_get":        (*wasiShims).timeGet,
+	"poll_oneoff":           (*wasiShims).pollOneoff,
+	"path_open":             (*wasiShims).pathOpen,
+	"path_create_directory": (*wasiShims).pathCreateDirectory,
+	"fd_write":              (*wasiShims).fdWrite,
+	"fd_pwrite":             (*wasiShims).fdWrite,
+	"fd_allocate":           (*wasiShims).fdAllocate,
+	"fd_filestat_set_size":  (*wasiShims).fdSetSize,
+	"fd_close":              (*wasiShims).fdClose,
+	"fd_renumber":           (*wasiShims).fdRenumber,
+	"path_link":             (*wasiShims).pathLink,
+	"path_symlink":          (*wasiShims).pathSymlink,
+}
+
+// Selects every WASI function, for `wasiShims.define`.
+func wasiWrapAll(string) bool { return true }
+
+// The original WASI functions which the wrappers defined by
+// `Linker.defineWasiShims` delegate to.
+type wasiShims struct {
+	original *Linker
+	modules  map[string]*wasiShimModule
+}
+
+// The wrapped functions of one WASI module, and the trampoline module used to
+// call their originals.
+type wasiShimModule struct {
+	names []string
+	types []*FuncType
+	index map[string]int
+
+	once       sync.Once
+	trampoline *Module
+	err        error
+}
+
+// Key of a trampoline instance within a store, see `wasiShims.call`.
+type wasiTrampolineKey struct {
+	module string
+	memory C.wasmtime_memory_t
+}
+
+// Learns the WASI functions which wrappers may be defined for, along with
+// their types, and defines their originals in a private linker.
+func newWasiShims(engine *Engine) (*wasiShims, error) {
+	shims := &wasiShims{original: NewLinker(engine), modules: make(map[string]*wasiShimModule)}
+	if err := C.wasmtime_linker_define_wasi(shims.original.ptr()); err != nil {
+		return nil, mkError(err)
+	}
+
+	// The types of the original functions can only be learned through a store.
+	store := NewStore(engine)
+	defer store.Close()
+	for module, names := range wasiFuncs {
+		m := &wasiShimModule{index: make(map[string]int)}
+		for _, name := range names {
+			original := shims.original.Get(store, module, name)
+			if original == nil {
+				continue
+			}
+			m.index[name] = len(m.names)
+			m.names = append(m.names, name)
+			m.types = append(m.types, original.Func().Type(store))
+		}
+		shims.modules[module] = m
+	}
+	return shims, nil
+}
+
+// Defines wrappers of the functions of the WASI module `module` for which
+// `wrap` returns true in `l`, under the module name `as`, which record their
+// latencies if `record` is set.
+func (shims *wasiShims) define(l *Linker, as, module string, wrap func(name string) bool, record bool) error {
+	m := shims.modules[module]
+	for i, name := range m.names {
+		if !wrap(name) {
+			continue
+		}
+		name := name
+		shim := wasiShimWrappers[name]
+		if shim == nil {
+			shim = func(shims *wasiShims, caller *Caller, module string, args []Val) ([]Val, *Trap) {
+				return shims.call(caller, module, name, args)
+			}
+		}
+		callback := func(caller *Caller, args []Val) ([]Val, *Trap) {
+			return shim(shims, caller, module, args)
+		}
+		if record {
+			callback = func(caller *Caller, args []Val) ([]Val, *Trap) {
+				start := time.Now()
+				results, trap := shim(shims, caller, module, args)
+				getDataInStore(caller).recordWasiLatency(name, time.Since(start))
+				return results, trap
+			}
+		}
+		if err := l.FuncNew(as, name, m.types[i], callback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Errno returned by WASI functions denied by `WasiLinkOptions`.
+const wasiErrnoNotCapable = 76
+
+// Replaces the wrappers of the functions of the WASI module `module` defined
+// under `as` for which `denied` returns true with functions which fail
+// without doing anything.
+func (shims *wasiShims) deny(l *Linker, as, module string, denied func(name string) bool) error {
+	m := shims.modules[module]
+	for i, name := range m.names {
+		if !denied(name) {
+			continue
+		}
+		name, results := name, []Val{ValI32(wasiErrnoNotCapable)}
+		if len(m.types[i].Results()) == 0 {
+			results = nil
+		}
+		err := l.FuncNew(as, name, m.types[i], func(*Caller, []Val) ([]Val, *Trap) {
+			if results == nil {
+				return nil, NewTrap(fmt.Sprintf("WASI function `%s` is denied", name))
+			}
+			return results, nil
+		})
+		if err != nil {
+			return err
+		}
+		l.recordDefined(as, name, "DefineWasiWithOptions")
+	}
+	return nil
+}
+
+// Returns a module which forwards calls to the original versions of the
+// functions in `m`, imported from "wasi", while re-exporting the memory of the
+// guest calling them, since WASI functions read their arguments from the
+// memory exported by their caller.
+func (m *wasiShimModule) trampolineWat() string {
+	var imports, exports strings.Builder
+	for i, ty := range m.types {
+		var sig strings.Builder
+		args := make([]string, len(ty.Params()))
+		for j, param := range ty.Params() {
+			fmt.Fprintf(&sig, " (param %s)", param.Kind())
+			args[j] = fmt.Sprintf(" (local.get %d)", j)
+		}
+		for _, result := range ty.Results() {
+			fmt.Fprintf(&sig, " (result %s)", result.Kind())
+		}
+		fmt.Fprintf(&imports, "  (import \"wasi\" %q (func $f%d%s))\n", m.names[i], i, sig.String())
+		fmt.Fprintf(&exports, "  (func (export %q)%s (call $f%d%s))\n", m.names[i], sig.String(), i, strings.Join(args, ""))
+	}
+	return "(module\n" + imports.String() +
+		"  (import \"env\" \"memory\" (memory 0))\n  (export \"memory\" (memory 0))\n" +
+		exports.String() + ")\n"
+}
+
+// Invokes the original WASI function `module`/`name` on behalf of `caller`.
+//
+// This goes through an instance of `wasiShimModule.trampolineWat`, created
+// once per store and guest memory, so the original function sees the guest's
+// memory.
+func (shims *wasiShims) call(caller *Caller, module, name string, args []Val) ([]Val, *Trap) {
+	memory := caller.GetExport("memory")
+	if memory == nil || memory.Memory() == nil {
+		return nil, NewTrap("missing required memory export")
+	}
+	m := shims.modules[module]
+	data := getDataInStore(caller)
+	key := wasiTrampolineKey{module, memory.Memory().val}
+	instance, ok := data.wasiTrampolines[key]
+	if !ok {
+		m.once.Do(func() {
+			var wasm []byte
+			if wasm, m.err = Wat2Wasm(m.trampolineWat()); m.err == nil {
+				m.trampoline, m.err = NewModule(shims.original.Engine, wasm)
+			}
+		})
+		if m.err != nil {
+			return nil, errorToTrap(m.err)
+		}
+		imports := make([]C.wasmtime_extern_t, 0, len(m.names)+1)
+		for _, name := range m.names {
+			imports = append(imports, shims.original.Get(caller, module, name).AsExtern())
+		}
+		imports = append(imports, memory.AsExtern())
+		// Trampolines are an implementation detail, so they're allowed on
+		// top of the instances permitted by `Store.Limiter`.
+		limited := data.appliedLimits.instances >= 0
+		data.trampolineInstances++
+		if limited {
+			data.applyLimits(data.appliedLimits)
+		}
+		var trap *C.wasm_trap_t
+		err := C.wasmtime_instance_new(caller.Context(), m.trampoline.ptr(), &imports[0], C.size_t(len(imports)), &instance, &trap)
+		runtime.KeepAlive(shims)
+		if trap != nil || err != nil {
+			data.trampolineInstances--
+			if limited {
+				data.applyLimits(data.appliedLimits)
+			}
+		}
+		if trap != nil {
+			return nil, mkTrap(trap)
+		}
+		if err != nil {
+			return nil, errorToTrap(mkError(err))
+		}
+		if data.wasiTrampolines == nil {
+			data.wasiTrampolines = make(map[wasiTrampolineKey]C.wasmtime_instance_t)
+		}
+		data.wasiTrampolines[key] = instance
+	}
+
+	f := mkInstance(instance).GetFunc(caller, name)
+	return caller.callFunc(f, args, len(m.types[m.index[name]].Results()))
+}
+
+// Returns an error if `module` imports WASI functions which need to be wrapped
+// for the WASI configuration of `store`, but which `l` defines without
+// wrappers, so that the configuration isn't silently ignored.
+func (l *Linker) checkWasiShims(store Storelike, module *Module) error {
+	data := getDataInStore(store)
+	scratch := false
+	for _, s := range data.wasiScratch {
+		scratch = scratch || s.limited()
+	}
+	clock := data.wasiClockScale != 0
+	if !scratch && !clock {
+		return nil
+	}
+	for _, imp := range module.Imports() {
+		if imp.Name() == nil || l.defined[linkerName{imp.Module(), *imp.Name()}] != "DefineWasi" {
+			continue
+		}
+		name := *imp.Name()
+		if scratch && wasiScratchFuncs[name] {
+			return fmt.Errorf("WASI function `%s` must be defined with `WasiLinkOptions.ScratchLimits` to enforce the limits of `WasiConfig.PreopenScratchDir`", name)
+		}
+		if clock && wasiClockFuncs[name] {
+			return fmt.Errorf("WASI function `%s` must be defined with `WasiLinkOptions.ClockScale` to honour `WasiConfig.SetClockScale`", name)
+		}
+	}
+	return nil
+}
+
+// Returns the memory of the module calling a WASI function, which WASI
+// requires to be exported as `memory`.
+func wasiMemory(caller *Caller) []byte {
+	export := caller.GetExport("memory")
+	if export == nil || export.Memory() == nil {
+		return nil
+	}
+	return export.Memory().UnsafeData(caller)
+}
@@ -0,0 +1,93 @@
+package wasmtime
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// WasiConfigError is returned by `WasiConfig.Validate` and
+// `Store.SetWasiConfigStrict`, listing everything wrong with a configuration.
+type WasiConfigError struct {
+	Problems []string
+}
+
+func (e *WasiConfigError) Error() string {
+	return "invalid WASI configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks this configuration for mistakes which wasmtime would
+// otherwise accept silently, leaving the guest to fail in confusing ways once
+// it starts making WASI calls:
+//
+//   - preopened directories or stdio files whose host paths no longer exist,
+//   - more than one directory preopened at the same guest path,
+//   - stdio streams which were connected more than once, of which only the
+//     last takes effect,
+//   - stdin reading from the same host file stdout or stderr write to, which
+//     the guest would see its own output in (stdout and stderr may share a
+//     file, as with `2>&1`),
+//   - and environment variables which were set more than once.
+//
+// Returns nil if no problems were found, or a `*WasiConfigError` otherwise.
+func (c *WasiConfig) Validate() error {
+	problems := append([]string(nil), c.problems...)
+
+	mounted := make(map[string]bool, len(c.mounts))
+	for _, guestPath := range c.mounts {
+		if guestPath == "" {
+			problems = append(problems, "a directory is preopened with an empty guest path")
+			continue
+		}
+		clean := path.Clean(guestPath)
+		if mounted[clean] {
+			problems = append(problems, fmt.Sprintf("guest path %q is preopened more than once", clean))
+		}
+		mounted[clean] = true
+	}
+	for _, guestPath := range sortedKeys(c.desc.Preopens, nil) {
+		host := c.desc.Preopens[guestPath]
+		if info, err := os.Stat(host); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("directory %q preopened at %q doesn't exist", host, guestPath))
+		}
+	}
+
+	stdin := ""
+	for _, stream := range []struct{ name, desc string }{
+		{"stdin", c.desc.Stdin},
+		{"stdout", c.desc.Stdout},
+		{"stderr", c.desc.Stderr},
+	} {
+		// Pipes created for connections and writers are named by their
+		// descriptor, and stay valid after those descriptors are closed.
+		host := strings.TrimPrefix(stream.desc, "file:")
+		if host == stream.desc || strings.HasPrefix(host, "/dev/fd/") {
+			continue
+		}
+		if _, err := os.Stat(host); err != nil {
+			problems = append(problems, fmt.Sprintf("file %q for %s doesn't exist", host, stream.name))
+		}
+		if stream.name == "stdin" {
+			stdin = host
+		} else if host == stdin {
+			problems = append(problems, fmt.Sprintf("stdin and %s are both connected to %q", stream.name, host))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &WasiConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// SetWasiConfigStrict is like `SetWasiConfig`, but first checks `wasi` with
+// `WasiConfig.Validate`. If it has any problems the error is returned, and
+// neither the store nor `wasi` are modified.
+func (store *Store) SetWasiConfigStrict(wasi *WasiConfig) error {
+	if err := wasi.Validate(); err != nil {
+		return err
+	}
+	store.SetWasiConfig(wasi)
+	return nil
+}
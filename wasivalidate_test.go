@@ -0,0 +1,65 @@
+package wasmtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasiConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log.txt")
+	require.NoError(t, os.WriteFile(log, nil, 0644))
+	config := NewWasiConfig()
+	config.SetEnv([]string{"HOME"}, []string{"/home"})
+	config.InheritStdout()
+	config.InheritStdout()
+	require.NoError(t, config.PreopenDir(dir, "/data"))
+	require.NoError(t, config.Validate())
+
+	store := NewStore(NewEngine())
+	require.NoError(t, store.SetWasiConfigStrict(config))
+
+	// as with 2>&1
+	config = NewWasiConfig()
+	require.NoError(t, config.SetStdoutFile(log))
+	require.NoError(t, config.SetStderrFile(log))
+	require.NoError(t, config.Validate())
+}
+
+func TestWasiConfigValidateProblems(t *testing.T) {
+	dir := t.TempDir()
+	gone := filepath.Join(dir, "gone")
+	require.NoError(t, os.Mkdir(gone, 0755))
+	out := filepath.Join(dir, "out.txt")
+
+	config := NewWasiConfig()
+	config.SetEnv([]string{"A", "A"}, []string{"1", "2"})
+	require.NoError(t, config.PreopenDir(dir, "/data"))
+	require.NoError(t, config.PreopenDir(dir, "/data/"))
+	require.NoError(t, config.PreopenDir(gone, "/gone"))
+	require.NoError(t, os.Remove(gone))
+	config.InheritStdout()
+	require.NoError(t, config.SetStdoutFile(out))
+	require.NoError(t, config.SetStderrFile(out))
+	require.NoError(t, config.SetStdinFile(out))
+
+	err := config.Validate()
+	var invalid *WasiConfigError
+	require.ErrorAs(t, err, &invalid)
+	require.Equal(t, []string{
+		`environment variable "A" is set more than once`,
+		`stdout is connected to both "inherit" and "file:` + out + `"`,
+		`guest path "/data" is preopened more than once`,
+		`directory "` + gone + `" preopened at "/gone" doesn't exist`,
+		`stdin and stdout are both connected to "` + out + `"`,
+		`stdin and stderr are both connected to "` + out + `"`,
+	}, invalid.Problems)
+
+	// the config isn't consumed when it's rejected
+	store := NewStore(NewEngine())
+	require.Equal(t, err, store.SetWasiConfigStrict(config))
+	store.SetWasiConfig(config)
+}
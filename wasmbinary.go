@@ -0,0 +1,207 @@
+package wasmtime
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Helpers for inspecting the raw bytes of a WebAssembly binary for
+// information which the wasmtime C API does not expose.
+
+var wasmMagic = []byte{0x00, 'a', 's', 'm'}
+
+const (
+	wasmCustomSection = 0
+	wasmCodeSection   = 10
+	wasmHeaderSize    = 8
+)
+
+var errMalformedWasm = errors.New("malformed wasm binary")
+
+// Reads an unsigned LEB128-encoded integer from the start of `buf`, returning
+// the value and the number of bytes it occupied.
+func readULEB(buf []byte) (uint64, int, error) {
+	var ret uint64
+	var shift uint
+	for i, b := range buf {
+		if shift >= 64 {
+			break
+		}
+		ret |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return ret, i + 1, nil
+		}
+		shift += 7
+	}
+	return 0, 0, errMalformedWasm
+}
+
+// Reads a length-prefixed byte vector, such as a name, from the start of
+// `buf`, returning the vector and the remaining bytes.
+func readWasmVec(buf []byte) ([]byte, []byte, error) {
+	n, size, err := readULEB(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	buf = buf[size:]
+	if n > uint64(len(buf)) {
+		return nil, nil, errMalformedWasm
+	}
+	return buf[:n], buf[n:], nil
+}
+
+// Invokes `f` with the id and payload of every section in the binary `wasm`,
+// stopping early if `f` returns false.
+func eachWasmSection(wasm []byte, f func(id byte, payload []byte) bool) error {
+	if len(wasm) < wasmHeaderSize || !bytes.Equal(wasm[:4], wasmMagic) {
+		return errMalformedWasm
+	}
+	wasm = wasm[wasmHeaderSize:]
+	for len(wasm) > 0 {
+		id := wasm[0]
+		payload, rest, err := readWasmVec(wasm[1:])
+		if err != nil {
+			return err
+		}
+		if !f(id, payload) {
+			return nil
+		}
+		wasm = rest
+	}
+	return nil
+}
+
+// Returns the module name recorded in the `name` custom section of `wasm`,
+// or an empty string if there isn't one.
+func wasmModuleName(wasm []byte) string {
+	var ret string
+	_ = eachWasmSection(wasm, func(id byte, payload []byte) bool {
+		if id != wasmCustomSection {
+			return true
+		}
+		name, payload, err := readWasmVec(payload)
+		if err != nil || string(name) != "name" {
+			return true
+		}
+		// The module name, if present, is always the first subsection.
+		if len(payload) == 0 || payload[0] != 0 {
+			return false
+		}
+		sub, _, err := readWasmVec(payload[1:])
+		if err != nil {
+			return false
+		}
+		if name, _, err := readWasmVec(sub); err == nil {
+			ret = string(name)
+		}
+		return false
+	})
+	return ret
+}
+
+// Returns the offset in `wasm` of the contents of its code section, which is
+// what the addresses in its DWARF debug info are relative to, or -1 if it
+// doesn't have a code section.
+func wasmCodeOffset(wasm []byte) int {
+	ret := -1
+	_ = eachWasmSection(wasm, func(id byte, payload []byte) bool {
+		if id != wasmCodeSection {
+			return true
+		}
+		// `payload` is a subslice of `wasm`, so their capacities differ by
+		// the payload's offset.
+		ret = cap(wasm) - cap(payload)
+		return false
+	})
+	return ret
+}
+
+// Returns copies of the contents of the custom sections in `wasm`, keyed by
+// section name, in the order they appear. DWARF sections are left out unless
+// `debug` is set.
+func wasmCustomSections(wasm []byte, debug bool) map[string][][]byte {
+	var ret map[string][][]byte
+	_ = eachWasmSection(wasm, func(id byte, payload []byte) bool {
+		if id != wasmCustomSection {
+			return true
+		}
+		name, contents, err := readWasmVec(payload)
+		if err != nil || !debug && bytes.HasPrefix(name, []byte(".debug_")) {
+			return true
+		}
+		if ret == nil {
+			ret = make(map[string][][]byte)
+		}
+		ret[string(name)] = append(ret[string(name)], append([]byte(nil), contents...))
+		return true
+	})
+	return ret
+}
+
+// Returns the function names recorded in the contents of a `name` custom
+// section, keyed by function index.
+func wasmFunctionNames(section []byte) map[uint32]string {
+	ret := make(map[uint32]string)
+	for len(section) > 0 {
+		id := section[0]
+		sub, rest, err := readWasmVec(section[1:])
+		if err != nil {
+			break
+		}
+		section = rest
+		if id != 1 {
+			continue
+		}
+		count, size, err := readULEB(sub)
+		if err != nil {
+			break
+		}
+		sub = sub[size:]
+		for i := uint64(0); i < count; i++ {
+			idx, size, err := readULEB(sub)
+			if err != nil {
+				break
+			}
+			name, rest, err := readWasmVec(sub[size:])
+			if err != nil {
+				break
+			}
+			ret[uint32(idx)] = string(name)
+			sub = rest
+		}
+	}
+	return ret
+}
+
+// Returns the fields of the contents of a `producers` custom section, each
+// of which lists name and version pairs.
+func wasmProducers(section []byte) (map[string][]Producer, error) {
+	fields, size, err := readULEB(section)
+	if err != nil {
+		return nil, err
+	}
+	section = section[size:]
+	ret := make(map[string][]Producer, fields)
+	for i := uint64(0); i < fields; i++ {
+		var field []byte
+		if field, section, err = readWasmVec(section); err != nil {
+			return nil, err
+		}
+		count, size, err := readULEB(section)
+		if err != nil {
+			return nil, err
+		}
+		section = section[size:]
+		for j := uint64(0); j < count; j++ {
+			var name, version []byte
+			if name, section, err = readWasmVec(section); err != nil {
+				return nil, err
+			}
+			if version, section, err = readWasmVec(section); err != nil {
+				return nil, err
+			}
+			ret[string(field)] = append(ret[string(field)], Producer{Name: string(name), Version: string(version)})
+		}
+	}
+	return ret, nil
+}
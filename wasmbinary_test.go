@@ -0,0 +1,29 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadULEB(t *testing.T) {
+	val, size, err := readULEB([]byte{0xe5, 0x8e, 0x26, 0xff})
+	require.NoError(t, err)
+	require.Equal(t, uint64(624485), val)
+	require.Equal(t, 3, size)
+
+	_, _, err = readULEB([]byte{0x80, 0x80})
+	require.Error(t, err)
+}
+
+func TestWasmModuleName(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module $hello (func $f))`)
+	require.NoError(t, err)
+	require.Equal(t, "hello", wasmModuleName(wasm))
+
+	wasm, err = Wat2Wasm(`(module (func $f))`)
+	require.NoError(t, err)
+	require.Equal(t, "", wasmModuleName(wasm))
+
+	require.Equal(t, "", wasmModuleName([]byte("not wasm")))
+}
@@ -3,6 +3,8 @@ package wasmtime
 // #include <wasmtime.h>
 import "C"
 import (
+	"fmt"
+	"reflect"
 	"runtime"
 	"unsafe"
 )
@@ -11,6 +13,9 @@ import (
 //
 // Takes the text format in-memory as input, and returns either the binary
 // encoding of the text format or an error if parsing fails.
+//
+// There's no conversion in the other direction since the Wasmtime 13 C API
+// only provides `wasmtime_wat2wasm`, not a printer for the binary format.
 func Wat2Wasm(wat string) ([]byte, error) {
 	retVec := C.wasm_byte_vec_t{}
 	err := C.wasmtime_wat2wasm(
@@ -28,3 +33,45 @@ func Wat2Wasm(wat string) ([]byte, error) {
 
 	return nil, mkError(err)
 }
+
+// Wat2WasmOptions configures `Wat2WasmWithOptions`.
+type Wat2WasmOptions struct {
+	// If set, the result is validated with this engine's configuration, so
+	// text which uses proposals that aren't enabled for the engine, or which
+	// is otherwise invalid, is rejected.
+	Engine *Engine
+	// If set, text which uses proposals outside of this set is rejected, as
+	// determined by `DetectFeatures`.
+	Features *FeatureSet
+}
+
+// Wat2WasmWithOptions is the same as `Wat2Wasm` except that the result is
+// also checked according to `opts`, which allows tests and tooling to catch
+// text using proposals that the engine it's meant for doesn't support.
+//
+// The text parser itself always accepts every proposal it knows about, so
+// these checks are made on the binary result.
+func Wat2WasmWithOptions(wat string, opts Wat2WasmOptions) ([]byte, error) {
+	wasm, err := Wat2Wasm(wat)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Features != nil {
+		used, err := DetectFeatures(wasm)
+		if err != nil {
+			return nil, err
+		}
+		allowed := reflect.ValueOf(*opts.Features)
+		for i := 0; i < allowed.NumField(); i++ {
+			if reflect.ValueOf(used).Field(i).Bool() && !allowed.Field(i).Bool() {
+				return nil, fmt.Errorf("module uses the %s feature, which isn't enabled", allowed.Type().Field(i).Name)
+			}
+		}
+	}
+	if opts.Engine != nil {
+		if err := ModuleValidate(opts.Engine, wasm); err != nil {
+			return nil, err
+		}
+	}
+	return wasm, nil
+}
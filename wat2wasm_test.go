@@ -0,0 +1,26 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWat2WasmWithOptions(t *testing.T) {
+	wat := `(module (memory i64 1))`
+	_, err := Wat2WasmWithOptions(wat, Wat2WasmOptions{})
+	require.NoError(t, err)
+
+	_, err = Wat2WasmWithOptions(wat, Wat2WasmOptions{Features: &FeatureSet{SIMD: true}})
+	require.EqualError(t, err, "module uses the Memory64 feature, which isn't enabled")
+	_, err = Wat2WasmWithOptions(wat, Wat2WasmOptions{Features: &FeatureSet{Memory64: true}})
+	require.NoError(t, err)
+
+	config := NewConfig()
+	config.SetWasmMemory64(false)
+	_, err = Wat2WasmWithOptions(wat, Wat2WasmOptions{Engine: NewEngineWithConfig(config)})
+	require.Error(t, err)
+
+	_, err = Wat2WasmWithOptions(`(module`, Wat2WasmOptions{})
+	require.Error(t, err)
+}